@@ -0,0 +1,186 @@
+package main
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/ningen/v3"
+	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/automation"
+	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/window"
+)
+
+// startAutomation exports the D-Bus automation interface. It is only done by
+// the primary instance.
+func (m *manager) startAutomation() {
+	s, err := automation.Start(automationClient{m}, func(f func()) { glib.IdleAdd(f) })
+	if err != nil {
+		slog.Warn(
+			"cannot export the D-Bus automation interface",
+			"err", err)
+		return
+	}
+
+	m.automation = s
+}
+
+func (m *manager) stopAutomation() {
+	if m.automation != nil {
+		m.automation.Close()
+		m.automation = nil
+	}
+}
+
+func (m *manager) emitMention(win *window.Window, ev *gateway.MessageCreateEvent) {
+	if m.automation == nil {
+		return
+	}
+
+	state := gtkcord.FromContext(win.Context())
+
+	err := m.automation.EmitMention(automation.Mention{
+		GuildID:   ev.GuildID,
+		ChannelID: ev.ChannelID,
+		MessageID: ev.ID,
+		Author:    state.AuthorDisplayName(ev),
+		Content:   state.MessagePreview(&ev.Message),
+	})
+	if err != nil {
+		slog.Warn(
+			"cannot emit D-Bus mention signal",
+			"err", err)
+	}
+}
+
+// automationClient controls the window that was focused last for the D-Bus
+// automation interface.
+type automationClient struct {
+	m *manager
+}
+
+// window returns the focused window and its state, or ErrNotConnected if it
+// isn't logged in.
+func (c automationClient) window() (*window.Window, *gtkcord.State, error) {
+	win := c.m.focused
+	if win == nil || win.Chat == nil {
+		return nil, nil, automation.ErrNotConnected
+	}
+	return win, gtkcord.FromContext(win.Context()), nil
+}
+
+func (c automationClient) OpenChannel(id discord.ChannelID) error {
+	win, state, err := c.window()
+	if err != nil {
+		return err
+	}
+
+	if _, err := state.Cabinet.Channel(id); err != nil {
+		return errors.Wrapf(automation.ErrUnknownChannel, "channel %d", id)
+	}
+
+	win.OpenLink(gtkcord.Link{ChannelID: id})
+	win.Present()
+	return nil
+}
+
+func (c automationClient) OpenGuild(id discord.GuildID) error {
+	win, state, err := c.window()
+	if err != nil {
+		return err
+	}
+
+	if _, err := state.Cabinet.Guild(id); err != nil {
+		return errors.Wrapf(automation.ErrUnknownGuild, "guild %d", id)
+	}
+
+	win.ActivateAction("open-guild", gtkcord.NewGuildIDVariant(id))
+	win.Present()
+	return nil
+}
+
+func (c automationClient) SetStatus(status discord.Status) error {
+	win, _, err := c.window()
+	if err != nil {
+		return err
+	}
+
+	// The actions are named after the statuses, which are the same as
+	// Discord's.
+	win.ActivateAction("set-"+string(status), nil)
+	return nil
+}
+
+func (c automationClient) UnreadCounts() ([]automation.UnreadCount, error) {
+	_, state, err := c.window()
+	if err != nil {
+		return nil, err
+	}
+
+	guilds, err := state.Cabinet.Guilds()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get guilds")
+	}
+
+	slices.SortFunc(guilds, func(a, b discord.Guild) int { return cmp.Compare(a.ID, b.ID) })
+
+	counts := make([]automation.UnreadCount, 0, len(guilds)+1)
+	for _, guild := range guilds {
+		unread := state.GuildIsUnread(guild.ID, ningen.GuildUnreadOpts{
+			Types: gtkcord.AllowedChannelTypes,
+		})
+		counts = append(counts, automation.UnreadCount{
+			GuildID:  guild.ID,
+			Name:     guild.Name,
+			Unread:   unread != ningen.ChannelRead,
+			Mentions: state.GuildMentionCount(guild.ID),
+		})
+	}
+
+	// Count direct messages the same way as the sidebar does, where every
+	// unread message is a mention.
+	dms := automation.UnreadCount{Name: "Direct Messages"}
+	chs, _ := state.Cabinet.PrivateChannels()
+	for _, ch := range chs {
+		unread := state.ChannelIsUnread(ch.ID, ningen.UnreadOpts{})
+		if unread == ningen.ChannelRead {
+			continue
+		}
+		dms.Unread = true
+		dms.Mentions += max(state.ChannelCountUnreads(ch.ID, ningen.UnreadOpts{}), 1)
+	}
+	counts = append(counts, dms)
+
+	return counts, nil
+}
+
+func (c automationClient) SendMessage(chID discord.ChannelID, content string, done func(discord.MessageID, error)) error {
+	_, state, err := c.window()
+	if err != nil {
+		return err
+	}
+
+	if _, err := state.Cabinet.Channel(chID); err != nil {
+		return errors.Wrapf(automation.ErrUnknownChannel, "channel %d", chID)
+	}
+
+	state = state.Online()
+
+	go func() {
+		msg, err := state.SendMessageComplex(chID, api.SendMessageData{
+			Content: content,
+		})
+		if err != nil {
+			done(0, errors.Wrap(err, "cannot send message"))
+			return
+		}
+		done(msg.ID, nil)
+	}()
+
+	return nil
+}
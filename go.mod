@@ -14,29 +14,30 @@ require (
 	github.com/diamondburned/ningen/v3 v3.0.1-0.20250703054403-e5dc4cf15e84
 	github.com/dustin/go-humanize v1.0.1
 	github.com/enescakir/emoji v1.0.0
+	github.com/godbus/dbus/v5 v5.2.2
+	github.com/gorilla/websocket v1.5.3
 	github.com/ianlancetaylor/cgosymbolizer v0.0.0-20260706211533-3db786f0ca59
 	github.com/pkg/errors v0.9.1
 	github.com/sahilm/fuzzy v0.1.3
 	github.com/yuin/goldmark v1.8.5
+	github.com/zalando/go-keyring v0.2.8
 	libdb.so/ctxt v0.0.0-20240229093153-2db38a5d3c12
 	libdb.so/gotk4-sourceview/pkg v0.0.0-20260808232043-f3d195566193
 	libdb.so/gotk4-spelling/pkg v0.0.0-20241128063647-a9edc40bddb0
+	rsc.io/qr v0.2.0
 )
 
 require (
 	github.com/alecthomas/chroma v0.10.0 // indirect
 	github.com/danieljoos/wincred v1.2.3 // indirect
 	github.com/dlclark/regexp2 v1.12.0 // indirect
-	github.com/godbus/dbus/v5 v5.2.2 // indirect
 	github.com/gorilla/schema v1.4.1 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/leonelquinteros/gotext v1.7.2 // indirect
 	github.com/lmittmann/tint v1.2.0 // indirect
 	github.com/mattn/go-isatty v0.0.24 // indirect
 	github.com/puzpuzpuz/xsync/v3 v3.5.1 // indirect
 	github.com/twmb/murmur3 v1.1.8 // indirect
 	github.com/yalue/merged_fs v1.3.0 // indirect
-	github.com/zalando/go-keyring v0.2.8 // indirect
 	go4.org v0.0.0-20260112195520-a5071408f32f // indirect
 	golang.org/x/crypto v0.54.0 // indirect
 	golang.org/x/sync v0.22.0 // indirect
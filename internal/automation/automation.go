@@ -0,0 +1,287 @@
+// Package automation exports a D-Bus interface that lets other programs, such
+// as panel widgets and scripts, control Dissent and follow its mentions. The
+// interface is documented in so.libdb.dissent.Automation.xml.
+//
+// The package doesn't depend on GTK. The application implements Client, and
+// the service calls it on the main thread using the function given to Start.
+package automation
+
+import (
+	_ "embed"
+	"encoding/xml"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BusName is the name that the service owns on the session bus. It is
+	// separate from the name of the application, which is owned by GLib's own
+	// connection.
+	BusName = "so.libdb.dissent.Automation"
+	// ObjectPath is the path of the exported object.
+	ObjectPath = dbus.ObjectPath("/so/libdb/dissent/Automation")
+	// InterfaceName is the name of the exported interface.
+	InterfaceName = "so.libdb.dissent.Automation"
+)
+
+//go:embed so.libdb.dissent.Automation.xml
+var interfaceXML []byte
+
+// Errors that the Client may return. They are sent to the caller as the D-Bus
+// errors documented in the interface. Other errors are sent as
+// so.libdb.dissent.Automation.Error.Failed.
+var (
+	ErrNotConnected   = errors.New("not logged in")
+	ErrUnknownChannel = errors.New("unknown channel")
+	ErrUnknownGuild   = errors.New("unknown guild")
+)
+
+var errorNames = []struct {
+	err  error
+	name string
+}{
+	{ErrNotConnected, InterfaceName + ".Error.NotConnected"},
+	{ErrUnknownChannel, InterfaceName + ".Error.UnknownChannel"},
+	{ErrUnknownGuild, InterfaceName + ".Error.UnknownGuild"},
+}
+
+// UnreadCount is the unread state of a guild.
+type UnreadCount struct {
+	// GuildID is 0 for direct messages.
+	GuildID  discord.GuildID
+	Name     string
+	Unread   bool
+	Mentions int
+}
+
+// Mention is a new message that mentions the user.
+type Mention struct {
+	GuildID   discord.GuildID
+	ChannelID discord.ChannelID
+	MessageID discord.MessageID
+	// Author is the display name of the author.
+	Author string
+	// Content is a plain text preview of the message.
+	Content string
+}
+
+// Client is the client that the service controls. Its methods are called on
+// the main thread.
+type Client interface {
+	OpenChannel(discord.ChannelID) error
+	OpenGuild(discord.GuildID) error
+	SetStatus(discord.Status) error
+	UnreadCounts() ([]UnreadCount, error)
+	// SendMessage sends a message in the background. done is called once it is
+	// sent, which may be outside of the main thread. If SendMessage returns an
+	// error, then done is never called.
+	SendMessage(chID discord.ChannelID, content string, done func(discord.MessageID, error)) error
+}
+
+// Service is the exported D-Bus service.
+type Service struct {
+	conn   *dbus.Conn
+	client Client
+	invoke func(func())
+}
+
+// Start connects to the session bus and exports the service on it. invoke must
+// call the given function on the main thread, such as by using glib.IdleAdd.
+func Start(client Client, invoke func(func())) (*Service, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot connect to the session bus")
+	}
+
+	s, err := Export(conn, client, invoke)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "cannot request %s", BusName)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, errors.Errorf("%s is already owned by another program", BusName)
+	}
+
+	return s, nil
+}
+
+// Export exports the service on the given connection without requesting
+// BusName. Callers then reach it using the unique name of the connection. It is
+// meant for connections to a private bus.
+func Export(conn *dbus.Conn, client Client, invoke func(func())) (*Service, error) {
+	var node introspect.Node
+	if err := xml.Unmarshal(interfaceXML, &node); err != nil {
+		return nil, errors.Wrap(err, "invalid interface XML")
+	}
+
+	s := &Service{
+		conn:   conn,
+		client: client,
+		invoke: invoke,
+	}
+
+	if err := conn.Export(object{s}, ObjectPath, InterfaceName); err != nil {
+		return nil, errors.Wrap(err, "cannot export object")
+	}
+
+	introspectable := introspect.NewIntrospectable(&node)
+	if err := conn.Export(introspectable, ObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, errors.Wrap(err, "cannot export introspection data")
+	}
+
+	return s, nil
+}
+
+// Close closes the connection to the bus, which also releases BusName.
+func (s *Service) Close() error {
+	return s.conn.Close()
+}
+
+// EmitMention emits the MentionReceived signal.
+func (s *Service) EmitMention(m Mention) error {
+	return s.conn.Emit(
+		ObjectPath, InterfaceName+".MentionReceived",
+		int64(m.GuildID), int64(m.ChannelID), int64(m.MessageID),
+		m.Author, m.Content)
+}
+
+// call calls f on the main thread and waits for it to return.
+func (s *Service) call(f func() error) *dbus.Error {
+	errCh := make(chan error, 1)
+	s.invoke(func() { errCh <- f() })
+	return dbusError(<-errCh)
+}
+
+func dbusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+
+	name := InterfaceName + ".Error.Failed"
+	for _, e := range errorNames {
+		if errors.Is(err, e.err) {
+			name = e.name
+			break
+		}
+	}
+
+	return dbus.NewError(name, []any{err.Error()})
+}
+
+func invalidArgs(format string, v ...any) *dbus.Error {
+	return dbus.NewError(
+		"org.freedesktop.DBus.Error.InvalidArgs",
+		[]any{errors.Errorf(format, v...).Error()})
+}
+
+// statuses maps the statuses taken by SetStatus to Discord's.
+var statuses = map[string]discord.Status{
+	"online":    discord.OnlineStatus,
+	"idle":      discord.IdleStatus,
+	"dnd":       discord.DoNotDisturbStatus,
+	"invisible": discord.InvisibleStatus,
+}
+
+// object is the exported object. Its exported methods are the methods of the
+// interface.
+type object struct {
+	s *Service
+}
+
+func (o object) OpenChannel(id int64) *dbus.Error {
+	if id <= 0 {
+		return invalidArgs("invalid channel ID %d", id)
+	}
+	return o.s.call(func() error {
+		return o.s.client.OpenChannel(discord.ChannelID(id))
+	})
+}
+
+func (o object) OpenGuild(id int64) *dbus.Error {
+	if id <= 0 {
+		return invalidArgs("invalid guild ID %d", id)
+	}
+	return o.s.call(func() error {
+		return o.s.client.OpenGuild(discord.GuildID(id))
+	})
+}
+
+func (o object) SetStatus(status string) *dbus.Error {
+	discordStatus, ok := statuses[status]
+	if !ok {
+		return invalidArgs("invalid status %q, must be one of online, idle, dnd or invisible", status)
+	}
+	return o.s.call(func() error {
+		return o.s.client.SetStatus(discordStatus)
+	})
+}
+
+// unreadCount is UnreadCount as it is sent over the bus.
+type unreadCount struct {
+	GuildID  int64
+	Name     string
+	Unread   bool
+	Mentions uint32
+}
+
+func (o object) GetUnreadCounts() ([]unreadCount, *dbus.Error) {
+	var counts []UnreadCount
+	if err := o.s.call(func() (err error) {
+		counts, err = o.s.client.UnreadCounts()
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	wire := make([]unreadCount, len(counts))
+	for i, count := range counts {
+		wire[i] = unreadCount{
+			GuildID:  int64(count.GuildID),
+			Name:     count.Name,
+			Unread:   count.Unread,
+			Mentions: uint32(count.Mentions),
+		}
+	}
+
+	return wire, nil
+}
+
+func (o object) SendMessage(chID int64, content string) (int64, *dbus.Error) {
+	if chID <= 0 {
+		return 0, invalidArgs("invalid channel ID %d", chID)
+	}
+	if content == "" {
+		return 0, invalidArgs("content must not be empty")
+	}
+
+	type result struct {
+		id  discord.MessageID
+		err error
+	}
+	sent := make(chan result, 1)
+
+	if err := o.s.call(func() error {
+		return o.s.client.SendMessage(discord.ChannelID(chID), content, func(id discord.MessageID, err error) {
+			sent <- result{id, err}
+		})
+	}); err != nil {
+		return 0, err
+	}
+
+	r := <-sent
+	if r.err != nil {
+		return 0, dbusError(r.err)
+	}
+
+	return int64(r.id), nil
+}
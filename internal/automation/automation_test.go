@@ -0,0 +1,278 @@
+package automation
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/godbus/dbus/v5"
+	"github.com/pkg/errors"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	channels map[discord.ChannelID]bool
+	status   discord.Status
+	counts   []UnreadCount
+	sent     []string
+	sendErr  error
+}
+
+func (c *fakeClient) OpenChannel(id discord.ChannelID) error {
+	if !c.channels[id] {
+		return errors.Wrapf(ErrUnknownChannel, "channel %d", id)
+	}
+	return nil
+}
+
+func (c *fakeClient) OpenGuild(id discord.GuildID) error {
+	return errors.Wrapf(ErrUnknownGuild, "guild %d", id)
+}
+
+func (c *fakeClient) SetStatus(status discord.Status) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status = status
+	return nil
+}
+
+func (c *fakeClient) Status() discord.Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.status
+}
+
+func (c *fakeClient) SetSendError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sendErr = err
+}
+
+func (c *fakeClient) UnreadCounts() ([]UnreadCount, error) {
+	return c.counts, nil
+}
+
+func (c *fakeClient) SendMessage(chID discord.ChannelID, content string, done func(discord.MessageID, error)) error {
+	if !c.channels[chID] {
+		return errors.Wrapf(ErrUnknownChannel, "channel %d", chID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sendErr != nil {
+		go done(0, c.sendErr)
+		return nil
+	}
+
+	c.sent = append(c.sent, content)
+	go done(discord.MessageID(len(c.sent)), nil)
+	return nil
+}
+
+// startBus starts a private session bus and returns its address. The test is
+// skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal("cannot start dbus-daemon:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal("cannot read bus address:", err)
+	}
+
+	return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal("cannot connect to bus:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// export exports the service for the client on a private bus and returns the
+// object to call it with.
+func export(t *testing.T, client Client) (*Service, dbus.BusObject, *dbus.Conn) {
+	t.Helper()
+
+	addr := startBus(t)
+
+	service, err := Export(connect(t, addr), client, func(f func()) { f() })
+	if err != nil {
+		t.Fatal("cannot export service:", err)
+	}
+
+	caller := connect(t, addr)
+	obj := caller.Object(service.conn.Names()[0], ObjectPath)
+
+	return service, obj, caller
+}
+
+func assertErrorName(t *testing.T, err error, name string) {
+	t.Helper()
+
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		t.Fatalf("expected D-Bus error %s, got %v", name, err)
+	}
+	if dbusErr.Name != name {
+		t.Fatalf("expected D-Bus error %s, got %s", name, dbusErr.Name)
+	}
+}
+
+func TestOpenChannel(t *testing.T) {
+	client := &fakeClient{channels: map[discord.ChannelID]bool{1: true}}
+	_, obj, _ := export(t, client)
+
+	if err := obj.Call(InterfaceName+".OpenChannel", 0, int64(1)).Err; err != nil {
+		t.Fatal("cannot open channel:", err)
+	}
+
+	err := obj.Call(InterfaceName+".OpenChannel", 0, int64(2)).Err
+	assertErrorName(t, err, InterfaceName+".Error.UnknownChannel")
+
+	err = obj.Call(InterfaceName+".OpenChannel", 0, int64(-1)).Err
+	assertErrorName(t, err, "org.freedesktop.DBus.Error.InvalidArgs")
+}
+
+func TestSetStatus(t *testing.T) {
+	client := &fakeClient{}
+	_, obj, _ := export(t, client)
+
+	if err := obj.Call(InterfaceName+".SetStatus", 0, "dnd").Err; err != nil {
+		t.Fatal("cannot set status:", err)
+	}
+	if status := client.Status(); status != discord.DoNotDisturbStatus {
+		t.Fatalf("expected status %q, got %q", discord.DoNotDisturbStatus, status)
+	}
+
+	err := obj.Call(InterfaceName+".SetStatus", 0, "busy").Err
+	assertErrorName(t, err, "org.freedesktop.DBus.Error.InvalidArgs")
+}
+
+func TestGetUnreadCounts(t *testing.T) {
+	client := &fakeClient{
+		counts: []UnreadCount{
+			{GuildID: 1, Name: "Guild", Unread: true, Mentions: 2},
+			{Name: "Direct Messages"},
+		},
+	}
+	_, obj, _ := export(t, client)
+
+	var counts []struct {
+		GuildID  int64
+		Name     string
+		Unread   bool
+		Mentions uint32
+	}
+	if err := obj.Call(InterfaceName+".GetUnreadCounts", 0).Store(&counts); err != nil {
+		t.Fatal("cannot get unread counts:", err)
+	}
+
+	if len(counts) != len(client.counts) {
+		t.Fatalf("expected %d counts, got %d", len(client.counts), len(counts))
+	}
+	for i, count := range counts {
+		want := client.counts[i]
+		if count.GuildID != int64(want.GuildID) ||
+			count.Name != want.Name ||
+			count.Unread != want.Unread ||
+			count.Mentions != uint32(want.Mentions) {
+			t.Errorf("count %d: expected %+v, got %+v", i, want, count)
+		}
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	client := &fakeClient{channels: map[discord.ChannelID]bool{1: true}}
+	_, obj, _ := export(t, client)
+
+	var id int64
+	if err := obj.Call(InterfaceName+".SendMessage", 0, int64(1), "hello").Store(&id); err != nil {
+		t.Fatal("cannot send message:", err)
+	}
+	if id != 1 {
+		t.Fatalf("expected message ID 1, got %d", id)
+	}
+
+	err := obj.Call(InterfaceName+".SendMessage", 0, int64(2), "hello").Err
+	assertErrorName(t, err, InterfaceName+".Error.UnknownChannel")
+
+	err = obj.Call(InterfaceName+".SendMessage", 0, int64(1), "").Err
+	assertErrorName(t, err, "org.freedesktop.DBus.Error.InvalidArgs")
+
+	client.SetSendError(errors.New("rate limited"))
+
+	err = obj.Call(InterfaceName+".SendMessage", 0, int64(1), "hello").Err
+	assertErrorName(t, err, InterfaceName+".Error.Failed")
+}
+
+func TestMentionReceived(t *testing.T) {
+	service, _, caller := export(t, &fakeClient{})
+
+	if err := caller.AddMatchSignal(
+		dbus.WithMatchObjectPath(ObjectPath),
+		dbus.WithMatchInterface(InterfaceName),
+		dbus.WithMatchMember("MentionReceived"),
+	); err != nil {
+		t.Fatal("cannot match signal:", err)
+	}
+
+	signals := make(chan *dbus.Signal, 1)
+	caller.Signal(signals)
+
+	mention := Mention{
+		GuildID:   1,
+		ChannelID: 2,
+		MessageID: 3,
+		Author:    "Author",
+		Content:   "Hello",
+	}
+	if err := service.EmitMention(mention); err != nil {
+		t.Fatal("cannot emit mention:", err)
+	}
+
+	select {
+	case sig := <-signals:
+		want := []any{int64(1), int64(2), int64(3), "Author", "Hello"}
+		if len(sig.Body) != len(want) {
+			t.Fatalf("expected %d values, got %v", len(want), sig.Body)
+		}
+		for i := range want {
+			if sig.Body[i] != want[i] {
+				t.Errorf("value %d: expected %v, got %v", i, want[i], sig.Body[i])
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for MentionReceived")
+	}
+}
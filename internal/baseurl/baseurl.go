@@ -0,0 +1,79 @@
+// Package baseurl sends Discord API requests to another server instead of
+// https://discord.com, such as the stand-in server in package fakediscord. It
+// has no GTK dependency, so tests can use it without a display.
+package baseurl
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/utils/httputil/httpdriver"
+	"github.com/pkg/errors"
+)
+
+// discordHost is the host that Transport replaces.
+const discordHost = "discord.com"
+
+// Parse parses the given base URL, which must have a scheme and a host.
+func Parse(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base URL")
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("base URL %q must have a scheme and a host", baseURL)
+	}
+
+	return u, nil
+}
+
+// Use makes the client send all of its requests to base instead of Discord.
+// The requests are sent using next, or http.DefaultTransport if next is nil.
+func Use(c *api.Client, base *url.URL, next http.RoundTripper) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.Client.Client = httpdriver.WrapClient(http.Client{
+		Transport: &Transport{Base: base, Next: next},
+	})
+}
+
+// UseGateway makes the gateway URL be queried from base, so the websocket
+// connection follows whatever that server returns.
+//
+// Note that the gateway endpoint is a global variable within arikawa, so this
+// affects all states in the process.
+func UseGateway(base *url.URL) {
+	api.EndpointGateway = base.String() + api.Path + "/gateway"
+}
+
+// Transport rewrites requests to Discord so that they go to Base instead.
+// Other requests are passed to Next as-is.
+type Transport struct {
+	Base *url.URL
+	Next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host != discordHost {
+		return t.Next.RoundTrip(r)
+	}
+
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.Base.Scheme
+	r.URL.Host = t.Base.Host
+	if t.Base.Path != "" {
+		r.URL.Path = t.Base.Path + r.URL.Path
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = t.Base.EscapedPath() + r.URL.RawPath
+		}
+	}
+	r.Host = t.Base.Host
+
+	return t.Next.RoundTrip(r)
+}
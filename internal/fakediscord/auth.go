@@ -0,0 +1,265 @@
+package fakediscord
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+)
+
+// PasswordLogin configures the email and password login endpoints, which are
+// disabled until SetPasswordLogin is called.
+type PasswordLogin struct {
+	Login    string
+	Password string
+
+	// TOTP, SMS and Backup are the codes that each second factor accepts. A
+	// method is disabled if its code is empty. If all of them are empty, then
+	// logging in doesn't need a second factor.
+	TOTP   string
+	SMS    string
+	Backup string
+
+	// Captcha makes logging in always fail with a captcha request.
+	Captcha bool
+}
+
+func (l *PasswordLogin) mfa() bool {
+	return l.TOTP != "" || l.SMS != "" || l.Backup != ""
+}
+
+// SetPasswordLogin enables the email and password login endpoints. Logging in
+// gives the client the server's Token.
+func (s *Server) SetPasswordLogin(l PasswordLogin) {
+	s.mu.Lock()
+	s.passwordLogin = &l
+	s.mu.Unlock()
+}
+
+var (
+	errInvalidTwoFactorCode = &apiError{Status: 400, Code: 60008, Message: "Invalid two-factor code"}
+	errInvalidMFATicket     = &apiError{Status: 401, Code: 0, Message: "401: Unauthorized"}
+)
+
+func (s *Server) routeAuth(mux *http.ServeMux) {
+	// None of these are authorized, since the client has no token yet.
+	routes := map[string]http.HandlerFunc{
+		"POST /auth/login":        s.postLogin,
+		"POST /auth/mfa/totp":     s.postMFA(func(l *PasswordLogin) string { return l.TOTP }),
+		"POST /auth/mfa/sms":      s.postMFA(func(l *PasswordLogin) string { return l.SMS }),
+		"POST /auth/mfa/backup":   s.postMFA(func(l *PasswordLogin) string { return l.Backup }),
+		"POST /auth/mfa/sms/send": s.postSendSMS,
+	}
+
+	for pattern, handler := range routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.HandleFunc(method+" "+api.Path+path, handler)
+	}
+}
+
+func (s *Server) postLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.passwordLogin
+	if l == nil || body.Login != l.Login || body.Password != l.Password {
+		writeInvalidLogin(w)
+		return
+	}
+
+	if l.Captcha {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]any{
+			"captcha_key":     []string{"captcha-required"},
+			"captcha_sitekey": "fake-sitekey",
+			"captcha_service": "hcaptcha",
+		})
+		return
+	}
+
+	if !l.mfa() {
+		writeJSON(w, map[string]any{
+			"user_id": s.model.me.ID,
+			"token":   s.Token,
+		})
+		return
+	}
+
+	var b [16]byte
+	rand.Read(b[:])
+	ticket := hex.EncodeToString(b[:])
+	s.mfaTickets[ticket] = struct{}{}
+
+	writeJSON(w, map[string]any{
+		"user_id":  s.model.me.ID,
+		"ticket":   ticket,
+		"mfa":      true,
+		"totp":     l.TOTP != "",
+		"sms":      l.SMS != "",
+		"backup":   l.Backup != "",
+		"webauthn": nil,
+	})
+}
+
+func (s *Server) postSendSMS(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	_, ok := s.mfaTickets[body.Ticket]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, errInvalidMFATicket)
+		return
+	}
+
+	writeJSON(w, map[string]string{"phone": "+*******0000"})
+}
+
+func (s *Server) postMFA(code func(*PasswordLogin) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Code   string `json:"code"`
+			Ticket string `json:"ticket"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.mfaTickets[body.Ticket]; !ok || s.passwordLogin == nil {
+			writeError(w, errInvalidMFATicket)
+			return
+		}
+
+		want := code(s.passwordLogin)
+		if want == "" || body.Code != want {
+			writeError(w, errInvalidTwoFactorCode)
+			return
+		}
+
+		delete(s.mfaTickets, body.Ticket)
+
+		writeJSON(w, map[string]any{
+			"user_id": s.model.me.ID,
+			"token":   s.Token,
+		})
+	}
+}
+
+// writeInvalidLogin writes the form error that Discord returns for a wrong
+// email or password.
+func writeInvalidLogin(w http.ResponseWriter) {
+	fieldErr := map[string]any{
+		"_errors": []map[string]string{{
+			"code":    "INVALID_LOGIN",
+			"message": "Login or password is invalid.",
+		}},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":    50035,
+		"message": "Invalid Form Body",
+		"errors": map[string]any{
+			"login":    fieldErr,
+			"password": fieldErr,
+		},
+	})
+}
+
+// Revoked returns true if the client has revoked the token by logging out. A
+// revoked token is no longer accepted.
+func (s *Server) Revoked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revoked
+}
+
+// tokenValid returns true if the token may be used.
+func (s *Server) tokenValid(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.revoked {
+		return false
+	}
+	return s.Token == "" || token == s.Token
+}
+
+// getAuthSessions lists every gateway connection as a logged in device.
+func (s *Server) getAuthSessions(w http.ResponseWriter, r *http.Request) {
+	type authSession struct {
+		IDHash string `json:"id_hash"`
+	}
+
+	s.mu.Lock()
+	sessions := make([]authSession, 0, len(s.sessions))
+	for session := range s.sessions {
+		if session.ready() {
+			sessions = append(sessions, authSession{IDHash: session.id})
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"user_sessions": sessions})
+}
+
+func (s *Server) postSessionsLogout(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		SessionIDHashes []string `json:"session_id_hashes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	var sessions []*gatewaySession
+	for session := range s.sessions {
+		if slices.Contains(body.SessionIDHashes, session.id) {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close(4004) // authentication failed
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) postLogout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.revoked = true
+	s.mu.Unlock()
+
+	s.Disconnect(4004)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,301 @@
+package fakediscord_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+	"libdb.so/dissent/internal/baseurl"
+	"libdb.so/dissent/internal/fakediscord"
+)
+
+const token = "fake-token"
+
+var me = discord.User{
+	ID:       1,
+	Username: "me",
+}
+
+// scenario is a fake server with a guild and a text channel, and an arikawa
+// session connected to it.
+type scenario struct {
+	srv     *fakediscord.Server
+	state   *state.State
+	other   discord.User
+	guild   discord.Guild
+	channel discord.Channel
+}
+
+func newScenario(t *testing.T) *scenario {
+	t.Helper()
+
+	srv := fakediscord.New(token, me)
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		t.Fatal("cannot start fake server:", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	sc := &scenario{srv: srv}
+	sc.other = srv.AddUser(discord.User{Username: "other"})
+	sc.guild = srv.AddGuild(discord.Guild{Name: "Guild"})
+
+	if _, err := srv.AddMember(sc.guild.ID, sc.other.ID); err != nil {
+		t.Fatal("cannot add member:", err)
+	}
+
+	ch, err := srv.AddChannel(discord.Channel{
+		GuildID: sc.guild.ID,
+		Name:    "general",
+		Type:    discord.GuildText,
+	})
+	if err != nil {
+		t.Fatal("cannot add channel:", err)
+	}
+	sc.channel = ch
+
+	u, err := baseurl.Parse(srv.URL())
+	if err != nil {
+		t.Fatal("cannot parse base URL:", err)
+	}
+
+	// Restore the global gateway endpoint that UseGateway replaces.
+	endpoint := api.EndpointGateway
+	t.Cleanup(func() { api.EndpointGateway = endpoint })
+	baseurl.UseGateway(u)
+
+	sc.state = state.New(token)
+	baseurl.Use(sc.state.Client, u, nil)
+
+	return sc
+}
+
+// open connects the session and waits until it is ready.
+func (sc *scenario) open(t *testing.T) {
+	t.Helper()
+
+	ready := waitFor[*gateway.ReadyEvent](t, sc.state)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := sc.state.Open(ctx); err != nil {
+		t.Fatal("cannot open session:", err)
+	}
+	t.Cleanup(func() { sc.state.Close() })
+
+	ev := ready()
+	if ev.User.ID != me.ID {
+		t.Fatalf("expected to be logged in as %d, got %d", me.ID, ev.User.ID)
+	}
+}
+
+// waitFor starts listening for an event of type T. The returned function
+// waits for the event and returns it.
+func waitFor[T any](t *testing.T, s *state.State) func() T {
+	t.Helper()
+
+	events := make(chan T, 1)
+	rm := s.AddHandler(func(ev T) {
+		select {
+		case events <- ev:
+		default:
+		}
+	})
+
+	return func() T {
+		t.Helper()
+		defer rm()
+
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(10 * time.Second):
+			var z T
+			t.Fatalf("timed out waiting for %T", z)
+			return z
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	sc := newScenario(t)
+	sc.open(t)
+
+	if n := sc.srv.Sessions(); n != 1 {
+		t.Fatalf("expected 1 session, got %d", n)
+	}
+
+	guild, err := sc.state.Cabinet.Guild(sc.guild.ID)
+	if err != nil {
+		t.Fatal("guild is not in the state:", err)
+	}
+	if guild.Name != sc.guild.Name {
+		t.Errorf("expected guild %q, got %q", sc.guild.Name, guild.Name)
+	}
+
+	ch, err := sc.state.Cabinet.Channel(sc.channel.ID)
+	if err != nil {
+		t.Fatal("channel is not in the state:", err)
+	}
+	if ch.Name != sc.channel.Name {
+		t.Errorf("expected channel %q, got %q", sc.channel.Name, ch.Name)
+	}
+}
+
+func TestIdentifyInvalidToken(t *testing.T) {
+	sc := newScenario(t)
+	sc.srv.Token = "other-token"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := sc.state.Open(ctx); err == nil {
+		sc.state.Close()
+		t.Fatal("session opened with an invalid token")
+	}
+}
+
+func TestResume(t *testing.T) {
+	sc := newScenario(t)
+	sc.open(t)
+
+	resumed := waitFor[*gateway.ResumedEvent](t, sc.state)
+	sc.srv.Disconnect(4000)
+	resumed()
+
+	// Events are still received after resuming.
+	created := waitFor[*gateway.MessageCreateEvent](t, sc.state)
+
+	msg, err := sc.srv.PostMessage(sc.channel.ID, sc.other.ID, "hello after resuming")
+	if err != nil {
+		t.Fatal("cannot post message:", err)
+	}
+
+	if ev := created(); ev.ID != msg.ID {
+		t.Fatalf("expected message %d, got %d", msg.ID, ev.ID)
+	}
+}
+
+func TestReceiveMessage(t *testing.T) {
+	sc := newScenario(t)
+	sc.open(t)
+
+	created := waitFor[*gateway.MessageCreateEvent](t, sc.state)
+
+	msg, err := sc.srv.PostMessage(sc.channel.ID, sc.other.ID, "hello")
+	if err != nil {
+		t.Fatal("cannot post message:", err)
+	}
+
+	ev := created()
+	if ev.ID != msg.ID || ev.Content != "hello" || ev.Author.ID != sc.other.ID {
+		t.Fatalf("unexpected message %+v", ev.Message)
+	}
+
+	messages, err := sc.state.Messages(sc.channel.ID, 50)
+	if err != nil {
+		t.Fatal("cannot get messages:", err)
+	}
+	if len(messages) != 1 || messages[0].ID != msg.ID {
+		t.Fatalf("expected only message %d, got %v", msg.ID, messages)
+	}
+}
+
+func TestSendEditDeleteMessage(t *testing.T) {
+	sc := newScenario(t)
+	sc.open(t)
+
+	created := waitFor[*gateway.MessageCreateEvent](t, sc.state)
+
+	msg, err := sc.state.SendMessage(sc.channel.ID, "hello")
+	if err != nil {
+		t.Fatal("cannot send message:", err)
+	}
+	if msg.Author.ID != me.ID {
+		t.Errorf("expected message from %d, got %d", me.ID, msg.Author.ID)
+	}
+	if ev := created(); ev.ID != msg.ID {
+		t.Fatalf("expected Message Create for %d, got %d", msg.ID, ev.ID)
+	}
+
+	updated := waitFor[*gateway.MessageUpdateEvent](t, sc.state)
+
+	edited, err := sc.state.EditMessage(sc.channel.ID, msg.ID, "hello, edited")
+	if err != nil {
+		t.Fatal("cannot edit message:", err)
+	}
+	if edited.Content != "hello, edited" {
+		t.Errorf("expected edited content, got %q", edited.Content)
+	}
+	if ev := updated(); ev.Content != "hello, edited" {
+		t.Errorf("expected Message Update with edited content, got %q", ev.Content)
+	}
+
+	stored, err := sc.srv.Message(sc.channel.ID, msg.ID)
+	if err != nil {
+		t.Fatal("message is not on the server:", err)
+	}
+	if stored.Content != "hello, edited" {
+		t.Errorf("server has content %q", stored.Content)
+	}
+
+	deleted := waitFor[*gateway.MessageDeleteEvent](t, sc.state)
+
+	if err := sc.state.DeleteMessage(sc.channel.ID, msg.ID, ""); err != nil {
+		t.Fatal("cannot delete message:", err)
+	}
+	if ev := deleted(); ev.ID != msg.ID {
+		t.Errorf("expected Message Delete for %d, got %d", msg.ID, ev.ID)
+	}
+
+	if _, err := sc.srv.Message(sc.channel.ID, msg.ID); err == nil {
+		t.Error("message is still on the server")
+	}
+}
+
+func TestReact(t *testing.T) {
+	sc := newScenario(t)
+	sc.open(t)
+
+	msg, err := sc.srv.PostMessage(sc.channel.ID, sc.other.ID, "react to me")
+	if err != nil {
+		t.Fatal("cannot post message:", err)
+	}
+
+	const emoji = discord.APIEmoji("👍")
+
+	added := waitFor[*gateway.MessageReactionAddEvent](t, sc.state)
+
+	if err := sc.state.React(sc.channel.ID, msg.ID, emoji); err != nil {
+		t.Fatal("cannot react:", err)
+	}
+	if ev := added(); ev.MessageID != msg.ID || ev.UserID != me.ID || ev.Emoji.Name != "👍" {
+		t.Fatalf("unexpected Message Reaction Add %+v", ev)
+	}
+
+	stored, err := sc.srv.Message(sc.channel.ID, msg.ID)
+	if err != nil {
+		t.Fatal("message is not on the server:", err)
+	}
+	if len(stored.Reactions) != 1 || stored.Reactions[0].Count != 1 || !stored.Reactions[0].Me {
+		t.Fatalf("unexpected reactions on the server: %+v", stored.Reactions)
+	}
+
+	removed := waitFor[*gateway.MessageReactionRemoveEvent](t, sc.state)
+
+	if err := sc.state.Unreact(sc.channel.ID, msg.ID, emoji); err != nil {
+		t.Fatal("cannot unreact:", err)
+	}
+	if ev := removed(); ev.MessageID != msg.ID || ev.UserID != me.ID {
+		t.Fatalf("unexpected Message Reaction Remove %+v", ev)
+	}
+
+	stored, _ = sc.srv.Message(sc.channel.ID, msg.ID)
+	if len(stored.Reactions) != 0 {
+		t.Fatalf("reaction is still on the server: %+v", stored.Reactions)
+	}
+}
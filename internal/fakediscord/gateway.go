@@ -0,0 +1,200 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/gorilla/websocket"
+)
+
+const closeNormal = websocket.CloseNormalClosure
+
+// HeartbeatInterval is the heartbeat interval sent to clients in the Hello
+// event.
+var HeartbeatInterval = 41250 * time.Millisecond
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// gatewaySession is a single websocket connection to the fake gateway.
+type gatewaySession struct {
+	conn *websocket.Conn
+
+	wmu sync.Mutex // guards writes to conn, id and seq
+	id  string
+	seq int64
+
+	identified atomic.Bool
+}
+
+var sessionCounter atomic.Uint64
+
+// incomingOp is an op sent by the client.
+type incomingOp struct {
+	Code ws.OpCode       `json:"op"`
+	Data json.RawMessage `json:"d"`
+}
+
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn(
+			"fake gateway cannot upgrade connection",
+			"err", err)
+		return
+	}
+
+	session := &gatewaySession{
+		conn: conn,
+		id:   "fake-session-" + strconv.FormatUint(sessionCounter.Add(1), 10),
+	}
+
+	s.mu.Lock()
+	s.sessions[session] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	session.send(&gateway.HelloEvent{
+		HeartbeatInterval: discord.Milliseconds(HeartbeatInterval.Milliseconds()),
+	})
+
+	for {
+		var op incomingOp
+		if err := conn.ReadJSON(&op); err != nil {
+			return
+		}
+
+		if err := s.handleOp(session, op); err != nil {
+			slog.Warn(
+				"fake gateway cannot handle op",
+				"op", op.Code,
+				"err", err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleOp(session *gatewaySession, op incomingOp) error {
+	switch op.Code {
+	case (*gateway.HeartbeatCommand)(nil).Op():
+		session.send(&gateway.HeartbeatAckEvent{})
+
+	case (*gateway.IdentifyCommand)(nil).Op():
+		var identify gateway.IdentifyCommand
+		if err := json.Unmarshal(op.Data, &identify); err != nil {
+			return err
+		}
+
+		if !s.tokenValid(identify.Token) {
+			session.close(4004) // authentication failed
+			return nil
+		}
+
+		s.mu.Lock()
+		ready := s.model.readyEvent(session.id)
+		s.mu.Unlock()
+
+		session.dispatch("READY", &ready)
+		session.identified.Store(true)
+
+	case (*gateway.ResumeCommand)(nil).Op():
+		var resume gateway.ResumeCommand
+		if err := json.Unmarshal(op.Data, &resume); err != nil {
+			return err
+		}
+
+		if !s.tokenValid(resume.Token) {
+			session.close(4004)
+			return nil
+		}
+
+		// We don't keep a backlog of dispatched events, so resuming is the
+		// same as starting over with the same session ID.
+		session.wmu.Lock()
+		session.id = resume.SessionID
+		session.seq = resume.Sequence
+		session.wmu.Unlock()
+
+		session.dispatch("RESUMED", &gateway.ResumedEvent{})
+		session.identified.Store(true)
+	}
+
+	return nil
+}
+
+// dispatch sends the event to all sessions that have identified.
+func (s *Server) dispatch(ev ws.Event) {
+	s.mu.Lock()
+	sessions := make([]*gatewaySession, 0, len(s.sessions))
+	for session := range s.sessions {
+		if session.ready() {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.dispatch(ev.EventType(), ev)
+	}
+}
+
+func (s *gatewaySession) ready() bool {
+	return s.identified.Load()
+}
+
+func (s *gatewaySession) send(ev ws.Event) {
+	s.write(ws.Op{Code: ev.Op(), Data: ev})
+}
+
+func (s *gatewaySession) dispatch(t ws.EventType, data any) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.seq++
+	s.writeLocked(struct {
+		Code     ws.OpCode    `json:"op"`
+		Data     any          `json:"d"`
+		Type     ws.EventType `json:"t"`
+		Sequence int64        `json:"s"`
+	}{0, data, t, s.seq})
+}
+
+func (s *gatewaySession) write(v any) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.writeLocked(v)
+}
+
+func (s *gatewaySession) writeLocked(v any) {
+	if err := s.conn.WriteJSON(v); err != nil {
+		slog.Debug(
+			"fake gateway cannot write to session",
+			"session_id", s.id,
+			"err", err)
+	}
+}
+
+func (s *gatewaySession) close(code int) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	msg := websocket.FormatCloseMessage(code, "")
+	s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	s.conn.Close()
+}
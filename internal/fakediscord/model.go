@@ -0,0 +1,428 @@
+package fakediscord
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+// model is the in-memory state of the fake server. All fields are guarded by
+// Server.mu.
+type model struct {
+	me       discord.User
+	users    map[discord.UserID]discord.User
+	guilds   []discord.GuildID
+	guildMap map[discord.GuildID]*guildModel
+	channels map[discord.ChannelID]*discord.Channel
+	messages map[discord.ChannelID][]discord.Message // oldest first
+	lastID   discord.Snowflake
+}
+
+type guildModel struct {
+	guild   discord.Guild
+	members []discord.Member
+}
+
+func newModel(me discord.User) model {
+	return model{
+		me:       me,
+		users:    map[discord.UserID]discord.User{me.ID: me},
+		guildMap: make(map[discord.GuildID]*guildModel),
+		channels: make(map[discord.ChannelID]*discord.Channel),
+		messages: make(map[discord.ChannelID][]discord.Message),
+	}
+}
+
+// newID returns a new unique snowflake. IDs are strictly increasing, even if
+// they're generated within the same millisecond.
+func (m *model) newID() discord.Snowflake {
+	id := discord.NewSnowflake(time.Now())
+	if id <= m.lastID {
+		id = m.lastID + 1
+	}
+	m.lastID = id
+	return id
+}
+
+func (m *model) guildCreateEvent(id discord.GuildID) gateway.GuildCreateEvent {
+	g := m.guildMap[id]
+
+	ev := gateway.GuildCreateEvent{
+		Guild:       g.guild,
+		Joined:      discord.NowTimestamp(),
+		MemberCount: uint64(len(g.members)),
+		Members:     slices.Clone(g.members),
+	}
+
+	for _, ch := range m.channels {
+		if ch.GuildID == id {
+			ev.Channels = append(ev.Channels, *ch)
+		}
+	}
+
+	slices.SortFunc(ev.Channels, func(a, b discord.Channel) int {
+		return a.Position - b.Position
+	})
+
+	return ev
+}
+
+func (m *model) readyEvent(sessionID string) readyEvent {
+	ev := readyEvent{
+		ReadyEvent: gateway.ReadyEvent{
+			Version:   9,
+			User:      m.me,
+			SessionID: sessionID,
+		},
+		UserSettings: &gateway.UserSettings{Theme: "dark"},
+	}
+
+	for _, id := range m.guilds {
+		ev.Guilds = append(ev.Guilds, m.guildCreateEvent(id))
+	}
+
+	for _, ch := range m.channels {
+		if !ch.GuildID.IsValid() {
+			ev.PrivateChannels = append(ev.PrivateChannels, *ch)
+		}
+
+		// Mark every channel as read up until now.
+		if msgs := m.messages[ch.ID]; len(msgs) > 0 {
+			ev.ReadStates = append(ev.ReadStates, gateway.ReadState{
+				ChannelID:     ch.ID,
+				LastMessageID: msgs[len(msgs)-1].ID,
+			})
+		}
+	}
+
+	return ev
+}
+
+// readyEvent is a Ready event that also marshals the extra fields sent to user
+// accounts. gateway.ReadyEvent only unmarshals them.
+type readyEvent struct {
+	gateway.ReadyEvent
+	UserSettings *gateway.UserSettings `json:"user_settings,omitempty"`
+	ReadStates   []gateway.ReadState   `json:"read_state"`
+}
+
+func (m *model) channel(id discord.ChannelID) (*discord.Channel, error) {
+	ch, ok := m.channels[id]
+	if !ok {
+		return nil, errUnknownChannel
+	}
+	return ch, nil
+}
+
+func (m *model) messageIndex(chID discord.ChannelID, id discord.MessageID) (int, error) {
+	if _, err := m.channel(chID); err != nil {
+		return -1, err
+	}
+
+	i := slices.IndexFunc(m.messages[chID], func(msg discord.Message) bool {
+		return msg.ID == id
+	})
+	if i == -1 {
+		return -1, errUnknownMessage
+	}
+
+	return i, nil
+}
+
+// member returns the member of the user in the channel's guild, if any.
+func (m *model) member(chID discord.ChannelID, userID discord.UserID) *discord.Member {
+	ch, ok := m.channels[chID]
+	if !ok || !ch.GuildID.IsValid() {
+		return nil
+	}
+
+	g := m.guildMap[ch.GuildID]
+	for i, member := range g.members {
+		if member.User.ID == userID {
+			return &g.members[i]
+		}
+	}
+
+	return nil
+}
+
+// AddUser adds a user that can later be used as a message author or a guild
+// member. If the user's ID is invalid, a new one is assigned.
+func (s *Server) AddUser(u discord.User) discord.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !u.ID.IsValid() {
+		u.ID = discord.UserID(s.model.newID())
+	}
+	if u.Discriminator == "" {
+		u.Discriminator = "0"
+	}
+
+	s.model.users[u.ID] = u
+	return u
+}
+
+// AddGuild adds a guild that the logged-in user is a member of. If the guild's
+// ID is invalid, a new one is assigned. Connected clients receive a Guild Create
+// event.
+func (s *Server) AddGuild(g discord.Guild) discord.Guild {
+	s.mu.Lock()
+
+	if !g.ID.IsValid() {
+		g.ID = discord.GuildID(s.model.newID())
+	}
+	if !g.OwnerID.IsValid() {
+		g.OwnerID = s.model.me.ID
+	}
+
+	s.model.guilds = append(s.model.guilds, g.ID)
+	s.model.guildMap[g.ID] = &guildModel{
+		guild:   g,
+		members: []discord.Member{{User: s.model.me, Joined: discord.NowTimestamp()}},
+	}
+
+	ev := s.model.guildCreateEvent(g.ID)
+	s.mu.Unlock()
+
+	s.dispatch(&ev)
+	return g
+}
+
+// AddMember adds the user as a member of the guild. The user must have been
+// added using AddUser.
+func (s *Server) AddMember(guildID discord.GuildID, userID discord.UserID) (discord.Member, error) {
+	s.mu.Lock()
+
+	g, ok := s.model.guildMap[guildID]
+	if !ok {
+		s.mu.Unlock()
+		return discord.Member{}, errUnknownGuild
+	}
+
+	u, ok := s.model.users[userID]
+	if !ok {
+		s.mu.Unlock()
+		return discord.Member{}, errUnknownUser
+	}
+
+	member := discord.Member{User: u, Joined: discord.NowTimestamp()}
+	g.members = append(g.members, member)
+	s.mu.Unlock()
+
+	s.dispatch(&gateway.GuildMemberAddEvent{Member: member, GuildID: guildID})
+	return member, nil
+}
+
+// AddChannel adds a channel. If the channel's ID is invalid, a new one is
+// assigned. If the channel has no guild ID, it is added as a private channel.
+// Connected clients receive a Channel Create event.
+func (s *Server) AddChannel(ch discord.Channel) (discord.Channel, error) {
+	s.mu.Lock()
+
+	if ch.GuildID.IsValid() {
+		if _, ok := s.model.guildMap[ch.GuildID]; !ok {
+			s.mu.Unlock()
+			return discord.Channel{}, errUnknownGuild
+		}
+	}
+
+	if !ch.ID.IsValid() {
+		ch.ID = discord.ChannelID(s.model.newID())
+	}
+
+	s.model.channels[ch.ID] = &ch
+	s.mu.Unlock()
+
+	s.dispatch(&gateway.ChannelCreateEvent{Channel: ch})
+	return ch, nil
+}
+
+// PostMessage adds a message to the channel as if the author sent it. The
+// author must have been added using AddUser, or be the logged-in user.
+// Connected clients receive a Message Create event.
+func (s *Server) PostMessage(chID discord.ChannelID, authorID discord.UserID, content string) (discord.Message, error) {
+	s.mu.Lock()
+
+	author, ok := s.model.users[authorID]
+	if !ok {
+		s.mu.Unlock()
+		return discord.Message{}, errUnknownUser
+	}
+
+	ev, err := s.model.createMessage(chID, author, content, "")
+	s.mu.Unlock()
+
+	if err != nil {
+		return discord.Message{}, err
+	}
+
+	s.dispatch(ev)
+	return ev.Message, nil
+}
+
+func (m *model) createMessage(chID discord.ChannelID, author discord.User, content, nonce string) (*gateway.MessageCreateEvent, error) {
+	ch, err := m.channel(chID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := discord.Message{
+		ID:        discord.MessageID(m.newID()),
+		Type:      discord.DefaultMessage,
+		ChannelID: ch.ID,
+		GuildID:   ch.GuildID,
+		Content:   content,
+		Author:    author,
+		Timestamp: discord.NowTimestamp(),
+		Nonce:     nonce,
+	}
+
+	m.messages[ch.ID] = append(m.messages[ch.ID], msg)
+	ch.LastMessageID = msg.ID
+
+	return &gateway.MessageCreateEvent{
+		Message: msg,
+		Member:  m.member(ch.ID, author.ID),
+	}, nil
+}
+
+// EditMessage replaces the content of the message. Connected clients receive
+// a Message Update event.
+func (s *Server) EditMessage(chID discord.ChannelID, id discord.MessageID, content string) (discord.Message, error) {
+	s.mu.Lock()
+	ev, err := s.model.editMessage(chID, id, content)
+	s.mu.Unlock()
+
+	if err != nil {
+		return discord.Message{}, err
+	}
+
+	s.dispatch(ev)
+	return ev.Message, nil
+}
+
+func (m *model) editMessage(chID discord.ChannelID, id discord.MessageID, content string) (*gateway.MessageUpdateEvent, error) {
+	i, err := m.messageIndex(chID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &m.messages[chID][i]
+	msg.Content = content
+	msg.EditedTimestamp = discord.NowTimestamp()
+
+	return &gateway.MessageUpdateEvent{
+		Message: *msg,
+		Member:  m.member(chID, msg.Author.ID),
+	}, nil
+}
+
+// DeleteMessage deletes the message. Connected clients receive a Message Delete
+// event.
+func (s *Server) DeleteMessage(chID discord.ChannelID, id discord.MessageID) error {
+	s.mu.Lock()
+	ev, err := s.model.deleteMessage(chID, id)
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	s.dispatch(ev)
+	return nil
+}
+
+func (m *model) deleteMessage(chID discord.ChannelID, id discord.MessageID) (*gateway.MessageDeleteEvent, error) {
+	i, err := m.messageIndex(chID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := m.messages[chID][i]
+	m.messages[chID] = slices.Delete(m.messages[chID], i, i+1)
+
+	return &gateway.MessageDeleteEvent{
+		ID:        msg.ID,
+		ChannelID: msg.ChannelID,
+		GuildID:   msg.GuildID,
+	}, nil
+}
+
+// react adds or removes the logged-in user's reaction to the message.
+func (m *model) react(chID discord.ChannelID, id discord.MessageID, emoji discord.Emoji, add bool) error {
+	i, err := m.messageIndex(chID, id)
+	if err != nil {
+		return err
+	}
+
+	msg := &m.messages[chID][i]
+
+	j := slices.IndexFunc(msg.Reactions, func(r discord.Reaction) bool {
+		return r.Emoji.APIString() == emoji.APIString()
+	})
+
+	switch {
+	case add && j == -1:
+		msg.Reactions = append(msg.Reactions, discord.Reaction{
+			Count: 1,
+			Me:    true,
+			Emoji: emoji,
+		})
+	case add && !msg.Reactions[j].Me:
+		msg.Reactions[j].Count++
+		msg.Reactions[j].Me = true
+	case !add && j != -1 && msg.Reactions[j].Me:
+		msg.Reactions[j].Count--
+		msg.Reactions[j].Me = false
+		if msg.Reactions[j].Count == 0 {
+			msg.Reactions = slices.Delete(msg.Reactions, j, j+1)
+		}
+	}
+
+	return nil
+}
+
+// Messages returns a copy of all messages in the channel, oldest first.
+func (s *Server) Messages(chID discord.ChannelID) []discord.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.model.messages[chID])
+}
+
+// Message returns a copy of the message with the given ID.
+func (s *Server) Message(chID discord.ChannelID, id discord.MessageID) (discord.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.model.messageIndex(chID, id)
+	if err != nil {
+		return discord.Message{}, err
+	}
+
+	return s.model.messages[chID][i], nil
+}
+
+// apiError is an error in the format that Discord's API returns.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *apiError) Error() string {
+	return fmt.Sprintf("%d: %s", err.Code, err.Message)
+}
+
+var (
+	errUnauthorized   = &apiError{Status: 401, Code: 0, Message: "401: Unauthorized"}
+	errUnknownGuild   = &apiError{Status: 404, Code: 10004, Message: "Unknown Guild"}
+	errUnknownChannel = &apiError{Status: 404, Code: 10003, Message: "Unknown Channel"}
+	errUnknownMessage = &apiError{Status: 404, Code: 10008, Message: "Unknown Message"}
+	errUnknownUser    = &apiError{Status: 404, Code: 10013, Message: "Unknown User"}
+	errEmptyMessage   = &apiError{Status: 400, Code: 50006, Message: "Cannot send an empty message"}
+)
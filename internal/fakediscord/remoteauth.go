@@ -0,0 +1,300 @@
+package fakediscord
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"libdb.so/dissent/internal/remoteauth"
+)
+
+// RemoteAuthTimeout is how long a remote auth session may wait for its QR code
+// to be scanned before the server closes it.
+var RemoteAuthTimeout = 2 * time.Minute
+
+// remoteAuthSession is a single websocket connection to the fake remote auth
+// gateway.
+type remoteAuthSession struct {
+	conn *websocket.Conn
+	wmu  sync.Mutex // guards writes to conn
+
+	// These fields are guarded by Server.mu. key never changes once the
+	// session is in Server.remoteAuths.
+	key         *rsa.PublicKey
+	nonce       []byte
+	fingerprint string
+}
+
+var (
+	errUnknownFingerprint = errors.New("no pending remote auth with that fingerprint")
+	errInvalidTicket      = &apiError{Status: 400, Code: 50035, Message: "Invalid ticket"}
+)
+
+// RemoteAuthURL returns the websocket URL of the remote auth gateway, which is
+// meant to replace remoteauth.DefaultGatewayURL.
+func (s *Server) RemoteAuthURL() string {
+	return "ws" + strings.TrimPrefix(s.URL(), "http") + "/remote-auth"
+}
+
+// PendingRemoteAuths returns the fingerprints of all remote auth sessions that
+// are showing a QR code. The fingerprint is the part of the QR code URL after
+// remoteauth.QRCodeURLPrefix.
+func (s *Server) PendingRemoteAuths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprints := make([]string, 0, len(s.remoteAuths))
+	for fingerprint := range s.remoteAuths {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints
+}
+
+// ApproveRemoteAuth acts as if the logged-in user scanned the QR code with the
+// given fingerprint and confirmed the login. The client then receives the
+// server's Token.
+func (s *Server) ApproveRemoteAuth(fingerprint string) error {
+	s.mu.Lock()
+	session, ok := s.remoteAuths[fingerprint]
+	me := s.model.me
+	s.mu.Unlock()
+
+	if !ok {
+		return errUnknownFingerprint
+	}
+
+	payload := fmt.Sprintf("%s:%s:%s:%s", me.ID, me.Discriminator, me.Avatar, me.Username)
+
+	encryptedPayload, err := session.encrypt([]byte(payload))
+	if err != nil {
+		return err
+	}
+
+	session.write(remoteauth.Message{
+		Op:                   remoteauth.OpPendingTicket,
+		EncryptedUserPayload: encryptedPayload,
+	})
+
+	var b [16]byte
+	rand.Read(b[:])
+	ticket := hex.EncodeToString(b[:])
+
+	s.mu.Lock()
+	s.remoteTickets[ticket] = session
+	s.mu.Unlock()
+
+	session.write(remoteauth.Message{
+		Op:     remoteauth.OpPendingLogin,
+		Ticket: ticket,
+	})
+
+	return nil
+}
+
+// CancelRemoteAuth acts as if the user cancelled the login with the given
+// fingerprint on their phone.
+func (s *Server) CancelRemoteAuth(fingerprint string) error {
+	s.mu.Lock()
+	session, ok := s.remoteAuths[fingerprint]
+	s.mu.Unlock()
+
+	if !ok {
+		return errUnknownFingerprint
+	}
+
+	session.write(remoteauth.Message{Op: remoteauth.OpCancel})
+	session.close(closeNormal)
+	return nil
+}
+
+func (s *Server) serveRemoteAuth(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn(
+			"fake remote auth gateway cannot upgrade connection",
+			"err", err)
+		return
+	}
+
+	session := &remoteAuthSession{conn: conn}
+
+	timeout := time.AfterFunc(RemoteAuthTimeout, func() {
+		session.close(remoteauth.CloseTimeout)
+	})
+
+	defer func() {
+		timeout.Stop()
+
+		s.mu.Lock()
+		delete(s.remoteAuths, session.fingerprint)
+		for ticket, ticketSession := range s.remoteTickets {
+			if ticketSession == session {
+				delete(s.remoteTickets, ticket)
+			}
+		}
+		s.mu.Unlock()
+
+		conn.Close()
+	}()
+
+	session.write(remoteauth.Message{
+		Op:                remoteauth.OpHello,
+		HeartbeatInterval: int(HeartbeatInterval.Milliseconds()),
+		TimeoutMs:         int(RemoteAuthTimeout.Milliseconds()),
+	})
+
+	for {
+		var msg remoteauth.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		if err := s.handleRemoteAuth(session, msg); err != nil {
+			slog.Warn(
+				"fake remote auth gateway cannot handle message",
+				"op", msg.Op,
+				"err", err)
+			session.close(4000)
+			return
+		}
+	}
+}
+
+func (s *Server) handleRemoteAuth(session *remoteAuthSession, msg remoteauth.Message) error {
+	switch msg.Op {
+	case remoteauth.OpHeartbeat:
+		session.write(remoteauth.Message{Op: remoteauth.OpHeartbeatAck})
+
+	case remoteauth.OpInit:
+		der, err := base64.StdEncoding.DecodeString(msg.EncodedPublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %w", err)
+		}
+
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return fmt.Errorf("invalid public key: %w", err)
+		}
+
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("public key is %T, not RSA", pub)
+		}
+
+		nonce := make([]byte, 32)
+		rand.Read(nonce)
+
+		s.mu.Lock()
+		session.key = key
+		session.nonce = nonce
+		session.fingerprint = remoteauth.Fingerprint(der)
+		s.mu.Unlock()
+
+		encryptedNonce, err := session.encrypt(nonce)
+		if err != nil {
+			return err
+		}
+
+		session.write(remoteauth.Message{
+			Op:             remoteauth.OpNonceProof,
+			EncryptedNonce: encryptedNonce,
+		})
+
+	case remoteauth.OpNonceProof:
+		s.mu.Lock()
+		nonce := session.nonce
+		fingerprint := session.fingerprint
+		s.mu.Unlock()
+
+		if nonce == nil {
+			return errors.New("got nonce proof before init")
+		}
+
+		if msg.Proof != remoteauth.Proof(nonce) {
+			return errors.New("invalid nonce proof")
+		}
+
+		s.mu.Lock()
+		s.remoteAuths[fingerprint] = session
+		s.mu.Unlock()
+
+		session.write(remoteauth.Message{
+			Op:          remoteauth.OpPendingRemoteInit,
+			Fingerprint: fingerprint,
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) postRemoteAuthLogin(w http.ResponseWriter, r *http.Request) {
+	var body remoteauth.TicketLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	session, ok := s.remoteTickets[body.Ticket]
+	delete(s.remoteTickets, body.Ticket)
+	token := s.Token
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, errInvalidTicket)
+		return
+	}
+
+	if token == "" {
+		token = "fake-token"
+	}
+
+	encryptedToken, err := session.encrypt([]byte(token))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, remoteauth.TicketLoginResponse{EncryptedToken: encryptedToken})
+}
+
+func (s *remoteAuthSession) encrypt(plaintext []byte) (string, error) {
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, s.key, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *remoteAuthSession) write(msg remoteauth.Message) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.conn.WriteJSON(msg); err != nil {
+		slog.Debug(
+			"fake remote auth gateway cannot write to session",
+			"err", err)
+	}
+}
+
+func (s *remoteAuthSession) close(code int) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	msg := websocket.FormatCloseMessage(code, "")
+	s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	s.conn.Close()
+}
@@ -0,0 +1,363 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+)
+
+func (s *Server) routeREST(mux *http.ServeMux) {
+	routes := map[string]http.HandlerFunc{
+		"GET /gateway":          s.getGateway,
+		"GET /users/@me":        s.getMe,
+		"GET /users/@me/guilds": s.getMyGuilds,
+
+		"GET /auth/sessions":         s.getAuthSessions,
+		"POST /auth/sessions/logout": s.postSessionsLogout,
+		"POST /auth/logout":          s.postLogout,
+
+		"GET /channels/{channelID}":                                               s.getChannel,
+		"GET /channels/{channelID}/messages":                                      s.getMessages,
+		"POST /channels/{channelID}/messages":                                     s.postMessage,
+		"GET /channels/{channelID}/messages/{messageID}":                          s.getMessage,
+		"PATCH /channels/{channelID}/messages/{messageID}":                        s.patchMessage,
+		"DELETE /channels/{channelID}/messages/{messageID}":                       s.deleteMessage,
+		"POST /channels/{channelID}/messages/{messageID}/ack":                     s.ackMessage,
+		"PUT /channels/{channelID}/messages/{messageID}/reactions/{emoji}/@me":    s.putReaction,
+		"DELETE /channels/{channelID}/messages/{messageID}/reactions/{emoji}/@me": s.deleteReaction,
+		"POST /channels/{channelID}/typing":                                       s.postTyping,
+	}
+
+	for pattern, handler := range routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		mux.Handle(method+" "+api.Path+path, s.authorize(handler))
+	}
+
+	// The client has no token yet when it exchanges its remote auth ticket.
+	mux.HandleFunc("POST "+api.Path+"/users/@me/remote-auth/login", s.postRemoteAuthLogin)
+
+	// Everything else is not implemented. Make that obvious in the logs.
+	mux.HandleFunc(api.Path+"/", func(w http.ResponseWriter, r *http.Request) {
+		slog.Warn(
+			"fake Discord server got request for unimplemented route",
+			"method", r.Method,
+			"path", r.URL.Path)
+		writeError(w, &apiError{Status: 404, Code: 0, Message: "404: Not Found"})
+	})
+}
+
+func (s *Server) authorize(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The gateway endpoint doesn't require authorization.
+		if r.URL.Path != api.Path+"/gateway" && !s.tokenValid(r.Header.Get("Authorization")) {
+			writeError(w, errUnauthorized)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn(
+			"fake Discord server cannot encode response",
+			"err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = &apiError{Status: 400, Code: 50035, Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+func pathSnowflake(r *http.Request, name string) discord.Snowflake {
+	id, _ := discord.ParseSnowflake(r.PathValue(name))
+	return id
+}
+
+func (s *Server) getGateway(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, api.BotData{URL: s.GatewayURL()})
+}
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	me := s.model.me
+	s.mu.Unlock()
+
+	writeJSON(w, me)
+}
+
+func (s *Server) getMyGuilds(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	guilds := make([]discord.Guild, 0, len(s.model.guilds))
+	for _, id := range s.model.guilds {
+		guilds = append(guilds, s.model.guildMap[id].guild)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, guilds)
+}
+
+func (s *Server) getChannel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ch, err := s.model.channel(discord.ChannelID(pathSnowflake(r, "channelID")))
+	var chCopy discord.Channel
+	if ch != nil {
+		chCopy = *ch
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, chCopy)
+}
+
+func (s *Server) getMessages(w http.ResponseWriter, r *http.Request) {
+	chID := discord.ChannelID(pathSnowflake(r, "channelID"))
+
+	q := r.URL.Query()
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	before, _ := discord.ParseSnowflake(q.Get("before"))
+	after, _ := discord.ParseSnowflake(q.Get("after"))
+
+	s.mu.Lock()
+	_, err := s.model.channel(chID)
+	all := s.model.messages[chID]
+
+	// Discord returns messages newest first.
+	msgs := make([]discord.Message, 0, min(limit, len(all)))
+	for i := len(all) - 1; i >= 0 && len(msgs) < limit; i-- {
+		id := discord.Snowflake(all[i].ID)
+		if before.IsValid() && id >= before {
+			continue
+		}
+		if after.IsValid() && id <= after {
+			continue
+		}
+		msgs = append(msgs, all[i])
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, msgs)
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := s.Message(
+		discord.ChannelID(pathSnowflake(r, "channelID")),
+		discord.MessageID(pathSnowflake(r, "messageID")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, msg)
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request) {
+	var data api.SendMessageData
+	if err := decodeMessageBody(r, &data); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if data.Content == "" && len(data.Files) == 0 {
+		writeError(w, errEmptyMessage)
+		return
+	}
+
+	s.mu.Lock()
+	ev, err := s.model.createMessage(
+		discord.ChannelID(pathSnowflake(r, "channelID")),
+		s.model.me, data.Content, data.Nonce)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.dispatch(ev)
+	writeJSON(w, ev.Message)
+}
+
+// decodeMessageBody decodes either a JSON body or the payload_json field of a
+// multipart body. Attachments themselves are ignored.
+func decodeMessageBody(r *http.Request, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(r.FormValue("payload_json")), v)
+}
+
+func (s *Server) patchMessage(w http.ResponseWriter, r *http.Request) {
+	var data api.EditMessageData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	chID := discord.ChannelID(pathSnowflake(r, "channelID"))
+	msgID := discord.MessageID(pathSnowflake(r, "messageID"))
+
+	if data.Content == nil {
+		// Nothing to change.
+		s.getMessage(w, r)
+		return
+	}
+
+	msg, err := s.EditMessage(chID, msgID, data.Content.Val)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, msg)
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	err := s.DeleteMessage(
+		discord.ChannelID(pathSnowflake(r, "channelID")),
+		discord.MessageID(pathSnowflake(r, "messageID")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ackMessage(w http.ResponseWriter, r *http.Request) {
+	chID := discord.ChannelID(pathSnowflake(r, "channelID"))
+	msgID := discord.MessageID(pathSnowflake(r, "messageID"))
+
+	s.mu.Lock()
+	_, err := s.model.messageIndex(chID, msgID)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, api.Ack{})
+}
+
+func (s *Server) putReaction(w http.ResponseWriter, r *http.Request) {
+	s.react(w, r, true)
+}
+
+func (s *Server) deleteReaction(w http.ResponseWriter, r *http.Request) {
+	s.react(w, r, false)
+}
+
+func (s *Server) react(w http.ResponseWriter, r *http.Request, add bool) {
+	chID := discord.ChannelID(pathSnowflake(r, "channelID"))
+	msgID := discord.MessageID(pathSnowflake(r, "messageID"))
+	emoji := parseAPIEmoji(r.PathValue("emoji"))
+
+	s.mu.Lock()
+	err := s.model.react(chID, msgID, emoji, add)
+	ch := s.model.channels[chID]
+	me := s.model.me
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if add {
+		s.dispatch(&gateway.MessageReactionAddEvent{
+			UserID:    me.ID,
+			ChannelID: chID,
+			MessageID: msgID,
+			Emoji:     emoji,
+			GuildID:   ch.GuildID,
+		})
+	} else {
+		s.dispatch(&gateway.MessageReactionRemoveEvent{
+			UserID:    me.ID,
+			ChannelID: chID,
+			MessageID: msgID,
+			Emoji:     emoji,
+			GuildID:   ch.GuildID,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAPIEmoji parses the emoji in the format of discord.APIEmoji, which is
+// either a Unicode emoji or "name:id".
+func parseAPIEmoji(s string) discord.Emoji {
+	name, id, ok := strings.Cut(s, ":")
+	if !ok {
+		return discord.Emoji{Name: s}
+	}
+
+	sf, _ := discord.ParseSnowflake(id)
+	return discord.Emoji{ID: discord.EmojiID(sf), Name: name}
+}
+
+func (s *Server) postTyping(w http.ResponseWriter, r *http.Request) {
+	chID := discord.ChannelID(pathSnowflake(r, "channelID"))
+
+	s.mu.Lock()
+	ch, err := s.model.channel(chID)
+	var guildID discord.GuildID
+	if ch != nil {
+		guildID = ch.GuildID
+	}
+	me := s.model.me
+	member := s.model.member(chID, me.ID)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.dispatch(&gateway.TypingStartEvent{
+		ChannelID: chID,
+		UserID:    me.ID,
+		Timestamp: discord.UnixTimestamp(discord.NowTimestamp().Time().Unix()),
+		GuildID:   guildID,
+		Member:    member,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+// Package fakediscord implements a local stand-in for Discord's REST API and
+// gateway. It keeps a small in-memory model of guilds, channels and messages
+// that can be scripted, and it speaks just enough of the protocol for the real
+// arikawa client to log in, receive events and send, edit, react to and delete
+// messages. It also stands in for the login endpoints and the remote auth
+// gateway used by QR code logins. It is meant for integration testing without any network access.
+package fakediscord
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/pkg/errors"
+)
+
+// Server is a fake Discord server. It serves both the REST API and the gateway
+// on the same address. All methods are safe to call concurrently.
+type Server struct {
+	// Token is the token that clients must use to authenticate. If empty, any
+	// token is accepted.
+	Token string
+
+	mu       sync.Mutex
+	model    model
+	sessions map[*gatewaySession]struct{}
+
+	remoteAuths   map[string]*remoteAuthSession // by fingerprint
+	remoteTickets map[string]*remoteAuthSession
+
+	passwordLogin *PasswordLogin
+	mfaTickets    map[string]struct{}
+	revoked       bool
+
+	http     *http.Server
+	listener net.Listener
+}
+
+// New creates a new Server with the given user as the logged-in user. The
+// server is not started until Start is called.
+func New(token string, me discord.User) *Server {
+	s := &Server{
+		Token:    token,
+		model:    newModel(me),
+		sessions: make(map[*gatewaySession]struct{}),
+
+		remoteAuths:   make(map[string]*remoteAuthSession),
+		remoteTickets: make(map[string]*remoteAuthSession),
+		mfaTickets:    make(map[string]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /gateway", s.serveGateway)
+	mux.HandleFunc("GET /remote-auth", s.serveRemoteAuth)
+	s.routeREST(mux)
+	s.routeAuth(mux)
+
+	s.http = &http.Server{Handler: mux}
+	return s
+}
+
+// Start starts listening on the given address, such as "127.0.0.1:0". Use URL
+// to get the actual address that the server is listening on.
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "cannot listen")
+	}
+
+	s.listener = l
+
+	go func() {
+		if err := s.http.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(
+				"fake Discord server stopped unexpectedly",
+				"err", err)
+		}
+	}()
+
+	slog.Info(
+		"fake Discord server started",
+		"url", s.URL())
+
+	return nil
+}
+
+// URL returns the base URL of the server, which is meant to replace
+// https://discord.com. It returns an empty string if the server is not
+// started.
+func (s *Server) URL() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String()
+}
+
+// GatewayURL returns the websocket URL of the gateway.
+func (s *Server) GatewayURL() string {
+	return "ws" + strings.TrimPrefix(s.URL(), "http") + "/gateway"
+}
+
+// Close closes the server and all gateway connections.
+func (s *Server) Close() error {
+	s.Disconnect(closeNormal)
+	return s.http.Shutdown(context.Background())
+}
+
+// Disconnect closes all gateway connections with the given close code. Use a
+// code such as 4000 to make clients reconnect, or 4004 to make them treat it as
+// an authentication failure.
+func (s *Server) Disconnect(code int) {
+	s.mu.Lock()
+	sessions := make([]*gatewaySession, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close(code)
+	}
+}
+
+// Sessions returns the number of gateway sessions that have identified or
+// resumed.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for session := range s.sessions {
+		if session.ready() {
+			n++
+		}
+	}
+	return n
+}
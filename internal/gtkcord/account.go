@@ -0,0 +1,207 @@
+package gtkcord
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/gotkit/app"
+)
+
+// accountStateKeys contains every AccountStateKey and AccountSingleStateKey, so
+// that ForgetAccountState can clear them and MigrateAccountState can move them.
+var accountStateKeys []interface {
+	clear(ctx context.Context, id discord.UserID)
+	migrate(ctx context.Context, id discord.UserID)
+}
+
+// AccountStateKey is like app.StateKey, except the state is kept separately
+// for each account. The account is the user of the State in the context, so
+// it must only be acquired with a context that has one.
+type AccountStateKey[StateT any] struct {
+	name string
+}
+
+// NewAccountStateKey creates a new AccountStateKey with the given name.
+func NewAccountStateKey[StateT any](name string) AccountStateKey[StateT] {
+	k := AccountStateKey[StateT]{name: name}
+	accountStateKeys = append(accountStateKeys, k)
+	return k
+}
+
+// Acquire acquires the state of the account in the given context.
+func (k AccountStateKey[StateT]) Acquire(ctx context.Context) *app.TypedState[StateT] {
+	return app.NewStateKey[StateT](accountStateTails(ctx, k.name)...).Acquire(ctx)
+}
+
+func (k AccountStateKey[StateT]) clear(ctx context.Context, id discord.UserID) {
+	state := app.NewStateKey[StateT](accountTails(id, k.name)...).Acquire(ctx)
+
+	var keys []string
+	state.EachKey(func(key string) bool {
+		keys = append(keys, key)
+		return false
+	})
+
+	for _, key := range keys {
+		state.Delete(key)
+	}
+}
+
+func (k AccountStateKey[StateT]) migrate(ctx context.Context, id discord.UserID) {
+	migrateAccountState(ctx, id, k.name)
+}
+
+// AccountSingleStateKey is like app.SingleStateKey, except the state is kept
+// separately for each account.
+type AccountSingleStateKey[StateT any] struct {
+	name string
+}
+
+// NewAccountSingleStateKey creates a new AccountSingleStateKey with the given
+// name.
+func NewAccountSingleStateKey[StateT any](name string) AccountSingleStateKey[StateT] {
+	k := AccountSingleStateKey[StateT]{name: name}
+	accountStateKeys = append(accountStateKeys, k)
+	return k
+}
+
+// Acquire acquires the state of the account in the given context.
+func (k AccountSingleStateKey[StateT]) Acquire(ctx context.Context) *app.TypedSingleState[StateT] {
+	return app.NewSingleStateKey[StateT](accountStateTails(ctx, k.name)...).Acquire(ctx)
+}
+
+// AcquireAccount acquires the state of the account with the given ID. It is
+// used before the account has logged in.
+func (k AccountSingleStateKey[StateT]) AcquireAccount(ctx context.Context, id discord.UserID) *app.TypedSingleState[StateT] {
+	return app.NewSingleStateKey[StateT](accountTails(id, k.name)...).Acquire(ctx)
+}
+
+func (k AccountSingleStateKey[StateT]) clear(ctx context.Context, id discord.UserID) {
+	k.AcquireAccount(ctx, id).Delete()
+}
+
+func (k AccountSingleStateKey[StateT]) migrate(ctx context.Context, id discord.UserID) {
+	migrateAccountState(ctx, id, k.name)
+}
+
+// ForgetAccountState clears all state that was kept for the account with the
+// given ID, such as the last opened channels and drafts. It must be called on
+// the main thread.
+func ForgetAccountState(ctx context.Context, id discord.UserID) {
+	for _, k := range accountStateKeys {
+		k.clear(ctx, id)
+	}
+}
+
+// MigrateAccountState moves the state that was shared by all accounts, from
+// before it was kept for each account, into the account with the given ID.
+// Since the shared state is removed, only the first account to log in gets
+// it. It must be called on the main thread.
+func MigrateAccountState(ctx context.Context, id discord.UserID) {
+	for _, k := range accountStateKeys {
+		k.migrate(ctx, id)
+	}
+}
+
+func migrateAccountState(ctx context.Context, id discord.UserID, name string) {
+	shared := app.AcquireState(ctx, name)
+
+	values := make(map[string]json.RawMessage)
+	shared.Each(func(key string, unmarshal func(any) bool) bool {
+		var value json.RawMessage
+		if unmarshal(&value) {
+			values[key] = value
+		}
+		return false
+	})
+
+	if len(values) == 0 {
+		return
+	}
+
+	account := app.AcquireState(ctx, accountTails(id, name)...)
+	for key, value := range values {
+		// Keep what the account already has.
+		if !account.Exists(key) {
+			account.Set(key, value)
+		}
+		shared.Delete(key)
+	}
+
+	slog.Info(
+		"moved shared state into account",
+		"state", name,
+		"account", id)
+}
+
+// unknownAccount is where the state of a State whose account is not known yet
+// is kept, such as a replayed State without a token. It is only used until the
+// gateway is ready, so that such a State doesn't mix its state with another
+// account's.
+const unknownAccount = "unknown"
+
+// accountStateTails returns the config path tails for the state with the given
+// name of the account in the context.
+func accountStateTails(ctx context.Context, name string) []string {
+	state := FromContext(ctx)
+	if state == nil {
+		panic("BUG: account state " + name + " acquired without a State")
+	}
+	return state.accountStateTails(name)
+}
+
+func (s *State) accountStateTails(name string) []string {
+	id, ok := s.accountID()
+	if !ok {
+		return []string{accountStateDir, unknownAccount, name}
+	}
+	return accountTails(id, name)
+}
+
+// accountID returns the ID of the account that the state is logged in as. It
+// comes from the token until the gateway is ready.
+func (s *State) accountID() (discord.UserID, bool) {
+	if me, _ := s.Cabinet.Me(); me != nil {
+		return me.ID, true
+	}
+	return TokenUserID(s.Client.Token)
+}
+
+// accountStateDir is the config directory that the state of each account is
+// kept in. It must not be "accounts", since that is the file that the login
+// page keeps the remembered accounts in.
+const accountStateDir = "account-state"
+
+func accountTails(id discord.UserID, name string) []string {
+	return []string{accountStateDir, id.String(), name}
+}
+
+// TokenUserID returns the ID of the user that the token belongs to. Discord
+// tokens start with the user ID in base64, so Discord doesn't need to be asked.
+func TokenUserID(token string) (discord.UserID, bool) {
+	head, _, ok := strings.Cut(strings.TrimPrefix(token, "Bot "), ".")
+	if !ok {
+		return 0, false
+	}
+
+	head = strings.TrimRight(head, "=")
+
+	b, err := base64.RawStdEncoding.DecodeString(head)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(head)
+		if err != nil {
+			return 0, false
+		}
+	}
+
+	id, err := discord.ParseSnowflake(string(b))
+	if err != nil || !id.IsValid() {
+		return 0, false
+	}
+
+	return discord.UserID(id), true
+}
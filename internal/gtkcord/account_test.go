@@ -0,0 +1,45 @@
+package gtkcord
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/ningen/v3"
+)
+
+func TestAccountStateTails(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  []string
+	}{
+		{
+			name:  "token with user ID",
+			token: base64.RawStdEncoding.EncodeToString([]byte("1234567890")) + ".abc.def",
+			want:  []string{accountStateDir, "1234567890", "drafts"},
+		},
+		{
+			name:  "empty token",
+			token: "",
+			want:  []string{accountStateDir, unknownAccount, "drafts"},
+		},
+		{
+			name:  "token without user ID",
+			token: "fake-token",
+			want:  []string{accountStateDir, unknownAccount, "drafts"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &State{State: ningen.FromState(state.New(test.token))}
+
+			got := s.accountStateTails("drafts")
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got tails %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package gtkcord
+
+import (
+	"log/slog"
+	"net/url"
+	"os"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/state"
+	"libdb.so/dissent/internal/baseurl"
+)
+
+// useBaseURL makes the state send all of its API requests to u through
+// HTTPTransport, including the one that queries the gateway URL.
+func useBaseURL(s *state.State, u *url.URL) {
+	baseurl.UseGateway(u)
+	baseurl.Use(s.Client, u, HTTPTransport)
+}
+
+// debugBaseURL returns the URL in DISSENT_DEBUG_BASE_URL, or nil if it is not
+// set or invalid.
+func debugBaseURL() *url.URL {
+	baseURL := os.Getenv("DISSENT_DEBUG_BASE_URL")
+	if baseURL == "" {
+		return nil
+	}
+
+	u, err := baseurl.Parse(baseURL)
+	if err != nil {
+		slog.Error(
+			"cannot use DISSENT_DEBUG_BASE_URL",
+			"err", err)
+		return nil
+	}
+
+	return u
+}
+
+// NewAPIClient creates an API client for requests made without a State, such
+// as logging in. Like the states returned by Wrap, it uses the User-Agent from
+// the preferences and honors DISSENT_DEBUG_BASE_URL.
+func NewAPIClient(token string) *api.Client {
+	client := api.NewClient(token)
+	client.UserAgent = identifyUserAgent.Value()
+
+	if u := debugBaseURL(); u != nil {
+		baseurl.Use(client, u, HTTPTransport)
+	}
+
+	return client
+}
@@ -0,0 +1,402 @@
+package gtkcord
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/pkg/errors"
+)
+
+var persistentCache = prefs.NewBool(false, prefs.PropMeta{
+	Name:    "Cache State on Disk",
+	Section: "Discord",
+	Description: "Keep guilds, channels, members, read states and recent messages on disk " +
+		"so that they show up immediately on startup. " +
+		"The cache is replaced with the latest data once Discord connects.",
+})
+
+const (
+	// cacheVersion is the version of the on-disk cache format. Caches with a
+	// different version are ignored.
+	cacheVersion = 1
+	// cachedMessages is the maximum number of messages that are cached per
+	// channel.
+	cachedMessages = 25
+	// cacheSaveInterval is how often the cache is saved while connected.
+	cacheSaveInterval = 5 * time.Minute
+)
+
+// CacheRestoredEvent is dispatched after the state has been populated from the
+// on-disk cache. The state can be shown to the user at this point, although it
+// is not yet connected.
+type CacheRestoredEvent struct {
+	// SavedAt is the time that the cache was saved.
+	SavedAt time.Time
+}
+
+// Op implements gateway.Event. It returns -1.
+func (*CacheRestoredEvent) Op() ws.OpCode { return -1 }
+
+// EventType implements gateway.Event. It returns an empty string.
+func (*CacheRestoredEvent) EventType() ws.EventType { return "" }
+
+// stateCache persists a snapshot of the state on disk.
+type stateCache struct {
+	// saveMu is held while saving, so that a periodic save can never write
+	// after the cache is closed or deleted.
+	saveMu sync.Mutex
+
+	mu     sync.Mutex
+	path   string
+	seed   *gateway.ReadyEvent // the Ready event made from the cache
+	extras cachedReadyExtras   // from the last live Ready event
+	live   bool                // true if a live Ready event was received
+	timer  *time.Timer
+}
+
+// cacheSnapshot is the on-disk format of the cache.
+type cacheSnapshot struct {
+	Version  int                                     `json:"version"`
+	SavedAt  time.Time                               `json:"saved_at"`
+	Ready    gateway.ReadyEvent                      `json:"ready"`
+	Extras   cachedReadyExtras                       `json:"extras"`
+	Messages map[discord.ChannelID][]discord.Message `json:"messages"`
+}
+
+// cachedReadyExtras contains the parts of gateway.ReadyEventExtras that are
+// worth caching. ReadyEventExtras itself is never marshaled.
+type cachedReadyExtras struct {
+	UserSettings      *gateway.UserSettings      `json:"user_settings,omitempty"`
+	ReadStates        []gateway.ReadState        `json:"read_states,omitempty"`
+	UserGuildSettings []gateway.UserGuildSetting `json:"user_guild_settings,omitempty"`
+	Relationships     []discord.Relationship     `json:"relationships,omitempty"`
+}
+
+// RestoreCache populates the state from the on-disk cache, if the user has
+// enabled it. It must be called after the handlers are hooked but before the
+// state is opened. Once restored, a CacheRestoredEvent is dispatched. The cache
+// is then kept up to date until the state is closed.
+//
+// RestoreCache returns nil if the cache is disabled or doesn't exist yet.
+func (s *State) RestoreCache(ctx context.Context) error {
+	if !persistentCache.Value() {
+		return nil
+	}
+
+	c := s.cache
+	c.mu.Lock()
+	c.path = cachePath(ctx, s.Client.Token)
+	c.mu.Unlock()
+
+	s.AddHandler(func(ev *gateway.ReadyEvent) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if ev == c.seed {
+			return
+		}
+
+		c.live = true
+		c.extras = cachedReadyExtras{
+			UserSettings:      ev.UserSettings,
+			ReadStates:        ev.ReadStates,
+			UserGuildSettings: ev.UserGuildSettings,
+			Relationships:     ev.Relationships,
+		}
+
+		c.startSaving(cacheSaveInterval, s.saveCachePeriodically)
+	})
+
+	snapshot, err := readCacheSnapshot(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	ready := &snapshot.Ready
+	ready.ReadyEventExtras = gateway.ReadyEventExtras{
+		UserSettings:      snapshot.Extras.UserSettings,
+		ReadStates:        snapshot.Extras.ReadStates,
+		UserGuildSettings: snapshot.Extras.UserGuildSettings,
+		Relationships:     snapshot.Extras.Relationships,
+	}
+
+	c.mu.Lock()
+	c.seed = ready
+	c.extras = snapshot.Extras
+	c.mu.Unlock()
+
+	// Feed the cached Ready event through the same path that the gateway
+	// uses. This populates the cabinet as well as all of ningen's states. The
+	// next live Ready event resets all of it.
+	s.Session.Handler.Call(ready)
+
+	for _, msgs := range snapshot.Messages {
+		for i := range msgs {
+			s.Cabinet.MessageSet(&msgs[i], false)
+		}
+	}
+
+	slog.Info(
+		"restored state from on-disk cache",
+		"path", c.path,
+		"saved_at", snapshot.SavedAt)
+
+	s.Session.Handler.Call(&CacheRestoredEvent{SavedAt: snapshot.SavedAt})
+	return nil
+}
+
+// SaveCache saves the state to the on-disk cache. It does nothing if the cache
+// is disabled or if the state has never received a live Ready event, since
+// there would be nothing new to save.
+func (s *State) SaveCache() error {
+	s.cache.saveMu.Lock()
+	defer s.cache.saveMu.Unlock()
+
+	return s.saveCache()
+}
+
+// CloseCache saves the state to the on-disk cache one last time and stops
+// saving it periodically. It must be called before the state is closed.
+func (s *State) CloseCache() error {
+	c := s.cache
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	err := s.saveCache()
+	c.stop()
+	return err
+}
+
+// DeleteCache deletes the on-disk cache of the state, such as when logging out.
+// The cache is not saved again afterwards, even if it is enabled.
+func (s *State) DeleteCache(ctx context.Context) error {
+	c := s.cache
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.stop()
+
+	if err := os.Remove(cachePath(ctx, s.Client.Token)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "cannot delete cache")
+	}
+
+	return nil
+}
+
+// saveCache is SaveCache without holding saveMu.
+func (s *State) saveCache() error {
+	c := s.cache
+	c.mu.Lock()
+	path := c.path
+	live := c.live
+	extras := c.extras
+	c.mu.Unlock()
+
+	if path == "" || !live || !persistentCache.Value() {
+		return nil
+	}
+
+	snapshot, err := s.cacheSnapshot(extras)
+	if err != nil {
+		return errors.Wrap(err, "cannot snapshot state")
+	}
+
+	if err := writeCacheSnapshot(path, snapshot); err != nil {
+		return err
+	}
+
+	slog.Debug(
+		"saved state to on-disk cache",
+		"path", path)
+
+	return nil
+}
+
+func (s *State) saveCachePeriodically() {
+	if err := s.saveCache(); err != nil {
+		slog.Warn(
+			"cannot save state to on-disk cache",
+			"err", err)
+	}
+}
+
+// startSaving calls save every interval until the cache is stopped. save is
+// called with saveMu held. It does nothing if the cache has already been
+// started or stopped. c.mu must be held.
+func (c *stateCache) startSaving(interval time.Duration, save func()) {
+	if c.timer != nil || c.path == "" {
+		return
+	}
+
+	c.timer = time.AfterFunc(interval, func() {
+		c.saveMu.Lock()
+		c.mu.Lock()
+		stopped := c.path == ""
+		c.mu.Unlock()
+
+		if !stopped {
+			save()
+		}
+		c.saveMu.Unlock()
+
+		c.mu.Lock()
+		if c.path != "" {
+			c.timer.Reset(interval)
+		}
+		c.mu.Unlock()
+	})
+}
+
+// stop stops the cache from being saved again. saveMu must be held, so that a
+// save in progress is waited for.
+func (c *stateCache) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.path = ""
+	c.live = false
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+func (s *State) cacheSnapshot(extras cachedReadyExtras) (*cacheSnapshot, error) {
+	me, err := s.Cabinet.Me()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get self")
+	}
+
+	snapshot := &cacheSnapshot{
+		Version:  cacheVersion,
+		SavedAt:  time.Now(),
+		Extras:   extras,
+		Messages: make(map[discord.ChannelID][]discord.Message),
+	}
+	snapshot.Ready.User = *me
+
+	// Read states change all the time, so get them from the state instead of
+	// the Ready event.
+	snapshot.Extras.ReadStates = nil
+
+	addChannel := func(ch *discord.Channel) {
+		if rs := s.ReadState.ReadState(ch.ID); rs != nil {
+			snapshot.Extras.ReadStates = append(snapshot.Extras.ReadStates, *rs)
+		}
+
+		msgs, _ := s.Cabinet.Messages(ch.ID)
+		if len(msgs) > 0 {
+			// Messages are sorted from newest to oldest.
+			snapshot.Messages[ch.ID] = msgs[:min(len(msgs), cachedMessages)]
+		}
+	}
+
+	privates, err := s.Cabinet.PrivateChannels()
+	if err == nil {
+		snapshot.Ready.PrivateChannels = privates
+		for i := range privates {
+			addChannel(&privates[i])
+		}
+	}
+
+	guilds, err := s.Cabinet.Guilds()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get guilds")
+	}
+
+	snapshot.Ready.Guilds = make([]gateway.GuildCreateEvent, 0, len(guilds))
+	for _, guild := range guilds {
+		ev := gateway.GuildCreateEvent{Guild: guild}
+		ev.Roles, _ = s.Cabinet.Roles(guild.ID)
+		ev.Emojis, _ = s.Cabinet.Emojis(guild.ID)
+		ev.Members, _ = s.Cabinet.Members(guild.ID)
+		ev.Channels, _ = s.Cabinet.Channels(guild.ID)
+
+		for i := range ev.Channels {
+			addChannel(&ev.Channels[i])
+		}
+
+		snapshot.Ready.Guilds = append(snapshot.Ready.Guilds, ev)
+	}
+
+	return snapshot, nil
+}
+
+// cachePath returns the path to the cache file for the account with the given
+// token. The token itself is never written to disk.
+func cachePath(ctx context.Context, token string) string {
+	hash := sha256.Sum256([]byte(token))
+	name := hex.EncodeToString(hash[:8]) + ".json.gz"
+	return app.FromContext(ctx).CachePath("state", name)
+}
+
+func readCacheSnapshot(path string) (*cacheSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read cache")
+	}
+
+	var snapshot cacheSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, errors.Wrap(err, "cannot decode cache")
+	}
+
+	if snapshot.Version != cacheVersion {
+		return nil, errors.Errorf(
+			"cache version %d is not supported (want %d)",
+			snapshot.Version, cacheVersion)
+	}
+
+	return &snapshot, nil
+}
+
+func writeCacheSnapshot(path string, snapshot *cacheSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "cannot create cache directory")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return errors.Wrap(err, "cannot create cache file")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return errors.Wrap(err, "cannot encode cache")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "cannot write cache")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot write cache")
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return errors.Wrap(err, "cannot commit cache")
+	}
+
+	return nil
+}
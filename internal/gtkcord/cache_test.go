@@ -0,0 +1,66 @@
+package gtkcord
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStateCacheStopsSaving(t *testing.T) {
+	const interval = time.Millisecond
+
+	var saves atomic.Int32
+
+	c := &stateCache{path: "state.json.gz"}
+	c.mu.Lock()
+	c.startSaving(interval, func() { saves.Add(1) })
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for saves.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cache was saved %d times, want at least 3", saves.Load())
+		}
+		time.Sleep(interval)
+	}
+
+	c.saveMu.Lock()
+	c.stop()
+	c.saveMu.Unlock()
+
+	stopped := saves.Load()
+	time.Sleep(50 * interval)
+
+	if n := saves.Load(); n != stopped {
+		t.Fatalf("cache was saved %d times after stopping", n-stopped)
+	}
+
+	// The cache cannot be started again once it is stopped.
+	c.mu.Lock()
+	c.startSaving(interval, func() { saves.Add(1) })
+	c.mu.Unlock()
+
+	time.Sleep(50 * interval)
+
+	if n := saves.Load(); n != stopped {
+		t.Fatalf("cache was saved %d times after restarting", n-stopped)
+	}
+}
+
+func TestStateCacheStartOnce(t *testing.T) {
+	c := &stateCache{path: "state.json.gz"}
+
+	c.mu.Lock()
+	c.startSaving(time.Hour, func() {})
+	timer := c.timer
+	c.startSaving(time.Hour, func() {})
+	c.mu.Unlock()
+
+	if c.timer != timer {
+		t.Fatal("startSaving replaced the running timer")
+	}
+
+	c.saveMu.Lock()
+	c.stop()
+	c.saveMu.Unlock()
+}
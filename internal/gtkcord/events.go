@@ -0,0 +1,207 @@
+package gtkcord
+
+import (
+	"context"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/ningen/v3/states/read"
+)
+
+// EventFilter is a filter that decides whether an event should be passed to a
+// handler added using On or OnCancellable. It returns true if the event should
+// be passed.
+type EventFilter func(ev gateway.Event) bool
+
+// ForChannel filters out events that don't belong to the given channel. Events
+// that don't carry a channel ID are also filtered out.
+func ForChannel(chID discord.ChannelID) EventFilter {
+	return func(ev gateway.Event) bool {
+		id, ok := EventChannelID(ev)
+		return ok && id == chID
+	}
+}
+
+// ForGuild filters out events that don't belong to the given guild. Events that
+// don't carry a guild ID are also filtered out.
+func ForGuild(guildID discord.GuildID) EventFilter {
+	return func(ev gateway.Event) bool {
+		id, ok := EventGuildID(ev)
+		return ok && id == guildID
+	}
+}
+
+// On adds a handler for events of type E to the state. The handler is called on
+// the main thread, and only if the event passes all the given filters. Its
+// lifetime is bound to the widget in the same way as AddHandlerForWidget. The
+// returned function unbinds the handler early.
+//
+// The event type is inferred from fn, so a typical call looks like this:
+//
+//	gtkcord.On(state, w, func(ev *gateway.MessageCreateEvent) {
+//		// ...
+//	}, gtkcord.ForChannel(chID))
+func On[T any, E interface {
+	*T
+	gateway.Event
+}](s *State, w gtk.Widgetter, fn func(E), filters ...EventFilter) func() {
+	return s.AddHandlerForWidget(w, filterHandler(fn, filters))
+}
+
+// OnCancellable is similar to On, except the lifetime of the handler is bound
+// to the given cancellable, such as one returned by gtkutil.WithVisibility.
+func OnCancellable[T any, E interface {
+	*T
+	gateway.Event
+}](s *State, ctx gtkutil.Cancellable, fn func(E), filters ...EventFilter) {
+	h := filterHandler(fn, filters)
+	site := handlerSite()
+	ctx.OnRenew(func(context.Context) func() {
+		return s.addHandler(nil, site, []any{h})
+	})
+}
+
+func filterHandler[E gateway.Event](fn func(E), filters []EventFilter) func(E) {
+	if len(filters) == 0 {
+		return fn
+	}
+	return func(ev E) {
+		for _, filter := range filters {
+			if !filter(ev) {
+				return
+			}
+		}
+		fn(ev)
+	}
+}
+
+// EventChannelID returns the ID of the channel that the event belongs to. It
+// returns false if the event doesn't carry a channel ID.
+func EventChannelID(ev gateway.Event) (discord.ChannelID, bool) {
+	switch ev := ev.(type) {
+	case *gateway.MessageCreateEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageUpdateEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageDeleteEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageDeleteBulkEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageReactionAddEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageReactionRemoveEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageReactionRemoveAllEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageReactionRemoveEmojiEvent:
+		return ev.ChannelID, true
+	case *gateway.MessageAckEvent:
+		return ev.ChannelID, true
+	case *gateway.TypingStartEvent:
+		return ev.ChannelID, true
+	case *gateway.ChannelCreateEvent:
+		return ev.ID, true
+	case *gateway.ChannelUpdateEvent:
+		return ev.ID, true
+	case *gateway.ChannelDeleteEvent:
+		return ev.ID, true
+	case *gateway.ChannelPinsUpdateEvent:
+		return ev.ChannelID, true
+	case *gateway.ThreadCreateEvent:
+		return ev.ID, true
+	case *gateway.ThreadUpdateEvent:
+		return ev.ID, true
+	case *gateway.ThreadDeleteEvent:
+		return ev.ID, true
+	case *gateway.InviteCreateEvent:
+		return ev.ChannelID, true
+	case *gateway.InviteDeleteEvent:
+		return ev.ChannelID, true
+	case *gateway.WebhooksUpdateEvent:
+		return ev.ChannelID, true
+	case *gateway.ConversationSummaryUpdateEvent:
+		return ev.ChannelID, true
+	case *read.UpdateEvent:
+		return ev.ChannelID, true
+	default:
+		return 0, false
+	}
+}
+
+// EventGuildID returns the ID of the guild that the event belongs to. It
+// returns false if the event doesn't carry a guild ID. Note that events in
+// direct messages may carry an invalid guild ID.
+func EventGuildID(ev gateway.Event) (discord.GuildID, bool) {
+	switch ev := ev.(type) {
+	case *gateway.MessageCreateEvent:
+		return ev.GuildID, true
+	case *gateway.MessageUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.MessageDeleteEvent:
+		return ev.GuildID, true
+	case *gateway.MessageDeleteBulkEvent:
+		return ev.GuildID, true
+	case *gateway.MessageReactionAddEvent:
+		return ev.GuildID, true
+	case *gateway.MessageReactionRemoveEvent:
+		return ev.GuildID, true
+	case *gateway.MessageReactionRemoveAllEvent:
+		return ev.GuildID, true
+	case *gateway.MessageReactionRemoveEmojiEvent:
+		return ev.GuildID, true
+	case *gateway.TypingStartEvent:
+		return ev.GuildID, true
+	case *gateway.ChannelCreateEvent:
+		return ev.GuildID, true
+	case *gateway.ChannelUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.ChannelDeleteEvent:
+		return ev.GuildID, true
+	case *gateway.ChannelPinsUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.ThreadCreateEvent:
+		return ev.GuildID, true
+	case *gateway.ThreadUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.ThreadDeleteEvent:
+		return ev.GuildID, true
+	case *gateway.InviteCreateEvent:
+		return ev.GuildID, true
+	case *gateway.InviteDeleteEvent:
+		return ev.GuildID, true
+	case *gateway.WebhooksUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.ConversationSummaryUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.GuildCreateEvent:
+		return ev.ID, true
+	case *gateway.GuildUpdateEvent:
+		return ev.ID, true
+	case *gateway.GuildDeleteEvent:
+		return ev.ID, true
+	case *gateway.GuildMemberAddEvent:
+		return ev.GuildID, true
+	case *gateway.GuildMemberUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.GuildMemberRemoveEvent:
+		return ev.GuildID, true
+	case *gateway.GuildMembersChunkEvent:
+		return ev.GuildID, true
+	case *gateway.GuildRoleCreateEvent:
+		return ev.GuildID, true
+	case *gateway.GuildRoleUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.GuildRoleDeleteEvent:
+		return ev.GuildID, true
+	case *gateway.GuildEmojisUpdateEvent:
+		return ev.GuildID, true
+	case *gateway.PresenceUpdateEvent:
+		return ev.GuildID, true
+	case *read.UpdateEvent:
+		return ev.GuildID, true
+	default:
+		return 0, false
+	}
+}
@@ -3,27 +3,63 @@ package gtkcord
 import (
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/utils/handler"
 	"github.com/diamondburned/gotk4/pkg/core/glib"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/ningen/v3/states/read"
 )
 
+var eventFrameBudget = prefs.NewInt(8, prefs.IntMeta{
+	Name:    "Event Frame Budget",
+	Section: "Discord",
+	Description: "The time in milliseconds that handling Discord events may take " +
+		"before the window gets to redraw. Lower values keep the window responsive " +
+		"during bursts of events, while higher values catch up with them faster.",
+	Min: 0,
+	Max: 100,
+})
+
 // MainThreadHandler wraps a [handler.Handler] to run all events on the main
 // thread.
+//
+// Events are queued up and dispatched in frames: each frame is a single idle
+// callback that calls handlers until the frame budget is used up, after which
+// the rest of the queue is left for the next main loop iteration. This lets
+// GTK draw and handle input in between, even during event storms. Events that
+// only carry the latest state of an entity, such as presence or read state
+// updates, are coalesced while they're still queued, so only the latest one
+// is dispatched.
 type MainThreadHandler struct {
-	h *handler.Handler
+	h      *handler.Handler
+	budget atomic.Int64 // time.Duration, or -1 to use eventFrameBudget
+
+	mu        sync.Mutex
+	queue     []*queuedEvent
+	pending   map[coalesceKey]*queuedEvent
+	scheduled bool
+}
+
+type queuedEvent struct {
+	callers []handler.Caller
+	value   reflect.Value
+	key     coalesceKey
 }
 
 // NewMainThreadHandler creates a new MainThreadHandler.
 func NewMainThreadHandler(h *handler.Handler) *MainThreadHandler {
 	m := &MainThreadHandler{
-		h: handler.New(),
-	}
-	p := &sync.Pool{
-		New: func() any { return make([]handler.Caller, 0, 10) },
+		h:       handler.New(),
+		pending: make(map[coalesceKey]*queuedEvent),
 	}
+	m.budget.Store(-1)
+
 	h.AddSyncHandler(func(ev any) {
-		callers := p.Get().([]handler.Caller)
+		var callers []handler.Caller
 
 		all := m.h.AllCallersForType(reflect.TypeOf(ev))
 		all(func(c handler.Caller) bool {
@@ -32,26 +68,104 @@ func NewMainThreadHandler(h *handler.Handler) *MainThreadHandler {
 		})
 
 		if len(callers) == 0 {
-			p.Put(callers)
 			return
 		}
 
-		v := reflect.ValueOf(ev)
-		glib.IdleAddPriority(glib.PriorityHighIdle, func() {
-			for _, c := range callers {
-				c.Call(v)
-			}
-
-			// Return the callers to the pool.
-			for i := range callers {
-				callers[i] = nil // avoid memory leaks
-			}
-			p.Put(callers[:0])
-		})
+		m.enqueue(ev, callers)
 	})
 	return m
 }
 
+// FrameBudget returns the maximum amount of time that handlers may take in a
+// single main loop iteration. Unless it is set using SetFrameBudget, it is the
+// one in the preferences.
+func (m *MainThreadHandler) FrameBudget() time.Duration {
+	if budget := m.budget.Load(); budget >= 0 {
+		return time.Duration(budget)
+	}
+	return time.Duration(eventFrameBudget.Value()) * time.Millisecond
+}
+
+// SetFrameBudget overrides the frame budget in the preferences. At least one
+// event is always dispatched per iteration, so a budget of 0 dispatches exactly
+// one event per iteration. A negative budget uses the preferences again.
+func (m *MainThreadHandler) SetFrameBudget(budget time.Duration) {
+	m.budget.Store(int64(max(budget, -1)))
+}
+
+func (m *MainThreadHandler) enqueue(ev any, callers []handler.Caller) {
+	key, coalesce := eventCoalesceKey(ev)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if coalesce {
+		if queued, ok := m.pending[key]; ok {
+			// Replace the queued event with the newer one while keeping its
+			// place in the queue.
+			queued.value = reflect.ValueOf(ev)
+			queued.callers = callers
+			return
+		}
+	}
+
+	queued := &queuedEvent{
+		callers: callers,
+		value:   reflect.ValueOf(ev),
+	}
+	if coalesce {
+		queued.key = key
+		m.pending[key] = queued
+	}
+	m.queue = append(m.queue, queued)
+
+	if !m.scheduled {
+		m.scheduled = true
+		glib.IdleAddPriority(glib.PriorityHighIdle, m.dispatchFrame)
+	}
+}
+
+// dispatchFrame dispatches queued events until the queue is empty or the frame
+// budget is used up. It returns true if there are still events left, which
+// keeps the idle source around for the next main loop iteration.
+func (m *MainThreadHandler) dispatchFrame() bool {
+	deadline := time.Now().Add(m.FrameBudget())
+
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 {
+			m.queue = nil
+			m.scheduled = false
+			m.mu.Unlock()
+			return false
+		}
+
+		queued := m.queue[0]
+		m.queue[0] = nil // avoid memory leaks
+		m.queue = m.queue[1:]
+
+		if queued.key != (coalesceKey{}) {
+			delete(m.pending, queued.key)
+		}
+		m.mu.Unlock()
+
+		for _, c := range queued.callers {
+			c.Call(queued.value)
+		}
+
+		if time.Now().After(deadline) {
+			m.mu.Lock()
+			more := len(m.queue) > 0
+			if !more {
+				m.queue = nil
+				m.scheduled = false
+			}
+			m.mu.Unlock()
+			return more
+		}
+	}
+}
+
 // AddHandler adds a handler to the handler.Handler.
 // The given handler will be called on the main thread.
 // The returned function will remove the handler.
@@ -64,3 +178,46 @@ func (m *MainThreadHandler) AddHandler(handler any) func() {
 func (m *MainThreadHandler) AddSyncHandler(handler any) func() {
 	return m.AddHandler(handler)
 }
+
+// coalesceKey identifies the entity that an event updates. Two queued events
+// with the same key are redundant, and only the latest one is kept.
+type coalesceKey struct {
+	typ reflect.Type
+	a   discord.Snowflake
+	b   discord.Snowflake
+}
+
+// eventCoalesceKey returns the coalesce key for the given event. It returns
+// false if the event must never be coalesced, which is the case for most
+// events, since handlers may rely on seeing each of them.
+func eventCoalesceKey(ev any) (coalesceKey, bool) {
+	key := coalesceKey{typ: reflect.TypeOf(ev)}
+
+	switch ev := ev.(type) {
+	case *gateway.PresenceUpdateEvent:
+		key.a = discord.Snowflake(ev.GuildID)
+		key.b = discord.Snowflake(ev.User.ID)
+	case *gateway.GuildMemberUpdateEvent:
+		key.a = discord.Snowflake(ev.GuildID)
+		key.b = discord.Snowflake(ev.User.ID)
+	case *gateway.TypingStartEvent:
+		key.a = discord.Snowflake(ev.ChannelID)
+		key.b = discord.Snowflake(ev.UserID)
+	case *read.UpdateEvent:
+		key.a = discord.Snowflake(ev.ChannelID)
+	case *gateway.GuildUpdateEvent:
+		key.a = discord.Snowflake(ev.ID)
+	case *gateway.ChannelUpdateEvent:
+		key.a = discord.Snowflake(ev.ID)
+	case
+		*gateway.PresencesReplaceEvent,
+		*gateway.SessionsReplaceEvent:
+		// These events replace the whole state, so there's only one entity.
+		// UserSettingsUpdateEvent is not one of them, since it only carries
+		// the settings that changed.
+	default:
+		return coalesceKey{}, false
+	}
+
+	return key, true
+}
@@ -0,0 +1,256 @@
+package gtkcord
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/handler"
+)
+
+func TestEventCoalesceKey(t *testing.T) {
+	presence := func(guildID discord.GuildID, userID discord.UserID) *gateway.PresenceUpdateEvent {
+		return &gateway.PresenceUpdateEvent{Presence: discord.Presence{
+			GuildID: guildID,
+			User:    discord.User{ID: userID},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		a, b     any
+		coalesce bool // whether a and b are coalesced together
+	}{
+		{
+			name:     "same presence",
+			a:        presence(1, 2),
+			b:        presence(1, 2),
+			coalesce: true,
+		},
+		{
+			name: "presence of another user",
+			a:    presence(1, 2),
+			b:    presence(1, 3),
+		},
+		{
+			name: "presence in another guild",
+			a:    presence(1, 2),
+			b:    presence(4, 2),
+		},
+		{
+			name:     "same member",
+			a:        &gateway.GuildMemberUpdateEvent{GuildID: 1, User: discord.User{ID: 2}},
+			b:        &gateway.GuildMemberUpdateEvent{GuildID: 1, User: discord.User{ID: 2}, Nick: "new"},
+			coalesce: true,
+		},
+		{
+			name:     "same typing user",
+			a:        &gateway.TypingStartEvent{ChannelID: 1, UserID: 2},
+			b:        &gateway.TypingStartEvent{ChannelID: 1, UserID: 2},
+			coalesce: true,
+		},
+		{
+			name: "typing in another channel",
+			a:    &gateway.TypingStartEvent{ChannelID: 1, UserID: 2},
+			b:    &gateway.TypingStartEvent{ChannelID: 3, UserID: 2},
+		},
+		{
+			name:     "same guild",
+			a:        &gateway.GuildUpdateEvent{Guild: discord.Guild{ID: 1}},
+			b:        &gateway.GuildUpdateEvent{Guild: discord.Guild{ID: 1, Name: "new"}},
+			coalesce: true,
+		},
+		{
+			name:     "same channel",
+			a:        &gateway.ChannelUpdateEvent{Channel: discord.Channel{ID: 1}},
+			b:        &gateway.ChannelUpdateEvent{Channel: discord.Channel{ID: 1, Name: "new"}},
+			coalesce: true,
+		},
+		{
+			name: "guild and channel with the same ID",
+			a:    &gateway.GuildUpdateEvent{Guild: discord.Guild{ID: 1}},
+			b:    &gateway.ChannelUpdateEvent{Channel: discord.Channel{ID: 1}},
+		},
+		{
+			name:     "presences replace",
+			a:        &gateway.PresencesReplaceEvent{},
+			b:        &gateway.PresencesReplaceEvent{},
+			coalesce: true,
+		},
+		{
+			name:     "sessions replace",
+			a:        &gateway.SessionsReplaceEvent{},
+			b:        &gateway.SessionsReplaceEvent{},
+			coalesce: true,
+		},
+		{
+			name: "user settings update",
+			a:    &gateway.UserSettingsUpdateEvent{},
+			b:    &gateway.UserSettingsUpdateEvent{},
+		},
+		{
+			name: "message create",
+			a:    &gateway.MessageCreateEvent{Message: discord.Message{ID: 1, ChannelID: 2}},
+			b:    &gateway.MessageCreateEvent{Message: discord.Message{ID: 1, ChannelID: 2}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keyA, okA := eventCoalesceKey(test.a)
+			keyB, okB := eventCoalesceKey(test.b)
+
+			coalesce := okA && okB && keyA == keyB
+			if coalesce != test.coalesce {
+				t.Errorf("coalesced = %v, want %v (keys %v, %v)", coalesce, test.coalesce, keyA, keyB)
+			}
+		})
+	}
+}
+
+// recordCaller records the values that it is called with and sleeps for the
+// given duration on each call.
+type recordCaller struct {
+	calls []any
+	sleep time.Duration
+}
+
+func (c *recordCaller) Call(ev reflect.Value) {
+	c.calls = append(c.calls, ev.Interface())
+	time.Sleep(c.sleep)
+}
+
+// newTestMainThreadHandler returns a MainThreadHandler that never schedules
+// itself on the main loop, so frames must be dispatched by calling
+// dispatchFrame.
+func newTestMainThreadHandler(budget time.Duration) *MainThreadHandler {
+	m := &MainThreadHandler{
+		pending:   make(map[coalesceKey]*queuedEvent),
+		scheduled: true,
+	}
+	m.SetFrameBudget(budget)
+	return m
+}
+
+func TestMainThreadHandlerFrameBudget(t *testing.T) {
+	m := newTestMainThreadHandler(-1)
+
+	want := time.Duration(eventFrameBudget.Value()) * time.Millisecond
+	if got := m.FrameBudget(); got != want {
+		t.Errorf("default budget is %v, want %v from the preferences", got, want)
+	}
+
+	m.SetFrameBudget(0)
+	if got := m.FrameBudget(); got != 0 {
+		t.Errorf("budget is %v after setting it to 0", got)
+	}
+
+	m.SetFrameBudget(-time.Second)
+	if got := m.FrameBudget(); got != want {
+		t.Errorf("budget is %v after resetting it, want %v", got, want)
+	}
+}
+
+func TestMainThreadHandlerDispatchFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget time.Duration
+		sleep  time.Duration
+		events int
+		frames []int // number of calls made after each frame
+	}{
+		{
+			name:   "all within budget",
+			budget: time.Hour,
+			events: 3,
+			frames: []int{3},
+		},
+		{
+			name:   "zero budget",
+			budget: 0,
+			sleep:  time.Microsecond,
+			events: 3,
+			frames: []int{1, 2, 3},
+		},
+		{
+			name:   "handlers exceed budget",
+			budget: time.Millisecond,
+			sleep:  2 * time.Millisecond,
+			events: 2,
+			frames: []int{1, 2},
+		},
+		{
+			name:   "empty queue",
+			budget: time.Hour,
+			events: 0,
+			frames: []int{0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestMainThreadHandler(test.budget)
+			c := &recordCaller{sleep: test.sleep}
+
+			for i := range test.events {
+				m.enqueue(&gateway.MessageCreateEvent{
+					Message: discord.Message{ID: discord.MessageID(i + 1)},
+				}, []handler.Caller{c})
+			}
+
+			for i, want := range test.frames {
+				more := m.dispatchFrame()
+				if len(c.calls) != want {
+					t.Fatalf("frame %d: got %d calls, want %d", i, len(c.calls), want)
+				}
+
+				last := i == len(test.frames)-1
+				if more == last {
+					t.Fatalf("frame %d: dispatchFrame returned %v", i, more)
+				}
+			}
+
+			if m.scheduled {
+				t.Error("handler is still scheduled after the queue is empty")
+			}
+
+			// Events must be dispatched in the order that they came in.
+			for i, call := range c.calls {
+				ev := call.(*gateway.MessageCreateEvent)
+				if ev.ID != discord.MessageID(i+1) {
+					t.Errorf("call %d: got message %d", i, ev.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMainThreadHandlerCoalesce(t *testing.T) {
+	m := newTestMainThreadHandler(time.Hour)
+	c := &recordCaller{}
+	callers := []handler.Caller{c}
+
+	m.enqueue(&gateway.ChannelUpdateEvent{Channel: discord.Channel{ID: 1, Name: "old"}}, callers)
+	m.enqueue(&gateway.TypingStartEvent{ChannelID: 1, UserID: 2}, callers)
+	m.enqueue(&gateway.ChannelUpdateEvent{Channel: discord.Channel{ID: 1, Name: "new"}}, callers)
+
+	if m.dispatchFrame() {
+		t.Fatal("dispatchFrame left events in the queue")
+	}
+
+	if len(c.calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(c.calls))
+	}
+
+	// The newer update takes the place of the older one in the queue.
+	ch, ok := c.calls[0].(*gateway.ChannelUpdateEvent)
+	if !ok || ch.Name != "new" {
+		t.Errorf("first call is %#v, want the newer channel update", c.calls[0])
+	}
+
+	if len(m.pending) != 0 {
+		t.Errorf("%d coalesced events are still pending", len(m.pending))
+	}
+}
@@ -0,0 +1,250 @@
+package gtkcord
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// HandlerTrackingEnabled is true if DISSENT_DEBUG_TRACK_HANDLERS is set to 1.
+// When enabled, every handler added through State is recorded along with its
+// owning widget and creation site until it is unbound.
+var HandlerTrackingEnabled = os.Getenv("DISSENT_DEBUG_TRACK_HANDLERS") == "1"
+
+var handlers = handlerTracker{
+	live: make(map[uint64]*trackedHandler),
+}
+
+type handlerTracker struct {
+	mu   sync.Mutex
+	live map[uint64]*trackedHandler
+	next uint64
+}
+
+type trackedHandler struct {
+	site       string
+	widgetType string
+	widget     *coreglib.WeakRef[gtk.Widgetter]
+	signals    []coreglib.SignalHandle
+	// unmapped is true if the widget was unmapped and not mapped again.
+	unmapped bool
+	// destroyed is true if the widget was destroyed.
+	destroyed bool
+}
+
+// track records a new handler owned by the given widget, which may be nil, and
+// added from the given site. It returns a function that removes the record.
+//
+// The widget is watched for being unmapped or destroyed while the handler is
+// still bound. Its finalization can't be watched instead, since the handler
+// usually references the widget, which keeps it alive for as long as the
+// handler is bound.
+func (t *handlerTracker) track(w gtk.Widgetter, site string) func() {
+	h := &trackedHandler{
+		site:       site,
+		widgetType: "(none)",
+	}
+
+	if w != nil {
+		h.widgetType = fmt.Sprintf("%T", w)
+		h.widget = coreglib.NewWeakRef(w)
+	}
+
+	t.mu.Lock()
+	t.next++
+	id := t.next
+	t.live[id] = h
+	t.mu.Unlock()
+
+	if w != nil {
+		base := gtk.BaseWidget(w)
+		h.signals = []coreglib.SignalHandle{
+			base.ConnectMap(func() { t.update(id, func(h *trackedHandler) { h.unmapped = false }) }),
+			base.ConnectUnmap(func() { t.update(id, func(h *trackedHandler) { h.unmapped = true }) }),
+			base.ConnectDestroy(func() {
+				t.update(id, func(h *trackedHandler) {
+					h.destroyed = true
+					slog.Warn(
+						"widget destroyed without unbinding its state handler",
+						"widget_type", h.widgetType,
+						"site", h.site)
+				})
+			}),
+		}
+	}
+
+	return func() {
+		t.mu.Lock()
+		delete(t.live, id)
+		t.mu.Unlock()
+
+		if h.widget != nil {
+			if w := h.widget.Get(); w != nil {
+				base := gtk.BaseWidget(w)
+				for _, signal := range h.signals {
+					base.HandlerDisconnect(signal)
+				}
+			}
+		}
+	}
+}
+
+// update calls fn with the handler with the given ID if it is still bound.
+func (t *handlerTracker) update(id uint64, fn func(h *trackedHandler)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if h, ok := t.live[id]; ok {
+		fn(h)
+	}
+}
+
+// handlerSite returns the site that a handler is being added from, or an empty
+// string if handler tracking is disabled.
+func handlerSite() string {
+	if !HandlerTrackingEnabled {
+		return ""
+	}
+	return callerSite()
+}
+
+// callerSite returns the file and line of the first caller outside of this
+// package.
+func callerSite() string {
+	pc := make([]uintptr, 16)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "libdb.so/dissent/internal/gtkcord.") {
+			return fmt.Sprintf("%s:%d", trimModulePath(frame.File), frame.Line)
+		}
+		if !more {
+			return "(unknown)"
+		}
+	}
+}
+
+func trimModulePath(file string) string {
+	if i := strings.Index(file, "/internal/"); i != -1 {
+		return file[i+1:]
+	}
+	return file
+}
+
+// HandlerSiteStats is the number of live handlers added from the same site.
+type HandlerSiteStats struct {
+	// Site is the file and line that added the handlers.
+	Site string
+	// WidgetType is the type of the widget that owns the handlers.
+	WidgetType string
+	// Live is the number of handlers that are still bound.
+	Live int
+	// Hidden is the number of handlers whose widget was unmapped but is still
+	// in a window, such as a page that isn't visible. These are usually fine.
+	Hidden int
+	// Leaked is the number of handlers whose widget was destroyed, or was
+	// unmapped and is no longer in any window. These are leaks.
+	Leaked int
+}
+
+// HandlerStats summarizes the handlers recorded while handler tracking is
+// enabled.
+type HandlerStats struct {
+	// TakenAt is the time the stats were taken.
+	TakenAt time.Time
+	// Live is the total number of handlers that are still bound.
+	Live int
+	// Hidden is the total number of handlers with hidden widgets.
+	Hidden int
+	// Leaked is the total number of leaked handlers.
+	Leaked int
+	// Sites contains the stats per site, sorted with the most live handlers
+	// first.
+	Sites []HandlerSiteStats
+}
+
+// TrackedHandlers returns the stats of all tracked handlers. It must be called
+// on the main thread. It returns false if handler tracking is disabled.
+func TrackedHandlers() (HandlerStats, bool) {
+	if !HandlerTrackingEnabled {
+		return HandlerStats{}, false
+	}
+	return handlers.stats(), true
+}
+
+func (t *handlerTracker) stats() HandlerStats {
+	t.mu.Lock()
+	tracked := make([]trackedHandler, 0, len(t.live))
+	for _, h := range t.live {
+		tracked = append(tracked, *h)
+	}
+	t.mu.Unlock()
+
+	stats := HandlerStats{TakenAt: time.Now()}
+	sites := make(map[[2]string]*HandlerSiteStats)
+
+	for _, h := range tracked {
+		key := [2]string{h.site, h.widgetType}
+		site, ok := sites[key]
+		if !ok {
+			site = &HandlerSiteStats{Site: h.site, WidgetType: h.widgetType}
+			sites[key] = site
+		}
+
+		site.Live++
+		stats.Live++
+
+		switch {
+		case h.destroyed:
+			site.Leaked++
+			stats.Leaked++
+		case h.unmapped:
+			w := h.widget.Get()
+			if w == nil || gtk.BaseWidget(w).Root() == nil {
+				site.Leaked++
+				stats.Leaked++
+			} else {
+				site.Hidden++
+				stats.Hidden++
+			}
+		}
+	}
+
+	stats.Sites = make([]HandlerSiteStats, 0, len(sites))
+	for _, site := range sites {
+		stats.Sites = append(stats.Sites, *site)
+	}
+
+	slices.SortFunc(stats.Sites, func(a, b HandlerSiteStats) int {
+		if c := cmp.Compare(b.Live, a.Live); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Site, b.Site)
+	})
+
+	return stats
+}
+
+// String formats the stats as a table.
+func (s HandlerStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Live: %d, hidden: %d, leaked: %d\n", s.Live, s.Hidden, s.Leaked)
+	fmt.Fprintf(&b, "%5s %5s %5s  %s  %s\n", "live", "hid", "leak", "widget", "site")
+	for _, site := range s.Sites {
+		fmt.Fprintf(&b, "%5d %5d %5d  %s  %s\n",
+			site.Live, site.Hidden, site.Leaked, site.WidgetType, site.Site)
+	}
+	return b.String()
+}
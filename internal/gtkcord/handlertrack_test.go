@@ -0,0 +1,118 @@
+package gtkcord
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/handler"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+)
+
+func TestHandlerStats(t *testing.T) {
+	tracker := handlerTracker{live: make(map[uint64]*trackedHandler)}
+
+	tracker.track(nil, "a.go:1")
+	tracker.track(nil, "a.go:1")
+	tracker.track(nil, "b.go:2")
+	tracker.track(nil, "c.go:3")
+	unbind := tracker.track(nil, "c.go:3")
+	tracker.track(nil, "c.go:3")
+	tracker.track(nil, "c.go:3")
+
+	// A handler that was unbound isn't counted.
+	tracker.track(nil, "d.go:4")()
+
+	// Pretend that the widget of one handler was destroyed.
+	tracker.mu.Lock()
+	for _, h := range tracker.live {
+		if h.site == "b.go:2" {
+			h.destroyed = true
+		}
+	}
+	tracker.mu.Unlock()
+
+	stats := tracker.stats()
+	if stats.Live != 7 || stats.Leaked != 1 || stats.Hidden != 0 {
+		t.Errorf("expected 7 live, 1 leaked and 0 hidden, got %d, %d and %d",
+			stats.Live, stats.Leaked, stats.Hidden)
+	}
+
+	want := []HandlerSiteStats{
+		{Site: "c.go:3", WidgetType: "(none)", Live: 4},
+		{Site: "a.go:1", WidgetType: "(none)", Live: 2},
+		{Site: "b.go:2", WidgetType: "(none)", Live: 1, Leaked: 1},
+	}
+	if !reflect.DeepEqual(stats.Sites, want) {
+		t.Errorf("expected sites %+v, got %+v", want, stats.Sites)
+	}
+
+	unbind()
+
+	stats = tracker.stats()
+	if stats.Live != 6 || stats.Sites[0].Live != 3 {
+		t.Errorf("expected 6 live with 3 from c.go:3 after unbinding, got %d and %d",
+			stats.Live, stats.Sites[0].Live)
+	}
+}
+
+func TestHandlerTrackerLeak(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if !gtk.InitCheck() {
+		t.Skip("GTK cannot be initialized without a display")
+	}
+
+	oldEnabled, oldHandlers := HandlerTrackingEnabled, handlers.live
+	HandlerTrackingEnabled = true
+	handlers.live = make(map[uint64]*trackedHandler)
+	t.Cleanup(func() {
+		HandlerTrackingEnabled = oldEnabled
+		handlers.live = oldHandlers
+	})
+
+	s := &State{MainThreadHandler: NewMainThreadHandler(handler.New())}
+
+	outer := gtk.NewBox(gtk.OrientationVertical, 0)
+	inner := gtk.NewLabel("")
+	outer.Append(inner)
+
+	// The handler references its widget, like most handlers do.
+	unbind := s.AddHandlerForWidget(inner, func(ev *gateway.MessageCreateEvent) {
+		inner.SetLabel(ev.Content)
+	})
+
+	win := gtk.NewWindow()
+	win.SetChild(outer)
+	win.Present()
+	t.Cleanup(win.Destroy)
+
+	if !inner.Mapped() {
+		t.Skip("window was not mapped")
+	}
+
+	stats := handlers.stats()
+	if stats.Live != 1 || stats.Leaked != 0 || stats.Hidden != 0 {
+		t.Fatalf("expected 1 live handler that isn't leaked, got %+v", stats)
+	}
+
+	// Removing the outer box takes the label out of the window without
+	// unparenting it, so the handler stays bound.
+	win.SetChild(nil)
+
+	stats = handlers.stats()
+	if stats.Live != 1 || stats.Leaked != 1 {
+		t.Fatalf("expected 1 leaked handler, got %+v", stats)
+	}
+	if site := stats.Sites[0]; site.WidgetType != "*gtk.Label" || site.Leaked != 1 {
+		t.Errorf("expected the leak to be reported for *gtk.Label, got %+v", site)
+	}
+
+	unbind()
+
+	if stats := handlers.stats(); stats.Live != 0 || stats.Leaked != 0 {
+		t.Errorf("expected no handlers after unbinding, got %+v", stats)
+	}
+}
@@ -0,0 +1,409 @@
+package gtkcord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/session"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/state/store/defaultstore"
+	"github.com/diamondburned/arikawa/v3/utils/handler"
+	"github.com/diamondburned/arikawa/v3/utils/json/option"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/pkg/errors"
+)
+
+const defaultUserAgent = "Dissent (https://libdb.so/dissent)"
+
+var identifyUserAgent = prefs.NewString(defaultUserAgent, prefs.StringMeta{
+	Name:    "User Agent",
+	Section: "Gateway",
+	Description: "The User-Agent header sent when logging in and by accounts " +
+		"that don't set their own.",
+	Placeholder: defaultUserAgent,
+})
+
+func init() {
+	// Used by requests made without a State, such as logging in.
+	api.UserAgent = defaultUserAgent
+	identifyUserAgent.SubscribeInit(func() {
+		api.UserAgent = identifyUserAgent.Value()
+	})
+
+	prefs.RegisterProp((*identifyPrefs)(nil))
+	prefs.Order(identifyUserAgent, (*identifyPrefs)(nil))
+}
+
+// IdentifySettings are the properties that an account identifies with when it
+// connects to the gateway. They are kept separately for each account.
+type IdentifySettings struct {
+	// UserAgent is the User-Agent header sent with every API request. The
+	// one in the preferences is used if it is empty.
+	UserAgent       string `json:"user_agent,omitempty"`
+	OS              string `json:"os"`
+	Browser         string `json:"browser"`
+	Device          string `json:"device"`
+	ReleaseChannel  string `json:"release_channel,omitempty"`
+	ClientVersion   string `json:"client_version,omitempty"`
+	ClientBuild     int    `json:"client_build_number,omitempty"`
+	ExtraProperties string `json:"extra_properties,omitempty"`
+	Capabilities    int    `json:"capabilities,omitempty"`
+	Intents         string `json:"intents,omitempty"`
+	LargeThreshold  int    `json:"large_threshold"`
+	Compress        bool   `json:"compress"`
+}
+
+// DefaultIdentifySettings returns the settings of accounts that haven't
+// changed them.
+func DefaultIdentifySettings() IdentifySettings {
+	return IdentifySettings{
+		OS:             runtime.GOOS,
+		Browser:        defaultBrowser(),
+		Device:         "Arikawa",
+		LargeThreshold: 50,
+		Compress:       true,
+	}
+}
+
+// Validate returns an error if the extra properties or the intents can't be
+// parsed.
+func (s IdentifySettings) Validate() error {
+	if _, err := parseExtraProperties(s.ExtraProperties); err != nil {
+		return errors.Wrap(err, "invalid extra properties")
+	}
+	if _, err := parseIntents(s.Intents); err != nil {
+		return err
+	}
+	return nil
+}
+
+func defaultBrowser() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "PC"
+	}
+	return "Dissent on " + hostname
+}
+
+var identifySettingsKey = NewAccountSingleStateKey[IdentifySettings]("identify-settings")
+
+// AccountIdentifySettings returns the identify settings of the account with
+// the given ID, or the defaults if it has none.
+func AccountIdentifySettings(ctx context.Context, id discord.UserID) IdentifySettings {
+	settings := DefaultIdentifySettings()
+	if !id.IsValid() {
+		return settings
+	}
+
+	// The settings are needed right away to create the state, so read them
+	// synchronously.
+	state := (*app.State)(identifySettingsKey.AcquireAccount(ctx, id))
+	state.Get("", &settings)
+
+	return settings
+}
+
+// SetAccountIdentifySettings saves the identify settings of the account with
+// the given ID. They apply the next time the account connects.
+func SetAccountIdentifySettings(ctx context.Context, id discord.UserID, settings IdentifySettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	identifySettingsKey.AcquireAccount(ctx, id).Set(settings)
+	return nil
+}
+
+// New creates a new state for the given token. The gateway is identified using
+// the settings of the account that the token belongs to. Changes to the
+// settings apply the next time a state is created.
+func New(ctx context.Context, token string) *State {
+	id, _ := TokenUserID(token)
+	settings := AccountIdentifySettings(ctx, id)
+
+	g := newGateway(NewIdentifier(token, settings))
+	s := Wrap(state.NewFromSession(
+		session.NewWithGateway(g, handler.New()),
+		defaultstore.New(),
+	))
+	if settings.UserAgent != "" {
+		s.Client.UserAgent = settings.UserAgent
+	}
+
+	return s
+}
+
+// NewIdentifier creates a gateway identifier for the given token using the
+// given settings.
+func NewIdentifier(token string, settings IdentifySettings) gateway.Identifier {
+	cmd := gateway.DefaultIdentifyCommand(token)
+	cmd.Properties = settings.properties()
+	cmd.Compress = settings.Compress
+	cmd.LargeThreshold = uint(settings.LargeThreshold)
+	cmd.Capabilities = settings.Capabilities
+	// The settings are validated before they are saved, so the error can be
+	// ignored.
+	cmd.Intents, _ = parseIntents(settings.Intents)
+
+	return gateway.NewIdentifier(cmd)
+}
+
+func (s IdentifySettings) properties() gateway.IdentifyProperties {
+	props := gateway.IdentifyProperties{
+		gateway.IdentifyOS:      s.OS,
+		gateway.IdentifyBrowser: s.Browser,
+		gateway.IdentifyDevice:  s.Device,
+	}
+
+	if s.ReleaseChannel != "" {
+		props["release_channel"] = s.ReleaseChannel
+	}
+	if s.ClientVersion != "" {
+		props["client_version"] = s.ClientVersion
+	}
+	if s.ClientBuild != 0 {
+		props["client_build_number"] = s.ClientBuild
+	}
+
+	// Extra properties override the ones above.
+	extras, _ := parseExtraProperties(s.ExtraProperties)
+	for k, v := range extras {
+		props[k] = v
+	}
+
+	return props
+}
+
+func parseExtraProperties(s string) (gateway.IdentifyProperties, error) {
+	props := make(gateway.IdentifyProperties)
+
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("line %d: expected key=value", i+1)
+		}
+
+		var value any
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			value = v
+		}
+
+		props[gateway.IdentifyPropertyKey(k)] = value
+	}
+
+	return props, nil
+}
+
+func parseIntents(s string) (option.Uint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid intents: %w", err)
+	}
+
+	return option.NewUint(uint(v)), nil
+}
+
+// IdentifyCommand returns the Identify command that is sent to the gateway,
+// with the token removed. It returns false if the state has no gateway.
+func (s *State) IdentifyCommand() (gateway.IdentifyCommand, bool) {
+	g := s.Session.Gateway()
+	if g == nil {
+		return gateway.IdentifyCommand{}, false
+	}
+
+	cmd := g.State().Identifier.IdentifyCommand
+	cmd.Token = ""
+	return cmd, true
+}
+
+// identifyPrefs is a pseudo-preference that edits the identify settings of the
+// account logged in to the window.
+type identifyPrefs struct{}
+
+func (*identifyPrefs) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+func (*identifyPrefs) UnmarshalJSON(b []byte) error {
+	if string(b) != "null" {
+		return fmt.Errorf("unexpected %q, expecting null", b)
+	}
+	return nil
+}
+
+func (*identifyPrefs) Meta() prefs.PropMeta {
+	return prefs.PropMeta{
+		Name:    "Identify Properties",
+		Section: "Gateway",
+		Description: "The properties that this account identifies with. " +
+			"Changes apply the next time it connects.",
+	}
+}
+
+// Pubsubber panics. Do not call this method.
+func (*identifyPrefs) Pubsubber() *prefs.Pubsub {
+	panic("BUG: accidental call to Pubsubber")
+}
+
+func (*identifyPrefs) CreateWidget(ctx context.Context, _ func()) gtk.Widgetter {
+	var me *discord.User
+	if state := FromContext(ctx); state != nil {
+		me, _ = state.Cabinet.Me()
+	}
+
+	if me == nil {
+		label := gtk.NewLabel(locale.Get("Log in to change the properties of your account."))
+		label.AddCSSClass("dim-label")
+		label.SetWrap(true)
+		label.SetXAlign(0)
+		return label
+	}
+
+	return newIdentifyEditor(ctx, me.ID)
+}
+
+func (*identifyPrefs) WidgetIsLarge() bool {
+	return true
+}
+
+func newIdentifyEditor(ctx context.Context, id discord.UserID) gtk.Widgetter {
+	settings := AccountIdentifySettings(ctx, id)
+
+	save := func() {
+		if err := SetAccountIdentifySettings(ctx, id, settings); err != nil {
+			slog.Error(
+				"cannot save identify settings",
+				"account", id,
+				"err", err)
+		}
+	}
+
+	entry := func(title string, field *string, validate func(string) error) *adw.EntryRow {
+		row := adw.NewEntryRow()
+		row.SetTitle(title)
+		row.SetText(*field)
+		row.SetShowApplyButton(true)
+		row.ConnectApply(func() {
+			text := row.Text()
+			if validate != nil {
+				if err := validate(text); err != nil {
+					row.AddCSSClass("error")
+					row.SetTooltipText(err.Error())
+					return
+				}
+			}
+			row.RemoveCSSClass("error")
+			row.SetTooltipText("")
+			*field = text
+			save()
+		})
+		return row
+	}
+
+	spin := func(title string, field *int, max float64) *adw.SpinRow {
+		row := adw.NewSpinRowWithRange(0, max, 1)
+		row.SetTitle(title)
+		row.SetValue(float64(*field))
+		row.NotifyProperty("value", func() {
+			*field = int(row.Value())
+			save()
+		})
+		return row
+	}
+
+	compress := adw.NewSwitchRow()
+	compress.SetTitle(locale.Get("Compress"))
+	compress.SetActive(settings.Compress)
+	compress.NotifyProperty("active", func() {
+		settings.Compress = compress.Active()
+		save()
+	})
+
+	group := adw.NewPreferencesGroup()
+	group.Add(entry(locale.Get("User Agent"), &settings.UserAgent, nil))
+	group.Add(entry(locale.Get("Operating System"), &settings.OS, nil))
+	group.Add(entry(locale.Get("Browser"), &settings.Browser, nil))
+	group.Add(entry(locale.Get("Device"), &settings.Device, nil))
+	group.Add(entry(locale.Get("Release Channel"), &settings.ReleaseChannel, nil))
+	group.Add(entry(locale.Get("Client Version"), &settings.ClientVersion, nil))
+	group.Add(spin(locale.Get("Client Build Number"), &settings.ClientBuild, math.MaxInt32))
+	group.Add(spin(locale.Get("Capabilities"), &settings.Capabilities, math.MaxInt32))
+	group.Add(entry(locale.Get("Intents"), &settings.Intents, func(s string) error {
+		_, err := parseIntents(s)
+		return err
+	}))
+	group.Add(spin(locale.Get("Large Threshold"), &settings.LargeThreshold, 250))
+	group.Add(compress)
+
+	// Extra properties take one key=value pair per line, which an entry row
+	// can't hold.
+	extras := gtk.NewTextView()
+	extras.AddCSSClass("card")
+	extras.SetMonospace(true)
+	extras.SetWrapMode(gtk.WrapWordChar)
+	extras.SetTopMargin(6)
+	extras.SetBottomMargin(6)
+	extras.SetLeftMargin(6)
+	extras.SetRightMargin(6)
+	extras.SetSizeRequest(-1, 80)
+	extras.Buffer().SetText(settings.ExtraProperties)
+
+	applyExtras := gtk.NewButtonWithLabel(locale.Get("Apply"))
+	applyExtras.AddCSSClass("flat")
+	applyExtras.SetVAlign(gtk.AlignCenter)
+	applyExtras.ConnectClicked(func() {
+		buffer := extras.Buffer()
+		start, end := buffer.Bounds()
+		text := buffer.Text(start, end, false)
+
+		if _, err := parseExtraProperties(text); err != nil {
+			extras.AddCSSClass("error")
+			extras.SetTooltipText(err.Error())
+			return
+		}
+
+		extras.RemoveCSSClass("error")
+		extras.SetTooltipText("")
+		settings.ExtraProperties = text
+		save()
+	})
+
+	extrasGroup := adw.NewPreferencesGroup()
+	extrasGroup.SetTitle(locale.Get("Extra Properties"))
+	extrasGroup.SetDescription(locale.Get(
+		"One key=value pair per line. Values that are valid JSON are sent as is, " +
+			"everything else is sent as a string."))
+	extrasGroup.SetHeaderSuffix(applyExtras)
+	extrasGroup.Add(extras)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 12)
+	box.Append(group)
+	box.Append(extrasGroup)
+
+	return box
+}
@@ -0,0 +1,108 @@
+package gtkcord
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// Link is a link to a Discord channel or to a message within it.
+type Link struct {
+	// GuildID is 0 for direct messages.
+	GuildID   discord.GuildID
+	ChannelID discord.ChannelID
+	// MessageID is 0 if the link is to the channel itself.
+	MessageID discord.MessageID
+}
+
+// linkHosts are the hosts that serve the Discord web client.
+var linkHosts = map[string]bool{
+	"discord.com":        true,
+	"www.discord.com":    true,
+	"canary.discord.com": true,
+	"ptb.discord.com":    true,
+	"discordapp.com":     true,
+	"www.discordapp.com": true,
+}
+
+// ParseLink parses a link to a channel or message, such as
+// https://discord.com/channels/<guild>/<channel>/<message> or
+// discord://-/channels/<guild>/<channel>. Direct messages use @me in place of
+// the guild.
+func ParseLink(uri string) (Link, bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Link{}, false
+	}
+
+	var path string
+	switch u.Scheme {
+	case "https", "http":
+		if !linkHosts[strings.ToLower(u.Host)] {
+			return Link{}, false
+		}
+		path = u.Path
+	case "discord":
+		// The official client uses discord://-/channels/..., but
+		// discord://channels/... and discord:///channels/... are also seen.
+		path = u.Path
+		if u.Host != "" && u.Host != "-" {
+			path = "/" + u.Host + path
+		}
+	default:
+		return Link{}, false
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "channels" {
+		return Link{}, false
+	}
+
+	var link Link
+
+	if parts[1] != "@me" {
+		id, err := discord.ParseSnowflake(parts[1])
+		if err != nil || !id.IsValid() {
+			return Link{}, false
+		}
+		link.GuildID = discord.GuildID(id)
+	}
+
+	id, err := discord.ParseSnowflake(parts[2])
+	if err != nil || !id.IsValid() {
+		return Link{}, false
+	}
+	link.ChannelID = discord.ChannelID(id)
+
+	if len(parts) == 4 {
+		id, err := discord.ParseSnowflake(parts[3])
+		if err != nil || !id.IsValid() {
+			return Link{}, false
+		}
+		link.MessageID = discord.MessageID(id)
+	}
+
+	return link, true
+}
+
+// MessageVariant is the variant type for a message, which is a tuple of its
+// channel ID and message ID.
+var MessageVariant = glib.NewVariantType("(xx)")
+
+// NewMessageVariant creates a new message variant.
+func NewMessageVariant(chID discord.ChannelID, msgID discord.MessageID) *glib.Variant {
+	return glib.NewVariantTuple([]*glib.Variant{
+		glib.NewVariantInt64(int64(chID)),
+		glib.NewVariantInt64(int64(msgID)),
+	})
+}
+
+// MessageFromVariant returns the channel ID and message ID within a message
+// variant.
+func MessageFromVariant(v *glib.Variant) (discord.ChannelID, discord.MessageID) {
+	chID := discord.ChannelID(v.ChildValue(0).Int64())
+	msgID := discord.MessageID(v.ChildValue(1).Int64())
+	return chID, msgID
+}
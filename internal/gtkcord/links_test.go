@@ -0,0 +1,159 @@
+package gtkcord
+
+import "testing"
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want Link
+		ok   bool
+	}{
+		{
+			name: "guild channel",
+			uri:  "https://discord.com/channels/1/2",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "guild message",
+			uri:  "https://discord.com/channels/1/2/3",
+			want: Link{GuildID: 1, ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "direct message",
+			uri:  "https://discord.com/channels/@me/2/3",
+			want: Link{ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "trailing slash",
+			uri:  "https://discord.com/channels/1/2/",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "http",
+			uri:  "http://discord.com/channels/1/2",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "www",
+			uri:  "https://www.discord.com/channels/1/2",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "ptb",
+			uri:  "https://ptb.discord.com/channels/1/2/3",
+			want: Link{GuildID: 1, ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "canary",
+			uri:  "https://canary.discord.com/channels/1/2/3",
+			want: Link{GuildID: 1, ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "discordapp",
+			uri:  "https://discordapp.com/channels/1/2/3",
+			want: Link{GuildID: 1, ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "uppercase host",
+			uri:  "https://Discord.com/channels/1/2",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "discord scheme with dash host",
+			uri:  "discord://-/channels/1/2/3",
+			want: Link{GuildID: 1, ChannelID: 2, MessageID: 3},
+			ok:   true,
+		},
+		{
+			name: "discord scheme without host",
+			uri:  "discord:///channels/1/2",
+			want: Link{GuildID: 1, ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "discord scheme with channels host",
+			uri:  "discord://channels/@me/2",
+			want: Link{ChannelID: 2},
+			ok:   true,
+		},
+		{
+			name: "other host",
+			uri:  "https://example.com/channels/1/2",
+		},
+		{
+			name: "lookalike host",
+			uri:  "https://discord.com.example.com/channels/1/2",
+		},
+		{
+			name: "other scheme",
+			uri:  "ftp://discord.com/channels/1/2",
+		},
+		{
+			name: "discord scheme with other host",
+			uri:  "discord://example/channels/1/2",
+		},
+		{
+			name: "not a channel",
+			uri:  "https://discord.com/invite/abc",
+		},
+		{
+			name: "guild only",
+			uri:  "https://discord.com/channels/1",
+		},
+		{
+			name: "extra segment",
+			uri:  "https://discord.com/channels/1/2/3/4",
+		},
+		{
+			name: "invalid guild ID",
+			uri:  "https://discord.com/channels/abc/2",
+		},
+		{
+			name: "zero guild ID",
+			uri:  "https://discord.com/channels/0/2",
+		},
+		{
+			name: "invalid channel ID",
+			uri:  "https://discord.com/channels/1/abc",
+		},
+		{
+			name: "negative channel ID",
+			uri:  "https://discord.com/channels/1/-2",
+		},
+		{
+			name: "invalid message ID",
+			uri:  "https://discord.com/channels/1/2/abc",
+		},
+		{
+			name: "empty",
+			uri:  "",
+		},
+		{
+			name: "malformed",
+			uri:  "https://discord.com/%zz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := ParseLink(test.uri)
+			if ok != test.ok {
+				t.Fatalf("ParseLink(%q) ok = %v, want %v", test.uri, ok, test.ok)
+			}
+			if got != test.want {
+				t.Errorf("ParseLink(%q) = %+v, want %+v", test.uri, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package gtkcord
+
+import (
+	"context"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/pkg/errors"
+)
+
+// Endpoints used for logging out.
+var (
+	EndpointLogout         = api.EndpointAuth + "logout"
+	EndpointSessions       = api.EndpointAuth + "sessions"
+	EndpointSessionsLogout = api.EndpointAuth + "sessions/logout"
+)
+
+// AuthSession is a device that the account is logged in on.
+type AuthSession struct {
+	IDHash string `json:"id_hash"`
+}
+
+// AuthSessions returns the devices that the account is logged in on, including
+// this one.
+func (s *State) AuthSessions(ctx context.Context) ([]AuthSession, error) {
+	var resp struct {
+		UserSessions []AuthSession `json:"user_sessions"`
+	}
+
+	err := s.Client.WithContext(ctx).RequestJSON(&resp, "GET", EndpointSessions)
+	return resp.UserSessions, err
+}
+
+// LogOutEverywhere logs the account out of every device, then revokes the
+// state's own token. The state cannot be used afterwards.
+func (s *State) LogOutEverywhere(ctx context.Context) error {
+	client := s.Client.WithContext(ctx)
+
+	sessions, err := s.AuthSessions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot list logged in devices")
+	}
+
+	if len(sessions) > 0 {
+		var body struct {
+			SessionIDHashes []string `json:"session_id_hashes"`
+		}
+		for _, session := range sessions {
+			body.SessionIDHashes = append(body.SessionIDHashes, session.IDHash)
+		}
+
+		err := client.FastRequest("POST", EndpointSessionsLogout, httputil.WithJSONBody(body))
+		if err != nil {
+			return errors.Wrap(err, "cannot log out other devices")
+		}
+	}
+
+	var body struct {
+		Provider     *string `json:"provider"`
+		VoipProvider *string `json:"voip_provider"`
+	}
+
+	if err := client.FastRequest("POST", EndpointLogout, httputil.WithJSONBody(body)); err != nil {
+		return errors.Wrap(err, "cannot revoke token")
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package gtkcord
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/utils/httputil/httpdriver"
+)
+
+// maxRequestRecords is the maximum number of requests kept in a RequestLog.
+const maxRequestRecords = 250
+
+// RequestRecord describes a single REST API call.
+type RequestRecord struct {
+	// Time is the time that the request was sent.
+	Time time.Time
+	// Method is the HTTP method.
+	Method string
+	// Path is the URL path with any token redacted.
+	Path string
+	// Status is the HTTP status code. It is 0 if the request failed without a
+	// response.
+	Status int
+	// Latency is the time from sending the request to receiving the response.
+	Latency time.Duration
+	// RateLimit contains the rate limit headers of the response.
+	RateLimit RateLimitInfo
+}
+
+// RateLimitInfo contains the rate limit headers of a response. Fields are
+// empty if the response doesn't have them.
+type RateLimitInfo struct {
+	Bucket     string
+	Limit      string
+	Remaining  string
+	ResetAfter string
+	Global     bool
+}
+
+// RequestLog keeps a list of the most recent REST API calls.
+type RequestLog struct {
+	mu      sync.Mutex
+	records []RequestRecord // ring buffer
+	next    int
+	serial  uint64
+	started map[*http.Request]time.Time
+}
+
+func newRequestLog() *RequestLog {
+	return &RequestLog{
+		records: make([]RequestRecord, 0, maxRequestRecords),
+		started: make(map[*http.Request]time.Time),
+	}
+}
+
+// Records returns the recorded requests from oldest to newest.
+func (l *RequestLog) Records() []RequestRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := make([]RequestRecord, 0, len(l.records))
+	records = append(records, l.records[l.next:]...)
+	records = append(records, l.records[:l.next]...)
+	return records
+}
+
+// Serial returns a number that changes every time a request is recorded. It
+// can be used to check whether Records has changed.
+func (l *RequestLog) Serial() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.serial
+}
+
+func (l *RequestLog) onRequest(dreq httpdriver.Request) error {
+	// Requests from other drivers can't be inspected, so they're not logged.
+	dr, ok := dreq.(*httpdriver.DefaultRequest)
+	if !ok {
+		return nil
+	}
+	req := (*http.Request)(dr)
+
+	l.mu.Lock()
+	l.started[req] = time.Now()
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *RequestLog) onResponse(dreq httpdriver.Request, dresp httpdriver.Response) error {
+	dr, ok := dreq.(*httpdriver.DefaultRequest)
+	if !ok {
+		return nil
+	}
+	req := (*http.Request)(dr)
+	now := time.Now()
+
+	record := RequestRecord{
+		Method: req.Method,
+		Path:   redactPath(req.URL.Path),
+	}
+
+	if dresp, ok := dresp.(*httpdriver.DefaultResponse); ok {
+		resp := (*http.Response)(dresp)
+		record.Status = resp.StatusCode
+		record.RateLimit = RateLimitInfo{
+			Bucket:     resp.Header.Get("X-RateLimit-Bucket"),
+			Limit:      resp.Header.Get("X-RateLimit-Limit"),
+			Remaining:  resp.Header.Get("X-RateLimit-Remaining"),
+			ResetAfter: resp.Header.Get("X-RateLimit-Reset-After"),
+			Global:     resp.Header.Get("X-RateLimit-Global") == "true",
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Time = now
+	if start, ok := l.started[req]; ok {
+		record.Time = start
+		record.Latency = now.Sub(start)
+		delete(l.started, req)
+	}
+
+	if len(l.records) < maxRequestRecords {
+		l.records = append(l.records, record)
+	} else {
+		l.records[l.next] = record
+		l.next = (l.next + 1) % maxRequestRecords
+	}
+	l.serial++
+
+	return nil
+}
+
+// redactPath redacts the tokens in webhook and interaction paths, which are
+// the only API paths that contain secrets.
+func redactPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if part != "webhooks" && part != "interactions" {
+			continue
+		}
+		// The layout is always {kind}/{id}/{token}.
+		if i+2 < len(parts) && parts[i+2] != "" {
+			parts[i+2] = "[redacted]"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// GatewayInfo describes the current state of the gateway connection.
+type GatewayInfo struct {
+	// Connected is true if there is a gateway connection.
+	Connected bool
+	// SessionID is the ID of the current gateway session.
+	SessionID string
+	// Sequence is the sequence number of the last received event.
+	Sequence int64
+	// Latency is the latency of the last acknowledged heartbeat.
+	Latency time.Duration
+	// LastHeartbeat is the time that the last heartbeat was acknowledged.
+	LastHeartbeat time.Time
+}
+
+// Requests returns the log of recent REST API calls.
+func (s *State) Requests() *RequestLog {
+	return s.requests
+}
+
+// GatewayInfo returns the current state of the gateway connection.
+func (s *State) GatewayInfo() GatewayInfo {
+	g := s.Session.Gateway()
+	if g == nil {
+		return GatewayInfo{}
+	}
+
+	state := g.State()
+	return GatewayInfo{
+		Connected:     s.GatewayIsAlive(),
+		SessionID:     state.SessionID,
+		Sequence:      state.Sequence,
+		Latency:       g.Latency(),
+		LastHeartbeat: g.EchoBeat(),
+	}
+}
@@ -0,0 +1,59 @@
+package gtkcord
+
+import (
+	"sync/atomic"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/ningen/v3"
+)
+
+// connection keeps track of whether the gateway is connected. It is shared by
+// all copies of a State.
+type connection struct {
+	offline atomic.Bool
+}
+
+// trackConnection keeps c up to date with the gateway. It must be added before
+// the MainThreadHandler so that its handlers already see the new value.
+func trackConnection(n *ningen.State) *connection {
+	c := &connection{}
+	// The state has nothing but what's cached until it first connects.
+	c.offline.Store(true)
+
+	n.Handler.AddSyncHandler(func(ev any) {
+		switch ev.(type) {
+		case *ningen.ConnectedEvent:
+			c.offline.Store(false)
+		case *ningen.DisconnectedEvent:
+			c.offline.Store(true)
+		}
+	})
+
+	return c
+}
+
+// IsOffline returns true if the gateway is not connected, such as while it is
+// reconnecting or when only the on-disk cache has been restored. The state
+// then only has what's already in the cabinet, and nothing can be sent.
+func (s *State) IsOffline() bool {
+	return s.conn.offline.Load()
+}
+
+// Cached returns an Offline state if the gateway is not connected and an Online
+// state otherwise. It is meant for reading things that may already be in the
+// cabinet without waiting on requests that cannot succeed.
+func (s *State) Cached() *State {
+	if s.IsOffline() {
+		return s.Offline()
+	}
+	return s.Online()
+}
+
+// OnConnectionChange calls fn with true when the gateway disconnects and with
+// false when it connects again. Like On, its lifetime is bound to the widget.
+func OnConnectionChange(s *State, w gtk.Widgetter, fn func(offline bool)) func() {
+	return s.AddHandlerForWidget(w,
+		func(*ningen.ConnectedEvent) { fn(false) },
+		func(*ningen.DisconnectedEvent) { fn(true) },
+	)
+}
@@ -0,0 +1,514 @@
+package gtkcord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+type proxyType string
+
+const (
+	systemProxy proxyType = "System"
+	noProxy     proxyType = "None"
+	httpProxy   proxyType = "HTTP"
+	socks5Proxy proxyType = "SOCKS5"
+)
+
+var proxyKind = prefs.NewEnumList(systemProxy, prefs.EnumListMeta[proxyType]{
+	PropMeta: prefs.PropMeta{
+		Name:    "Proxy",
+		Section: "Network",
+		Description: "How to connect to Discord. System uses the HTTP_PROXY, HTTPS_PROXY " +
+			"and NO_PROXY environment variables. " +
+			"Changes apply to the gateway connection the next time it connects.",
+	},
+	Options: []proxyType{systemProxy, noProxy, httpProxy, socks5Proxy},
+})
+
+var proxyHost = prefs.NewString("", prefs.StringMeta{
+	Name:        "Proxy Host",
+	Section:     "Network",
+	Description: "The host and port of the HTTP or SOCKS5 proxy.",
+	Placeholder: "127.0.0.1:1080",
+	Validate: func(host string) error {
+		if host == "" {
+			return nil
+		}
+		_, _, err := net.SplitHostPort(host)
+		return err
+	},
+})
+
+var proxyUsername = prefs.NewString("", prefs.StringMeta{
+	Name:        "Proxy Username",
+	Section:     "Network",
+	Description: "The username for the proxy, if it requires authentication.",
+})
+
+var proxyPassword = &proxyPasswordPrefs{loaded: make(chan struct{})}
+
+var proxyBypass = prefs.NewString("", prefs.StringMeta{
+	Name:    "Proxy Bypass List",
+	Section: "Network",
+	Description: "A comma-separated list of hosts that are connected to directly, " +
+		"such as localhost,.example.com.",
+	Placeholder: "localhost,127.0.0.1",
+})
+
+func init() {
+	prefs.RegisterProp(proxyPassword)
+	prefs.RegisterProp((*proxyTestPrefs)(nil))
+	prefs.Order(proxyKind, proxyHost, proxyUsername, proxyPassword, proxyBypass, (*proxyTestPrefs)(nil))
+}
+
+// HTTPTransport is the HTTP transport for all requests made by Dissent. It
+// connects through the proxy configured in the preferences.
+var HTTPTransport http.RoundTripper = newHTTPTransport()
+
+func newHTTPTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc
+	return t
+}
+
+// NewHTTPClient creates a new HTTP client that uses HTTPTransport.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: HTTPTransport,
+		Timeout:   timeout,
+	}
+}
+
+// proxyFunc returns the proxy URL for the given request according to the
+// preferences. It is used as http.Transport.Proxy.
+func proxyFunc(r *http.Request) (*url.URL, error) {
+	switch proxyKind.Value() {
+	case systemProxy:
+		return http.ProxyFromEnvironment(r)
+	case noProxy:
+		return nil, nil
+	}
+
+	if proxyBypassed(r.URL.Hostname()) {
+		return nil, nil
+	}
+
+	return configuredProxyURL()
+}
+
+// configuredProxyURL returns the URL of the HTTP or SOCKS5 proxy configured in
+// the preferences.
+func configuredProxyURL() (*url.URL, error) {
+	host := proxyHost.Value()
+	if host == "" {
+		return nil, errors.New("no proxy host set")
+	}
+
+	u := &url.URL{Host: host}
+
+	switch proxyKind.Value() {
+	case httpProxy:
+		u.Scheme = "http"
+	case socks5Proxy:
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unknown proxy type %q", proxyKind.Value())
+	}
+
+	if username := proxyUsername.Value(); username != "" {
+		u.User = url.UserPassword(username, proxyPassword.wait())
+	}
+
+	return u, nil
+}
+
+// proxyBypassed returns true if the given host matches the bypass list. An
+// entry matches the host itself and all of its subdomains, and "*" matches
+// everything.
+func proxyBypassed(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, entry := range strings.Split(proxyBypass.Value(), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entry = strings.TrimPrefix(entry, ".")
+		if entry == "" {
+			continue
+		}
+		if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newGateway creates a gateway that connects through the proxy configured in
+// the preferences. arikawa's own gateway only uses the proxy from the
+// environment, which Go reads once, so the preferences are given to the dialer
+// instead. They are read on every dial, so changes apply the next time the
+// gateway connects.
+func newGateway(id gateway.Identifier) *gateway.Gateway {
+	conn := &gatewayConn{
+		Conn: ws.NewConnWithDialer(ws.NewCodec(gateway.OpUnmarshalers), newGatewayDialer()),
+	}
+	gw := ws.NewGateway(ws.NewCustomWebsocket(conn, ""), &gateway.DefaultGatewayOpts)
+	return gateway.FromWebsocketGateway(gw, gateway.State{Identifier: id})
+}
+
+// newGatewayDialer creates a websocket dialer like arikawa's, except it uses
+// the proxy configured in the preferences.
+func newGatewayDialer() websocket.Dialer {
+	return websocket.Dialer{
+		Proxy:             proxyFunc,
+		HandshakeTimeout:  10 * time.Second,
+		ReadBufferSize:    1 << 15,
+		WriteBufferSize:   1 << 15,
+		EnableCompression: true,
+	}
+}
+
+// gatewayConn is a gateway connection that queries the gateway URL through
+// HTTPTransport when it is first dialed.
+type gatewayConn struct {
+	*ws.Conn
+	url string
+}
+
+func (c *gatewayConn) Dial(ctx context.Context, addr string) (<-chan ws.Op, error) {
+	if addr == "" {
+		if c.url == "" {
+			u, err := queryGatewayURL(ctx)
+			if err != nil {
+				return nil, err
+			}
+			c.url = gateway.AddGatewayParams(u)
+		}
+		addr = c.url
+	}
+
+	return c.Conn.Dial(ctx, addr)
+}
+
+// queryGatewayURL fetches the URL of the gateway through HTTPTransport.
+func queryGatewayURL(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", api.EndpointGateway, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := NewHTTPClient(0).Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot reach the Discord API")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("the Discord API returned %s", resp.Status)
+	}
+
+	var data api.BotData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", errors.Wrap(err, "cannot decode gateway URL")
+	}
+
+	return data.URL, nil
+}
+
+// TestConnection checks that Discord can be reached with the current proxy
+// preferences. It fetches the gateway URL and then dials the gateway. It
+// returns the total time taken.
+func TestConnection(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	gatewayURL, err := queryGatewayURL(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	dialer := newGatewayDialer()
+
+	conn, _, err := dialer.DialContext(ctx, gatewayURL, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot reach the Discord gateway")
+	}
+	conn.Close()
+
+	return time.Since(start), nil
+}
+
+// proxyPasswordKey is the key of the proxy password in the secret driver.
+const proxyPasswordKey = "proxy-password"
+
+// proxySecrets returns the secret driver that the proxy password is kept in.
+// The password is needed before logging in, so it can't be kept in the
+// password-protected file that account tokens may be kept in. Instead, the
+// fallback is a salted file in its own directory, which never shares its salt
+// with the one of account tokens.
+func proxySecrets(ctx context.Context) secret.Service {
+	dir := app.FromContext(ctx).ConfigPath("proxy-secrets")
+	return secret.New(
+		secret.KeyringDriver(ctx),
+		secret.SaltedFileDriver(secret.WithEncryptedFilePath(ctx, dir)),
+	)
+}
+
+// LoadProxyPassword loads the proxy password from the secret driver in the
+// background. The password that older versions kept in the preferences file is
+// moved into the secret driver instead. It must be called on the main thread
+// after the preferences are loaded. Connections that need the password wait
+// until it is loaded.
+func LoadProxyPassword(ctx context.Context) {
+	p := proxyPassword
+
+	p.mu.Lock()
+	plain := p.plain
+	p.mu.Unlock()
+
+	// The password is only used together with a username.
+	username := proxyUsername.Value()
+
+	gtkutil.Async(ctx, func() func() {
+		defer close(p.loaded)
+
+		if plain != "" {
+			if err := proxySecrets(ctx).Set(proxyPasswordKey, []byte(plain)); err != nil {
+				slog.Warn(
+					"cannot move the proxy password out of the preferences file",
+					"err", err)
+				return nil
+			}
+
+			return func() {
+				p.mu.Lock()
+				p.plain = ""
+				p.mu.Unlock()
+
+				// Save the preferences again to remove the plain text
+				// password.
+				snapshot := prefs.TakeSnapshot()
+				go func() {
+					if err := snapshot.Save(ctx); err != nil {
+						slog.Warn(
+							"cannot save preferences without the proxy password",
+							"err", err)
+					}
+				}()
+			}
+		}
+
+		if username == "" {
+			return nil
+		}
+
+		b, err := proxySecrets(ctx).Get(proxyPasswordKey)
+		if err != nil {
+			if !errors.Is(err, secret.ErrNotFound) {
+				slog.Warn(
+					"cannot load the proxy password",
+					"err", err)
+			}
+			return nil
+		}
+
+		p.mu.Lock()
+		p.password = string(b)
+		p.mu.Unlock()
+
+		return nil
+	})
+}
+
+// proxyPasswordPrefs is a pseudo-preference for the proxy password. The
+// password is kept in the keyring or the encrypted file instead of the
+// preferences file.
+type proxyPasswordPrefs struct {
+	mu       sync.Mutex
+	password string
+	// plain is the password found in the preferences file, which older
+	// versions kept it in. It stays there until LoadProxyPassword moves it.
+	plain string
+	// loaded is closed once LoadProxyPassword is done.
+	loaded chan struct{}
+}
+
+// Value returns the proxy password.
+func (p *proxyPasswordPrefs) Value() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.password
+}
+
+// wait returns the proxy password once LoadProxyPassword is done. It must not
+// be called on the main thread.
+func (p *proxyPasswordPrefs) wait() string {
+	<-p.loaded
+	return p.Value()
+}
+
+func (p *proxyPasswordPrefs) MarshalJSON() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.plain != "" {
+		return json.Marshal(p.plain)
+	}
+	return []byte("null"), nil
+}
+
+func (p *proxyPasswordPrefs) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+
+	var plain string
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.plain = plain
+	p.password = plain
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (*proxyPasswordPrefs) Meta() prefs.PropMeta {
+	return prefs.PropMeta{
+		Name:    "Proxy Password",
+		Section: "Network",
+		Description: "The password for the proxy, if it requires authentication. " +
+			"It is kept in the keyring, or in an encrypted file if there is none.",
+	}
+}
+
+// Pubsubber panics. Do not call this method.
+func (*proxyPasswordPrefs) Pubsubber() *prefs.Pubsub {
+	panic("BUG: accidental call to Pubsubber")
+}
+
+func (p *proxyPasswordPrefs) CreateWidget(ctx context.Context, _ func()) gtk.Widgetter {
+	entry := gtk.NewPasswordEntry()
+	entry.SetShowPeekIcon(true)
+	entry.SetVAlign(gtk.AlignCenter)
+	entry.SetText(p.Value())
+
+	store := func() {
+		password := entry.Text()
+		if password == p.Value() {
+			return
+		}
+
+		p.mu.Lock()
+		p.password = password
+		p.mu.Unlock()
+
+		go func() {
+			if err := proxySecrets(ctx).Set(proxyPasswordKey, []byte(password)); err != nil {
+				slog.Error(
+					"cannot store the proxy password",
+					"err", err)
+			}
+		}()
+	}
+
+	entry.ConnectActivate(store)
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectLeave(store)
+	entry.AddController(focus)
+
+	return entry
+}
+
+func (*proxyPasswordPrefs) WidgetIsLarge() bool {
+	return false
+}
+
+// proxyTestPrefs is a pseudo-preference that shows a button for testing the
+// proxy preferences.
+type proxyTestPrefs struct{}
+
+func (*proxyTestPrefs) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+func (*proxyTestPrefs) UnmarshalJSON(b []byte) error {
+	if string(b) != "null" {
+		return fmt.Errorf("unexpected %q, expecting null", b)
+	}
+	return nil
+}
+
+func (*proxyTestPrefs) Meta() prefs.PropMeta {
+	return prefs.PropMeta{
+		Name:        "Test Connection",
+		Section:     "Network",
+		Description: "Check that Discord can be reached with the settings above.",
+	}
+}
+
+// Pubsubber panics. Do not call this method.
+func (*proxyTestPrefs) Pubsubber() *prefs.Pubsub {
+	panic("BUG: accidental call to Pubsubber")
+}
+
+func (*proxyTestPrefs) CreateWidget(ctx context.Context, _ func()) gtk.Widgetter {
+	result := gtk.NewLabel("")
+	result.AddCSSClass("dim-label")
+	result.SetWrap(true)
+	result.SetXAlign(1)
+	result.SetHExpand(true)
+
+	button := gtk.NewButtonWithLabel(locale.Get("Test"))
+	button.ConnectClicked(func() {
+		button.SetSensitive(false)
+		result.SetText(locale.Get("Connecting…"))
+
+		gtkutil.Async(ctx, func() func() {
+			took, err := TestConnection(ctx)
+			return func() {
+				button.SetSensitive(true)
+				if err != nil {
+					result.SetText(err.Error())
+				} else {
+					result.SetText(locale.Sprintf("Connected in %s", took.Round(time.Millisecond)))
+				}
+			}
+		})
+	})
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	box.Append(result)
+	box.Append(button)
+
+	return box
+}
+
+func (*proxyTestPrefs) WidgetIsLarge() bool {
+	return false
+}
@@ -0,0 +1,31 @@
+package gtkcord
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"libdb.so/dissent/internal/remoteauth"
+)
+
+// RemoteAuthOptions returns the options for logging in using a QR code. Like
+// the states returned by Wrap, they use the proxy and User-Agent from the
+// preferences and honor DISSENT_DEBUG_BASE_URL. The callbacks are left for
+// the caller to set.
+func RemoteAuthOptions() remoteauth.Options {
+	opts := remoteauth.Options{
+		GatewayURL: remoteauth.DefaultGatewayURL,
+		Client:     NewAPIClient(""),
+		Dialer: &websocket.Dialer{
+			Proxy:            proxyFunc,
+			HandshakeTimeout: 30 * time.Second,
+		},
+	}
+
+	if u := debugBaseURL(); u != nil {
+		// This matches fakediscord.Server.RemoteAuthURL.
+		opts.GatewayURL = "ws" + strings.TrimPrefix(u.String(), "http") + "/remote-auth"
+	}
+
+	return opts
+}
@@ -0,0 +1,206 @@
+package gtkcord
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/gateway"
+	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/utils/httputil/httpdriver"
+	"github.com/diamondburned/arikawa/v3/utils/json"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/pkg/errors"
+)
+
+// ErrReplayOffline is returned by all API calls made by a state created using
+// NewReplayState. Replayed sessions never talk to Discord.
+var ErrReplayOffline = errors.New("replay mode: API calls are disabled")
+
+// ReplayDirFromEnv returns the directory of dumped events to replay from the
+// DISSENT_DEBUG_REPLAY_EVENTS environment variable. If
+// DISSENT_DEBUG_REPLAY_REALTIME is set to 1, then realtime is true.
+func ReplayDirFromEnv() (dir string, realtime bool) {
+	dir = os.Getenv("DISSENT_DEBUG_REPLAY_EVENTS")
+	realtime = os.Getenv("DISSENT_DEBUG_REPLAY_REALTIME") == "1"
+	return
+}
+
+// NewReplayState creates a new State that is never connected to the gateway.
+// Events are instead fed into it using ReplayEvents.
+func NewReplayState() *State {
+	s := state.New("")
+	s.Client.Client.OnRequest = append(s.Client.Client.OnRequest,
+		func(httpdriver.Request) error { return ErrReplayOffline })
+	return Wrap(s)
+}
+
+// ReplayEvent is a single raw event dumped by dumpRawEvents.
+type ReplayEvent struct {
+	// Path is the path to the event's file.
+	Path string
+	// ID is the sequential number of the event in the dump.
+	ID uint64
+	// Code is the original opcode of the event.
+	Code ws.OpCode
+	// Type is the original event type of the event. It is empty for
+	// non-dispatch events.
+	Type ws.EventType
+	// Time is the time that the event was dumped.
+	Time time.Time
+}
+
+// ReadReplayEvents reads all events dumped into dir by dumpRawEvents. The
+// returned events are sorted in the order that they were received.
+func ReadReplayEvents(dir string) ([]ReplayEvent, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read replay directory")
+	}
+
+	events := make([]ReplayEvent, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		ev, err := parseReplayEventName(entry.Name())
+		if err != nil {
+			slog.Warn(
+				"skipping unknown file in replay directory",
+				"file", entry.Name(),
+				"err", err)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot stat %q", entry.Name())
+		}
+
+		ev.Path = filepath.Join(dir, entry.Name())
+		ev.Time = info.ModTime()
+		events = append(events, ev)
+	}
+
+	slices.SortFunc(events, func(a, b ReplayEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return events, nil
+}
+
+// parseReplayEventName parses a file name in the format of
+// "%05d-%d-%s.json", which is the format used by dumpRawEvents.
+func parseReplayEventName(name string) (ReplayEvent, error) {
+	name = strings.TrimSuffix(name, ".json")
+
+	parts := strings.SplitN(name, "-", 3)
+	if len(parts) != 3 {
+		return ReplayEvent{}, fmt.Errorf("malformed event file name %q", name)
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ReplayEvent{}, errors.Wrap(err, "invalid event ID")
+	}
+
+	code, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ReplayEvent{}, errors.Wrap(err, "invalid event opcode")
+	}
+
+	return ReplayEvent{
+		ID:   id,
+		Code: ws.OpCode(code),
+		Type: ws.EventType(parts[2]),
+	}, nil
+}
+
+// Decode reads and decodes the event file into a gateway event.
+func (e ReplayEvent) Decode() (ws.Event, error) {
+	fn := gateway.OpUnmarshalers.Lookup(e.Code, e.Type)
+	if fn == nil {
+		return nil, ws.UnknownEventError{Op: e.Code, Type: e.Type}
+	}
+
+	b, err := os.ReadFile(e.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := fn()
+	if err := json.Raw(b).UnmarshalTo(ev); err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal event (op %d type %q)", e.Code, e.Type)
+	}
+
+	return ev, nil
+}
+
+// ReplayEvents reads the events that were dumped into dir when
+// DISSENT_DEBUG_DUMP_ALL_EVENTS_PLEASE was set and feeds them into the state as
+// if they came from the gateway. If realtime is true, then the events are paced
+// using the time that they were originally dumped at. This function blocks
+// until all events are replayed or ctx is canceled.
+func (s *State) ReplayEvents(ctx context.Context, dir string, realtime bool) error {
+	events, err := ReadReplayEvents(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return &fs.PathError{Op: "replay", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	slog.Info(
+		"replaying dumped gateway events",
+		"dir", dir,
+		"count", len(events),
+		"realtime", realtime)
+
+	var last time.Time
+	for _, event := range events {
+		if realtime && !last.IsZero() {
+			if delay := event.Time.Sub(last); delay > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+			}
+		}
+		last = event.Time
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ev, err := event.Decode()
+		if err != nil {
+			slog.Warn(
+				"cannot decode replayed event, skipping",
+				"file", event.Path,
+				"err", err)
+			continue
+		}
+
+		// Call the session handler directly. This is the same handler that the
+		// gateway would call, so the state cabinet, ningen and the
+		// MainThreadHandler all see the event.
+		s.Session.Handler.Call(ev)
+	}
+
+	slog.Info(
+		"finished replaying dumped gateway events",
+		"dir", dir)
+
+	return nil
+}
@@ -10,14 +10,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"reflect"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 
-	"github.com/diamondburned/arikawa/v3/api"
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/state"
@@ -32,24 +29,8 @@ import (
 	"github.com/diamondburned/ningen/v3"
 	"github.com/diamondburned/ningen/v3/discordmd"
 	"libdb.so/dissent/internal/colorhash"
-
-	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 )
 
-func init() {
-	hostname, err := os.Hostname()
-	if err != nil {
-		hostname = "PC"
-	}
-
-	api.UserAgent = "Dissent (https://libdb.so/dissent)"
-	gateway.DefaultIdentity = gateway.IdentifyProperties{
-		gateway.IdentifyOS:      runtime.GOOS,
-		gateway.IdentifyDevice:  "Arikawa",
-		gateway.IdentifyBrowser: "Dissent on " + hostname,
-	}
-}
-
 // AllowedChannelTypes are the channel types that are shown.
 var AllowedChannelTypes = []discord.ChannelType{
 	discord.GuildText,
@@ -74,6 +55,10 @@ const (
 type State struct {
 	*MainThreadHandler
 	*ningen.State
+
+	cache    *stateCache
+	requests *RequestLog
+	conn     *connection
 }
 
 // FromContext gets the Discord state controller from the given context.
@@ -87,7 +72,12 @@ func FromContext(ctx context.Context) *State {
 
 // Wrap wraps the given state.
 func Wrap(state *state.State) *State {
+	requests := newRequestLog()
+
 	c := state.Client.Client
+	c.Client = httpdriver.WrapClient(*NewHTTPClient(0))
+	c.OnRequest = append(c.OnRequest, requests.onRequest)
+	c.OnResponse = append(c.OnResponse, requests.onResponse)
 	c.OnRequest = append(c.OnRequest, func(r httpdriver.Request) error {
 		// req := (*http.Request)(r.(*httpdriver.DefaultRequest))
 		// log.Println("Discord API:", req.Method, req.URL.Path)
@@ -117,6 +107,13 @@ func Wrap(state *state.State) *State {
 			"err", err)
 	}
 
+	if u := debugBaseURL(); u != nil {
+		slog.Warn(
+			"ATTENTION: DISSENT_DEBUG_BASE_URL is set, not connecting to Discord",
+			"base_url", u.String())
+		useBaseURL(state, u)
+	}
+
 	if os.Getenv("DISSENT_DEBUG_DUMP_ALL_EVENTS_PLEASE") == "1" {
 		dir := filepath.Join(os.TempDir(), "gtkcord4-events")
 		slog.Warn(
@@ -125,10 +122,17 @@ func Wrap(state *state.State) *State {
 		dumpRawEvents(state, dir)
 	}
 
+	state.Client.UserAgent = identifyUserAgent.Value()
+
 	ningen := ningen.FromState(state)
+	conn := trackConnection(ningen)
+
 	return &State{
 		MainThreadHandler: NewMainThreadHandler(ningen.Handler),
 		State:             ningen,
+		cache:             &stateCache{},
+		requests:          requests,
+		conn:              conn,
 	}
 }
 
@@ -204,118 +208,29 @@ func (s *State) WithContext(ctx context.Context) *State {
 	return &s2
 }
 
-// BindHandler is similar to BindWidgetHandler, except the lifetime of the
-// handler is bound to the context.
-func (s *State) BindHandler(ctx gtkutil.Cancellable, fn func(gateway.Event), filters ...gateway.Event) {
-	eventTypes := make([]reflect.Type, len(filters))
-	for i, filter := range filters {
-		eventTypes[i] = reflect.TypeOf(filter)
-	}
-	ctx.OnRenew(func(context.Context) func() {
-		return s.AddSyncHandler(func(ev gateway.Event) {
-			// Optionally filter out events.
-			if len(eventTypes) > 0 {
-				evType := reflect.TypeOf(ev)
-
-				for _, typ := range eventTypes {
-					if typ == evType {
-						goto filtered
-					}
-				}
-
-				return
-			}
-
-		filtered:
-			glib.IdleAddPriority(glib.PriorityDefault, func() { fn(ev) })
-		})
-	})
-}
-
-// BindWidget is similar to BindHandler, except it doesn't rely on contexts.
-func (s *State) BindWidget(w gtk.Widgetter, fn func(gateway.Event), filters ...gateway.Event) {
-	eventTypes := make([]reflect.Type, len(filters))
-	for i, filter := range filters {
-		eventTypes[i] = reflect.TypeOf(filter)
-	}
-
-	ref := coreglib.NewWeakRef(w)
-
-	var unbind func()
-	bind := func() {
-		if unbind != nil {
-			return
-		}
-
-		w := ref.Get()
-		slog.Debug(
-			"binding state handler lifetime to widget",
-			"widget_type", fmt.Sprintf("%T", w),
-			"event_types", eventTypes)
-
-		unbind = s.AddSyncHandler(func(ev gateway.Event) {
-			// Optionally filter out events.
-			if len(eventTypes) > 0 {
-				evType := reflect.TypeOf(ev)
-
-				for _, typ := range eventTypes {
-					if typ == evType {
-						goto filtered
-					}
-				}
-
-				return
-			}
-
-		filtered:
-			glib.IdleAddPriority(glib.PriorityDefault, func() { fn(ev) })
-		})
-	}
-
-	bind()
-
-	base := gtk.BaseWidget(w)
-	base.NotifyProperty("parent", func() {
-		if base.Parent() != nil {
-			return
-		}
-
-		if unbind != nil {
-			unbind()
-			unbind = nil
-
-			slog.Debug(
-				"widget unparented, unbinded handler",
-				"func", "BindWidget",
-				"widget_type", gtk.BaseWidget(w).Type())
-		}
-	})
-	base.ConnectDestroy(func() {
-		if unbind != nil {
-			unbind()
-			unbind = nil
-
-			slog.Debug(
-				"widget destroyed, unbinded handler",
-				"func", "BindWidget",
-				"widget_type", gtk.BaseWidget(w).Type())
-		}
-	})
-}
-
 // AddHandler adds a handler to the state. The handler is removed when the
 // returned function is called.
 func (s *State) AddHandler(fns ...any) func() {
-	if len(fns) == 1 {
-		return s.MainThreadHandler.AddHandler(fns[0])
-	}
+	return s.addHandler(nil, handlerSite(), fns)
+}
 
-	unbinds := make([]func(), 0, len(fns))
+// addHandler adds the handlers and records them as owned by w and added from
+// site if handler tracking is enabled. w may be nil.
+func (s *State) addHandler(w gtk.Widgetter, site string, fns []any) func() {
+	unbinds := make([]func(), 0, len(fns)+1)
 	for _, fn := range fns {
 		unbind := s.MainThreadHandler.AddHandler(fn)
 		unbinds = append(unbinds, unbind)
 	}
 
+	if HandlerTrackingEnabled {
+		unbinds = append(unbinds, handlers.track(w, site))
+	}
+
+	if len(unbinds) == 1 {
+		return unbinds[0]
+	}
+
 	return func() {
 		for _, unbind := range unbinds {
 			unbind()
@@ -324,10 +239,13 @@ func (s *State) AddHandler(fns ...any) func() {
 	}
 }
 
-// AddHandlerForWidget replaces BindWidget and provides a way to bind a handler
-// that only receives events as long as the widget is mapped. As soon as the
-// widget is unmapped, the handler is unbound.
+// AddHandlerForWidget provides a way to bind a handler that only receives
+// events as long as the widget is mapped. As soon as the widget is unmapped,
+// the handler is unbound. See On for a typed variant.
 func (s *State) AddHandlerForWidget(w gtk.Widgetter, fns ...any) func() {
+	// The site is taken now, since bind is called again from a signal
+	// handler when the widget is reparented.
+	site := handlerSite()
 	unbinds := make([]func(), 0, len(fns))
 
 	unbind := func() {
@@ -338,10 +256,7 @@ func (s *State) AddHandlerForWidget(w gtk.Widgetter, fns ...any) func() {
 	}
 
 	bind := func() {
-		for _, fn := range fns {
-			unbind := s.AddHandler(fn)
-			unbinds = append(unbinds, unbind)
-		}
+		unbinds = append(unbinds, s.addHandler(w, site, fns))
 	}
 
 	bind()
@@ -507,6 +422,21 @@ func (s *State) MessagePreview(msg *discord.Message) string {
 	return ""
 }
 
+// GuildMentionCount returns the number of unread mentions in all channels of
+// the guild.
+func (s *State) GuildMentionCount(guildID discord.GuildID) int {
+	var mentions int
+
+	chs, _ := s.Cabinet.Channels(guildID)
+	for _, ch := range chs {
+		if read := s.ReadState.ReadState(ch.ID); read != nil {
+			mentions += read.MentionCount
+		}
+	}
+
+	return mentions
+}
+
 // InjectAvatarSize calls InjectSize with size being 64px.
 func InjectAvatarSize(urlstr string) string {
 	return InjectSize(urlstr, 64)
@@ -678,3 +608,23 @@ func NewGuildIDVariant(id discord.GuildID) *glib.Variant {
 func NewMessageIDVariant(id discord.MessageID) *glib.Variant {
 	return glib.NewVariantInt64(int64(id))
 }
+
+// AccountChannelVariant is the variant type for a channel of an account, which
+// is a tuple of the account's user ID and the channel ID.
+var AccountChannelVariant = glib.NewVariantType("(xx)")
+
+// NewAccountChannelVariant creates a new account channel variant.
+func NewAccountChannelVariant(userID discord.UserID, chID discord.ChannelID) *glib.Variant {
+	return glib.NewVariantTuple([]*glib.Variant{
+		glib.NewVariantInt64(int64(userID)),
+		glib.NewVariantInt64(int64(chID)),
+	})
+}
+
+// AccountChannelFromVariant returns the user ID and channel ID within an
+// account channel variant.
+func AccountChannelFromVariant(v *glib.Variant) (discord.UserID, discord.ChannelID) {
+	userID := discord.UserID(v.ChildValue(0).Int64())
+	chID := discord.ChannelID(v.ChildValue(1).Int64())
+	return userID, chID
+}
@@ -3,6 +3,7 @@ package composer
 import (
 	"context"
 	"fmt"
+	"html"
 	"io"
 	"log/slog"
 	"os"
@@ -138,6 +139,11 @@ type View struct {
 	msgLengthToast *adw.Toast
 	isOverLimit    bool
 
+	// placeholder is the markup of the placeholder that is shown while
+	// online.
+	placeholder string
+	offline     bool
+
 	state struct {
 		id       discord.MessageID
 		editing  bool
@@ -308,11 +314,39 @@ func (v *View) SetPlaceholderMarkup(markup string) {
 		return
 	}
 
-	v.Placeholder.SetMarkup(markup)
+	v.setPlaceholder(markup)
+}
+
+// SetOffline disables the composer while the gateway is disconnected, since
+// nothing can be sent until it reconnects. What's typed so far is kept, along
+// with the message being edited or replied to.
+func (v *View) SetOffline(offline bool) {
+	if v.offline == offline {
+		return
+	}
+
+	v.offline = offline
+	if offline {
+		v.Placeholder.SetText(locale.Get("You are offline. Messages can be sent once Discord reconnects."))
+	} else {
+		v.Placeholder.SetMarkup(v.placeholder)
+	}
+
+	v.topBox.SetSensitive(!offline)
+	v.UploadTray.SetSensitive(!offline)
 }
 
 func (v *View) ResetPlaceholder() {
-	v.Placeholder.SetText("Message " + gtkcord.ChannelNameFromID(v.ctx, v.chID))
+	v.setPlaceholder(html.EscapeString("Message " + gtkcord.ChannelNameFromID(v.ctx, v.chID)))
+}
+
+// setPlaceholder sets the placeholder that is shown while online. It is kept
+// until the composer is back online otherwise.
+func (v *View) setPlaceholder(markup string) {
+	v.placeholder = markup
+	if !v.offline {
+		v.Placeholder.SetMarkup(markup)
+	}
 }
 
 // actionButton is a button that is used in the composer bar.
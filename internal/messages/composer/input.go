@@ -24,6 +24,7 @@ import (
 	"github.com/diamondburned/gotkit/utils/osutil"
 	"github.com/pkg/errors"
 	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/shortcuts"
 )
 
 var persistInput = prefs.NewBool(true, prefs.PropMeta{
@@ -83,7 +84,7 @@ var inputWYSIWYG = prefs.NewBool(true, prefs.PropMeta{
 })
 
 // inputStateKey is the app state that stores the last input message.
-var inputStateKey = app.NewStateKey[string]("input-state")
+var inputStateKey = gtkcord.NewAccountStateKey[string]("input-state")
 
 var inputStateMemory sync.Map // map[discord.ChannelID]string
 
@@ -238,11 +239,34 @@ var sendOnEnter = prefs.NewBool(true, prefs.PropMeta{
 })
 
 func (i *Input) onKey(val, _ uint, state gdk.ModifierType) bool {
+	// Autocompletion takes over the keys for moving around its list.
 	switch val {
-	case gdk.KEY_Return:
+	case gdk.KEY_Return, gdk.KEY_Tab:
 		if i.ac.Select() {
 			return true
 		}
+	case gdk.KEY_Up:
+		if i.ac.MoveUp() {
+			return true
+		}
+	case gdk.KEY_Down:
+		if i.ac.MoveDown() {
+			return true
+		}
+	}
+
+	switch {
+	case !shortcuts.IsDefault("composer.send") && shortcuts.Matches("composer.send", val, state):
+		// A shortcut that the user picked always sends. Only Enter, the
+		// default, can be turned off and adds a new line in a code block.
+		i.ctrl.Send()
+		return true
+	case shortcuts.IsDefault("composer.send") && val == gdk.KEY_Return && !state.Has(gdk.ShiftMask):
+		// Enter sends with any modifier except Shift, so Ctrl+Enter and
+		// Alt+Enter keep working.
+		if !sendOnEnter.Value() {
+			break
+		}
 
 		// TODO: find a better way to do this. goldmark won't try to
 		// parse an incomplete codeblock (I think), but the changed
@@ -258,24 +282,16 @@ func (i *Input) onKey(val, _ uint, state gdk.ModifierType) bool {
 		// in one.
 		withinCodeblock := strings.Count(uinput, "```")%2 != 0
 
-		// Enter (without holding Shift) sends the message.
-		if sendOnEnter.Value() && !state.Has(gdk.ShiftMask) && !withinCodeblock {
+		if !withinCodeblock {
 			i.ctrl.Send()
 			return true
 		}
-	case gdk.KEY_Tab:
-		return i.ac.Select()
-	case gdk.KEY_Escape:
+	case shortcuts.Matches("composer.escape", val, state):
 		return i.ctrl.Escape()
-	case gdk.KEY_Up:
-		if i.ac.MoveUp() {
-			return true
-		}
+	case shortcuts.Matches("composer.edit-last-message", val, state):
 		if i.Buffer.CharCount() == 0 {
 			return i.ctrl.EditLastMessage()
 		}
-	case gdk.KEY_Down:
-		return i.ac.MoveDown()
 	}
 
 	return false
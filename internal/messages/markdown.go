@@ -10,6 +10,7 @@ import (
 	"github.com/diamondburned/chatkit/md/mdrender"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/components/onlineimage"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/gotkit/gtkutil/imgutil"
@@ -42,6 +43,8 @@ var renderers = []mdrender.OptionFunc{
 	mdrender.WithRenderer(discordmd.KindEmoji, renderEmoji),
 	mdrender.WithRenderer(discordmd.KindInline, renderInline),
 	mdrender.WithRenderer(discordmd.KindMention, renderMention),
+	mdrender.WithRenderer(ast.KindLink, renderLink),
+	mdrender.WithRenderer(ast.KindAutoLink, renderAutoLink),
 }
 
 var inlineEmojiTag = textutil.TextTag{
@@ -225,3 +228,72 @@ func renderMention(ctx context.Context, r *mdrender.Renderer, n ast.Node) ast.Wa
 
 	return ast.WalkContinue
 }
+
+// bindLinkHandler makes links in the text block open through openLink. It must
+// be bound before mdrender binds its own handler, since only the first one is
+// used.
+func bindLinkHandler(ctx context.Context, text *block.TextBlock) {
+	md.BindLinkHandler(text.TextView, func(uri string) {
+		openLink(ctx, text.TextView, uri)
+	})
+}
+
+// openLink opens the given URI. Links to Discord channels and messages are
+// opened within Dissent instead of the browser.
+func openLink(ctx context.Context, w gtk.Widgetter, uri string) {
+	link, ok := gtkcord.ParseLink(uri)
+	if !ok {
+		app.OpenURI(ctx, uri)
+		return
+	}
+
+	slog.Debug(
+		"opening Discord link",
+		"channel_id", link.ChannelID,
+		"message_id", link.MessageID)
+
+	widget := gtk.BaseWidget(w)
+	if link.MessageID.IsValid() {
+		widget.ActivateAction("app.open-message", gtkcord.NewMessageVariant(link.ChannelID, link.MessageID))
+	} else {
+		widget.ActivateAction("app.open-channel", gtkcord.NewChannelIDVariant(link.ChannelID))
+	}
+}
+
+// renderLink renders the link the same way as mdrender does, except that it
+// goes through openLink.
+func renderLink(ctx context.Context, r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
+	link := n.(*ast.Link)
+
+	text := r.State(ctx).TextBlock()
+	bindLinkHandler(ctx, text)
+
+	if string(link.Title) != "" {
+		text.Insert(string(link.Title))
+	}
+
+	startIx := text.Iter.Offset()
+	status := r.RenderChildren(ctx, n)
+
+	start := text.Buffer.IterAtOffset(startIx)
+	text.ApplyLink(string(link.Destination), start, text.Iter)
+
+	return status
+}
+
+// renderAutoLink is renderLink for links that are written out as is.
+func renderAutoLink(ctx context.Context, r *mdrender.Renderer, n ast.Node) ast.WalkStatus {
+	link := n.(*ast.AutoLink)
+	url := string(link.URL(r.Source()))
+
+	text := r.State(ctx).TextBlock()
+	bindLinkHandler(ctx, text)
+
+	startIx := text.Iter.Offset()
+	text.Insert(url)
+
+	start := text.Buffer.IterAtOffset(startIx)
+	text.ApplyLink(url, start, text.Iter)
+
+	return ast.WalkContinue
+}
@@ -87,6 +87,18 @@ type View struct {
 	summaries map[discord.Snowflake]messageSummaryWidget
 
 	state viewState
+	// stale is true if only cached messages are shown. The latest ones are
+	// fetched once the gateway reconnects.
+	stale bool
+	// detached is true if older messages around a jumped-to message are shown
+	// instead of the latest ones. New messages are not added until the latest
+	// ones are fetched again.
+	detached bool
+
+	// onJump is called when the user jumps to a message.
+	onJump func(discord.MessageID)
+	// anchor is restored once the backlog is loaded.
+	anchor *Anchor
 
 	ctx  context.Context
 	chID discord.ChannelID
@@ -264,156 +276,132 @@ func NewView(ctx context.Context, chID discord.ChannelID) *View {
 		v.guildID = ch.GuildID
 	}
 
-	state.BindWidget(v, func(ev gateway.Event) {
-		switch ev := ev.(type) {
-		case *gateway.MessageCreateEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
-
-			// Use this to update existing messages' members as well.
-			if ev.Member != nil {
-				v.updateMember(ev.Member)
-			}
+	forChannel := gtkcord.ForChannel(v.chID)
+	forGuild := gtkcord.ForGuild(v.guildID)
 
-			if ev.Nonce != "" {
-				// Try and look up the nonce.
-				key := messageKeyNonce(ev.Nonce)
-
-				if msg, ok := v.rows[key]; ok {
-					delete(v.rows, key)
-
-					key = messageKeyID(ev.ID)
-					// Known sent message. Update this instead.
-					v.rows[key] = msg
+	// Cached messages can still be read while offline, but nothing can be
+	// sent until the gateway reconnects.
+	v.Composer.SetOffline(state.IsOffline())
+	gtkcord.OnConnectionChange(state, v, func(offline bool) {
+		v.Composer.SetOffline(offline)
+		if !offline && v.stale {
+			v.FetchBacklog()
+		}
+	})
 
-					msg.ListBoxRow.SetName(string(key))
-					msg.message.Update(ev)
-					return
-				}
-			}
+	gtkcord.On(state, v, func(ev *gateway.MessageCreateEvent) {
+		// Use this to update existing messages' members as well.
+		if ev.Member != nil {
+			v.updateMember(ev.Member)
+		}
 
-			if !v.ignoreMessage(&ev.Message) {
-				msg := v.upsertMessage(ev.ID, newMessageInfo(&ev.Message), 0)
-				msg.Update(ev)
-			}
+		if ev.Nonce != "" {
+			// Try and look up the nonce.
+			key := messageKeyNonce(ev.Nonce)
 
-		case *gateway.MessageUpdateEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
+			if msg, ok := v.rows[key]; ok {
+				delete(v.rows, key)
 
-			m, err := state.Cabinet.Message(ev.ChannelID, ev.ID)
-			if err == nil && !v.ignoreMessage(&ev.Message) {
-				msg := v.upsertMessage(ev.ID, newMessageInfo(m), 0)
-				msg.Update(&gateway.MessageCreateEvent{
-					Message: *m,
-					Member:  ev.Member,
-				})
-			}
+				key = messageKeyID(ev.ID)
+				// Known sent message. Update this instead.
+				v.rows[key] = msg
 
-		case *gateway.MessageDeleteEvent:
-			if ev.ChannelID != v.chID {
+				msg.ListBoxRow.SetName(string(key))
+				msg.message.Update(ev)
 				return
 			}
+		}
 
-			v.deleteMessage(ev.ID)
-
-		case *gateway.MessageReactionAddEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
-			v.updateMessageReactions(ev.MessageID)
+		if v.detached {
+			return
+		}
 
-		case *gateway.MessageReactionRemoveEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
-			v.updateMessageReactions(ev.MessageID)
+		if !v.ignoreMessage(&ev.Message) {
+			msg := v.upsertMessage(ev.ID, newMessageInfo(&ev.Message), 0)
+			msg.Update(ev)
+		}
+	}, forChannel)
+
+	gtkcord.On(state, v, func(ev *gateway.MessageUpdateEvent) {
+		m, err := state.Cabinet.Message(ev.ChannelID, ev.ID)
+		if err == nil && !v.ignoreMessage(&ev.Message) {
+			msg := v.upsertMessage(ev.ID, newMessageInfo(m), 0)
+			msg.Update(&gateway.MessageCreateEvent{
+				Message: *m,
+				Member:  ev.Member,
+			})
+		}
+	}, forChannel)
 
-		case *gateway.MessageReactionRemoveAllEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
-			v.updateMessageReactions(ev.MessageID)
+	gtkcord.On(state, v, func(ev *gateway.MessageDeleteEvent) {
+		v.deleteMessage(ev.ID)
+	}, forChannel)
 
-		case *gateway.MessageReactionRemoveEmojiEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
-			v.updateMessageReactions(ev.MessageID)
+	gtkcord.On(state, v, func(ev *gateway.MessageDeleteBulkEvent) {
+		for _, id := range ev.IDs {
+			v.deleteMessage(id)
+		}
+	}, forChannel)
 
-		case *gateway.MessageDeleteBulkEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
+	gtkcord.On(state, v, func(ev *gateway.MessageReactionAddEvent) {
+		v.updateMessageReactions(ev.MessageID)
+	}, forChannel)
 
-			for _, id := range ev.IDs {
-				v.deleteMessage(id)
-			}
+	gtkcord.On(state, v, func(ev *gateway.MessageReactionRemoveEvent) {
+		v.updateMessageReactions(ev.MessageID)
+	}, forChannel)
 
-		case *gateway.GuildMemberAddEvent:
-			slog.Debug(
-				"GuildMemberAddEvent not implemented",
-				"guildID", ev.GuildID,
-				"userID", ev.User.ID)
+	gtkcord.On(state, v, func(ev *gateway.MessageReactionRemoveAllEvent) {
+		v.updateMessageReactions(ev.MessageID)
+	}, forChannel)
 
-		case *gateway.GuildMemberUpdateEvent:
-			if ev.GuildID != v.guildID {
-				return
-			}
+	gtkcord.On(state, v, func(ev *gateway.MessageReactionRemoveEmojiEvent) {
+		v.updateMessageReactions(ev.MessageID)
+	}, forChannel)
 
-			member, _ := state.Cabinet.Member(ev.GuildID, ev.User.ID)
-			if member != nil {
-				v.updateMember(member)
-			}
+	gtkcord.On(state, v, func(ev *gateway.ConversationSummaryUpdateEvent) {
+		v.updateSummaries(ev.Summaries)
+	}, forChannel)
 
-		case *gateway.GuildMemberRemoveEvent:
-			slog.Debug(
-				"GuildMemberRemoveEvent not implemented",
-				"guildID", ev.GuildID,
-				"userID", ev.User.ID)
-
-		case *gateway.GuildMembersChunkEvent:
-			// TODO: Discord isn't sending us this event. I'm not sure why.
-			// Their client has to work somehow. Maybe they use the right-side
-			// member list?
-			if ev.GuildID != v.guildID {
-				return
-			}
+	gtkcord.On(state, v, func(ev *gateway.GuildMemberAddEvent) {
+		slog.Debug(
+			"GuildMemberAddEvent not implemented",
+			"guildID", ev.GuildID,
+			"userID", ev.User.ID)
+	})
 
-			for i := range ev.Members {
-				v.updateMember(&ev.Members[i])
-			}
+	gtkcord.On(state, v, func(ev *gateway.GuildMemberUpdateEvent) {
+		member, _ := state.Cabinet.Member(ev.GuildID, ev.User.ID)
+		if member != nil {
+			v.updateMember(member)
+		}
+	}, forGuild)
 
-		case *gateway.ConversationSummaryUpdateEvent:
-			if ev.ChannelID != v.chID {
-				return
-			}
+	gtkcord.On(state, v, func(ev *gateway.GuildMemberRemoveEvent) {
+		slog.Debug(
+			"GuildMemberRemoveEvent not implemented",
+			"guildID", ev.GuildID,
+			"userID", ev.User.ID)
+	})
 
-			v.updateSummaries(ev.Summaries)
+	gtkcord.On(state, v, func(ev *gateway.GuildMembersChunkEvent) {
+		// TODO: Discord isn't sending us this event. I'm not sure why.
+		// Their client has to work somehow. Maybe they use the right-side
+		// member list?
+		for i := range ev.Members {
+			v.updateMember(&ev.Members[i])
 		}
-	})
+	}, forGuild)
 
 	gtkutil.BindActionCallbackMap(v.List, map[string]gtkutil.ActionCallback{
 		"messages.scroll-to": {
 			ArgType: gtkcord.SnowflakeVariant,
 			Func: func(args *glib.Variant) {
 				id := discord.MessageID(args.Int64())
-
-				msg, ok := v.rows[messageKeyID(id)]
-				if !ok {
-					slog.Warn(
-						"tried to scroll to non-existent message",
-						"id", id)
-					return
-				}
-
-				if !msg.ListBoxRow.GrabFocus() {
-					slog.Warn(
-						"failed to grab focus of message",
-						"id", id)
+				if v.onJump != nil {
+					v.onJump(id)
 				}
+				v.scrollToMessage(id)
 			},
 		},
 	})
@@ -544,19 +532,49 @@ func (v *View) FetchBacklog() {
 		"loading message view",
 		"channel", v.chID)
 
-	v.LoadablePage.SetLoading()
 	v.unload()
+	v.detached = false
 
 	state := gtkcord.FromContext(v.ctx)
 
+	// Show whatever messages we already have, such as the ones restored from
+	// the on-disk cache, while the latest ones are being fetched.
+	cached, _ := state.Cabinet.Messages(v.chID)
+	if len(cached) > 0 {
+		v.AddBacklog(slices.Clone(cached))
+		v.restoreAnchor()
+	} else {
+		v.LoadablePage.SetLoading()
+	}
+
+	// Don't bother fetching while offline if there's something to read.
+	v.stale = len(cached) > 0
+	if v.stale && state.IsOffline() {
+		return
+	}
+
 	gtkutil.Async(v.ctx, func() func() {
 		msgs, err := state.Online().Messages(v.chID, 15)
 		if err != nil {
+			if len(cached) > 0 {
+				slog.Warn(
+					"cannot fetch messages, showing cached messages only",
+					"channel", v.chID,
+					"err", err)
+				return nil
+			}
 			return func() { v.LoadablePage.SetError(err) }
 		}
 
 		return func() {
 			state := gtkcord.FromContext(v.ctx)
+			v.stale = false
+
+			// Drop the cached messages, since some of them may have been
+			// deleted or edited in the meantime.
+			if len(cached) > 0 {
+				v.unload()
+			}
 
 			ch, _ := state.Cabinet.Channel(v.chID)
 			if ch == nil {
@@ -571,6 +589,8 @@ func (v *View) FetchBacklog() {
 			}
 
 			v.AddBacklog(msgs)
+			v.restoreAnchor()
+			v.anchor = nil
 		}
 	})
 }
@@ -1120,7 +1140,138 @@ func (v *View) SendMessage(sendingMsg composer.SendingMessage) {
 	})
 }
 
-// ScrollToMessage scrolls to the message with the given ID.
+// Anchor is a position in the message view that can be returned to.
+type Anchor struct {
+	// MessageID is the message that was jumped to. If it is 0, then FromBottom
+	// is used instead.
+	MessageID discord.MessageID
+	// FromBottom is how far up the view was scrolled from the bottom.
+	FromBottom float64
+}
+
+// Anchor returns the current scroll position of the view.
+func (v *View) Anchor() Anchor {
+	vadj := v.Scroll.VAdjustment()
+	return Anchor{
+		FromBottom: max(vadj.Upper()-vadj.PageSize()-vadj.Value(), 0),
+	}
+}
+
+// RestoreAnchor scrolls the view back to the given anchor, or once its backlog
+// is loaded if it isn't yet. The message must be within the backlog to be
+// scrolled to.
+func (v *View) RestoreAnchor(anchor Anchor) {
+	v.anchor = &anchor
+	if len(v.rows) > 0 {
+		// The backlog is already loaded.
+		v.restoreAnchor()
+		if !v.stale {
+			v.anchor = nil
+		}
+	}
+}
+
+func (v *View) restoreAnchor() {
+	anchor := v.anchor
+	if anchor == nil {
+		return
+	}
+
+	// Wait until the view has finished scrolling to the bottom of the newly
+	// added messages.
+	glib.IdleAddPriority(glib.PriorityLow, func() {
+		if anchor.MessageID.IsValid() {
+			v.scrollToMessage(anchor.MessageID)
+			return
+		}
+
+		vadj := v.Scroll.VAdjustment()
+		v.Scroll.Unbottom()
+		vadj.SetValue(vadj.Upper() - vadj.PageSize() - anchor.FromBottom)
+	})
+}
+
+// OnJump registers the given function to be called when the user jumps to a
+// message, such as by clicking on a reply.
+func (v *View) OnJump(f func(discord.MessageID)) {
+	v.onJump = f
+}
+
+func (v *View) scrollToMessage(id discord.MessageID) {
+	if _, ok := v.rows[messageKeyID(id)]; !ok {
+		// The message is older than the loaded ones.
+		v.loadAround(id)
+		return
+	}
+
+	v.focusMessage(id)
+}
+
+func (v *View) focusMessage(id discord.MessageID) {
+	msg, ok := v.rows[messageKeyID(id)]
+	if !ok {
+		slog.Warn(
+			"tried to scroll to non-existent message",
+			"id", id)
+		return
+	}
+
+	if !msg.ListBoxRow.GrabFocus() {
+		slog.Warn(
+			"failed to grab focus of message",
+			"id", id)
+	}
+}
+
+// loadAround replaces the loaded messages with the ones around the message
+// with the given ID and scrolls to it. The view stays detached from the latest
+// messages until the user jumps back to them.
+func (v *View) loadAround(id discord.MessageID) {
+	slog.Debug(
+		"loading messages around message",
+		"channel", v.chID,
+		"id", id)
+
+	ctx := v.ctx
+	state := gtkcord.FromContext(ctx).Online()
+
+	gtkutil.Async(ctx, func() func() {
+		msgs, err := state.MessagesAround(v.chID, id, loadMoreBatch)
+		if err != nil {
+			slog.Error(
+				"cannot load messages around message",
+				"channel", v.chID,
+				"id", id,
+				"err", err)
+			return func() { v.AddToast(adw.NewToast(locale.Get("Cannot load message"))) }
+		}
+
+		return func() {
+			if !slices.ContainsFunc(msgs, func(m discord.Message) bool { return m.ID == id }) {
+				v.AddToast(adw.NewToast(locale.Get("Message not found")))
+				return
+			}
+
+			v.unload()
+			v.detached = true
+			v.AddBacklog(msgs)
+			v.LoadMore.SetSensitive(true)
+
+			toast := adw.NewToast(locale.Get("Viewing older messages"))
+			toast.SetTimeout(0)
+			toast.SetButtonLabel(locale.Get("Jump to Present"))
+			toast.ConnectButtonClicked(v.FetchBacklog)
+			v.AddToast(toast)
+
+			// Wait until the view has finished scrolling to the bottom of
+			// the newly added messages, like restoreAnchor.
+			glib.IdleAddPriority(glib.PriorityLow, func() { v.focusMessage(id) })
+		}
+	})
+}
+
+// ScrollToMessage scrolls to the message with the given ID. This counts as the
+// user jumping to the message.
 func (v *View) ScrollToMessage(id discord.MessageID) {
 	if !v.List.ActivateAction("messages.scroll-to", gtkcord.NewMessageIDVariant(id)) {
 		slog.Error(
@@ -1282,7 +1433,8 @@ func (v *View) delete(id discord.MessageID) {
 }
 
 func (v *View) onScrollBottomed() {
-	if v.IsActive() {
+	// The bottom isn't the latest message while detached.
+	if v.IsActive() && !v.detached {
 		v.MarkRead()
 	}
 
@@ -0,0 +1,168 @@
+package passwordauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+)
+
+// Error codes that the login endpoints are known to return.
+const (
+	CodeInvalidFormBody  = 50035
+	CodeInvalidTwoFactor = 60008
+)
+
+// Error is a structured error returned by Discord.
+type Error struct {
+	Status  int
+	Code    int
+	Message string
+	// Fields are the errors for each field of the request, if any. They are
+	// sorted by field.
+	Fields []FieldError
+}
+
+// FieldError is an error for a single field of the request, such as the
+// password.
+type FieldError struct {
+	// Field is the path to the field, such as "password".
+	Field string
+	// Code is Discord's code for the error, such as "INVALID_LOGIN".
+	Code    string
+	Message string
+}
+
+// Error returns the field errors if there are any, since they are more useful
+// than the generic "Invalid Form Body" message.
+func (err *Error) Error() string {
+	if len(err.Fields) == 0 {
+		if err.Message != "" {
+			return err.Message
+		}
+		return fmt.Sprintf("Discord returned error code %d", err.Code)
+	}
+
+	// Discord often repeats the same message for several fields, such as
+	// both the login and the password.
+	msgs := make([]string, 0, len(err.Fields))
+	for _, field := range err.Fields {
+		if !slices.Contains(msgs, field.Message) {
+			msgs = append(msgs, field.Message)
+		}
+	}
+	return strings.Join(msgs, " ")
+}
+
+// Field returns the error for the field with the given path, if any.
+func (err *Error) Field(path string) (FieldError, bool) {
+	for _, field := range err.Fields {
+		if field.Field == path {
+			return field, true
+		}
+	}
+	return FieldError{}, false
+}
+
+// CaptchaError is returned if Discord wants a captcha to be solved before
+// logging in. Captchas can't be solved within Dissent, so the user has to log
+// in another way.
+type CaptchaError struct {
+	Service string
+	SiteKey string
+	// Keys is Discord's reason for the captcha, such as "captcha-required".
+	Keys []string
+}
+
+func (err *CaptchaError) Error() string {
+	return "Discord wants a captcha to be solved, which is not supported. " +
+		"Try logging in using a QR code or a token instead."
+}
+
+// wrapError turns Discord's error responses into an *Error or a
+// *CaptchaError. Other errors are returned as is.
+func wrapError(err error) error {
+	var httpErr *httputil.HTTPError
+	if err == nil || !errors.As(err, &httpErr) {
+		return err
+	}
+
+	var body struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Errors  json.RawMessage `json:"errors"`
+
+		CaptchaKey     []string `json:"captcha_key"`
+		CaptchaSiteKey string   `json:"captcha_sitekey"`
+		CaptchaService string   `json:"captcha_service"`
+	}
+	if json.Unmarshal(httpErr.Body, &body) != nil {
+		return err
+	}
+
+	if len(body.CaptchaKey) > 0 {
+		return &CaptchaError{
+			Service: body.CaptchaService,
+			SiteKey: body.CaptchaSiteKey,
+			Keys:    body.CaptchaKey,
+		}
+	}
+
+	if body.Code == 0 && body.Message == "" {
+		return err
+	}
+
+	apiErr := &Error{
+		Status:  httpErr.Status,
+		Code:    body.Code,
+		Message: body.Message,
+	}
+
+	if len(body.Errors) > 0 {
+		var tree map[string]json.RawMessage
+		if json.Unmarshal(body.Errors, &tree) == nil {
+			apiErr.Fields = flattenFieldErrors(nil, "", tree)
+			sort.SliceStable(apiErr.Fields, func(i, j int) bool {
+				return apiErr.Fields[i].Field < apiErr.Fields[j].Field
+			})
+		}
+	}
+
+	return apiErr
+}
+
+// flattenFieldErrors walks Discord's nested error object, which looks like
+// {"login": {"_errors": [{"code": "...", "message": "..."}]}}.
+func flattenFieldErrors(dst []FieldError, path string, tree map[string]json.RawMessage) []FieldError {
+	for key, raw := range tree {
+		if key == "_errors" {
+			var errs []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(raw, &errs) == nil {
+				for _, e := range errs {
+					dst = append(dst, FieldError{Field: path, Code: e.Code, Message: e.Message})
+				}
+			}
+			continue
+		}
+
+		var subtree map[string]json.RawMessage
+		if json.Unmarshal(raw, &subtree) != nil {
+			continue
+		}
+
+		subpath := key
+		if path != "" {
+			subpath = path + "." + key
+		}
+
+		dst = flattenFieldErrors(dst, subpath, subtree)
+	}
+	return dst
+}
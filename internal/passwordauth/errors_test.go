@@ -0,0 +1,155 @@
+package passwordauth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+)
+
+func TestWrapError(t *testing.T) {
+	otherErr := errors.New("connection refused")
+
+	httpError := func(body string) error {
+		return &httputil.HTTPError{Status: 400, Body: []byte(body)}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		// same is true if the error should be returned as is.
+		same    bool
+		want    error
+		wantMsg string
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			same: true,
+		},
+		{
+			name: "not an HTTP error",
+			err:  otherErr,
+			same: true,
+		},
+		{
+			name: "invalid JSON",
+			err:  httpError("<html>Bad Gateway</html>"),
+			same: true,
+		},
+		{
+			name: "no code or message",
+			err:  httpError(`{}`),
+			same: true,
+		},
+		{
+			name:    "message",
+			err:     httpError(`{"code": 60008, "message": "Invalid two-factor code"}`),
+			want:    &Error{Status: 400, Code: CodeInvalidTwoFactor, Message: "Invalid two-factor code"},
+			wantMsg: "Invalid two-factor code",
+		},
+		{
+			name:    "code only",
+			err:     httpError(`{"code": 12345}`),
+			want:    &Error{Status: 400, Code: 12345},
+			wantMsg: "Discord returned error code 12345",
+		},
+		{
+			name: "field errors",
+			err: httpError(`{
+				"code": 50035,
+				"message": "Invalid Form Body",
+				"errors": {
+					"password": {"_errors": [{"code": "INVALID_LOGIN", "message": "Login or password is invalid."}]},
+					"login": {"_errors": [{"code": "INVALID_LOGIN", "message": "Login or password is invalid."}]}
+				}
+			}`),
+			want: &Error{
+				Status:  400,
+				Code:    CodeInvalidFormBody,
+				Message: "Invalid Form Body",
+				Fields: []FieldError{
+					{Field: "login", Code: "INVALID_LOGIN", Message: "Login or password is invalid."},
+					{Field: "password", Code: "INVALID_LOGIN", Message: "Login or password is invalid."},
+				},
+			},
+			wantMsg: "Login or password is invalid.",
+		},
+		{
+			name: "nested field errors",
+			err: httpError(`{
+				"code": 50035,
+				"message": "Invalid Form Body",
+				"errors": {
+					"login": {
+						"email": {"_errors": [{"code": "EMAIL_TYPE_INVALID_EMAIL", "message": "Not a well formed email address."}]}
+					},
+					"code": {"_errors": [{"code": "BASE_TYPE_MAX_LENGTH", "message": "Must be 8 or fewer in length."}]}
+				}
+			}`),
+			want: &Error{
+				Status:  400,
+				Code:    CodeInvalidFormBody,
+				Message: "Invalid Form Body",
+				Fields: []FieldError{
+					{Field: "code", Code: "BASE_TYPE_MAX_LENGTH", Message: "Must be 8 or fewer in length."},
+					{Field: "login.email", Code: "EMAIL_TYPE_INVALID_EMAIL", Message: "Not a well formed email address."},
+				},
+			},
+			wantMsg: "Must be 8 or fewer in length. Not a well formed email address.",
+		},
+		{
+			name: "captcha",
+			err: httpError(`{
+				"captcha_key": ["captcha-required"],
+				"captcha_sitekey": "site-key",
+				"captcha_service": "hcaptcha"
+			}`),
+			want: &CaptchaError{
+				Service: "hcaptcha",
+				SiteKey: "site-key",
+				Keys:    []string{"captcha-required"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := wrapError(test.err)
+
+			if test.same {
+				if err != test.err {
+					t.Fatalf("expected the error to be returned as is, got %v", err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(err, test.want) {
+				t.Fatalf("expected %#v, got %#v", test.want, err)
+			}
+
+			if test.wantMsg != "" && err.Error() != test.wantMsg {
+				t.Errorf("expected message %q, got %q", test.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestErrorField(t *testing.T) {
+	err := &Error{
+		Fields: []FieldError{
+			{Field: "login", Code: "INVALID_LOGIN"},
+			{Field: "password", Code: "PASSWORD_DOES_NOT_MATCH"},
+		},
+	}
+
+	field, ok := err.Field("password")
+	if !ok || field.Code != "PASSWORD_DOES_NOT_MATCH" {
+		t.Errorf("expected the password error, got %+v", field)
+	}
+
+	if _, ok := err.Field("email"); ok {
+		t.Error("expected no error for the email")
+	}
+}
@@ -0,0 +1,218 @@
+// Package passwordauth implements Discord's email and password login, including
+// the ticket-based second factor flow.
+//
+// Logging in is done in steps. Login is called first with the email and
+// password. If the account has two-factor authentication enabled, then it
+// returns a Challenge instead of a token. The challenge lists the methods that
+// the account can use, and its ticket must be passed to Verify together with
+// the code that the user entered.
+package passwordauth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/pkg/errors"
+)
+
+// Endpoints used by this package.
+var (
+	EndpointLogin   = api.EndpointAuth + "login"
+	EndpointTOTP    = api.EndpointAuth + "mfa/totp"
+	EndpointSMS     = api.EndpointAuth + "mfa/sms"
+	EndpointSMSSend = api.EndpointAuth + "mfa/sms/send"
+	EndpointBackup  = api.EndpointAuth + "mfa/backup"
+)
+
+// Method is a second factor that an account may use.
+type Method string
+
+const (
+	// TOTP is a code from an authenticator app.
+	TOTP Method = "totp"
+	// SMS is a code sent to the account's phone number.
+	SMS Method = "sms"
+	// Backup is one of the account's backup codes.
+	Backup Method = "backup"
+	// WebAuthn is a security key. It is not supported, but it is listed so
+	// that the user can be told why.
+	WebAuthn Method = "webauthn"
+)
+
+// CodeLength returns the usual length of a code for the method. It returns 0 if
+// the method doesn't use codes.
+func (m Method) CodeLength() int {
+	switch m {
+	case TOTP, SMS:
+		return 6
+	case Backup:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// endpoint returns the endpoint that verifies the code.
+func (m Method) endpoint() string {
+	switch m {
+	case TOTP:
+		return EndpointTOTP
+	case SMS:
+		return EndpointSMS
+	case Backup:
+		return EndpointBackup
+	default:
+		return ""
+	}
+}
+
+// Challenge is returned by Login if the account needs a second factor.
+type Challenge struct {
+	UserID discord.UserID
+	// Ticket identifies this login attempt. It expires after a few minutes.
+	Ticket string
+	// Methods are the methods that the account can use, in the order that
+	// they should be offered.
+	Methods []Method
+}
+
+// Supports returns true if the challenge can be completed using the method.
+func (c *Challenge) Supports(m Method) bool {
+	return slices.Contains(c.Methods, m)
+}
+
+// ErrWebAuthnRequired is returned if the only second factor that the account
+// can use is a security key.
+var ErrWebAuthnRequired = errors.New("this account can only use a security key as its second factor, which is not supported")
+
+// loginResponse is the response of the login and verify endpoints.
+type loginResponse struct {
+	UserID discord.UserID `json:"user_id"`
+	Token  string         `json:"token"`
+
+	Ticket   string  `json:"ticket"`
+	MFA      bool    `json:"mfa"`
+	TOTP     bool    `json:"totp"`
+	SMS      bool    `json:"sms"`
+	Backup   bool    `json:"backup"`
+	WebAuthn *string `json:"webauthn"`
+}
+
+// Client logs in. It must not have a token.
+type Client struct {
+	*api.Client
+}
+
+// NewClient creates a new Client using the given API client.
+func NewClient(c *api.Client) *Client {
+	return &Client{c}
+}
+
+// Login logs in using the given email or phone number and password. If the
+// account needs a second factor, then the token is empty and a Challenge is
+// returned instead.
+func (c *Client) Login(ctx context.Context, login, password string) (string, *Challenge, error) {
+	var body struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+		Undelete bool   `json:"undelete"`
+	}
+	body.Login = login
+	body.Password = password
+
+	var resp loginResponse
+	if err := c.request(ctx, &resp, EndpointLogin, body); err != nil {
+		return "", nil, err
+	}
+
+	if !resp.MFA {
+		if resp.Token == "" {
+			return "", nil, errors.New("Discord returned neither a token nor a second factor challenge")
+		}
+		return resp.Token, nil, nil
+	}
+
+	challenge := &Challenge{
+		UserID: resp.UserID,
+		Ticket: resp.Ticket,
+	}
+	if resp.TOTP {
+		challenge.Methods = append(challenge.Methods, TOTP)
+	}
+	if resp.SMS {
+		challenge.Methods = append(challenge.Methods, SMS)
+	}
+	if resp.Backup {
+		challenge.Methods = append(challenge.Methods, Backup)
+	}
+
+	if len(challenge.Methods) == 0 {
+		if resp.WebAuthn != nil {
+			return "", nil, ErrWebAuthnRequired
+		}
+		return "", nil, errors.New("Discord asked for a second factor but offered no methods")
+	}
+
+	if resp.WebAuthn != nil {
+		challenge.Methods = append(challenge.Methods, WebAuthn)
+	}
+
+	return "", challenge, nil
+}
+
+// SendSMS asks Discord to send a code to the account's phone number. It returns
+// the redacted phone number that the code was sent to. It can be called again
+// to resend the code.
+func (c *Client) SendSMS(ctx context.Context, ticket string) (string, error) {
+	var body struct {
+		Ticket string `json:"ticket"`
+	}
+	body.Ticket = ticket
+
+	var resp struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.request(ctx, &resp, EndpointSMSSend, body); err != nil {
+		return "", err
+	}
+
+	return resp.Phone, nil
+}
+
+// Verify completes the challenge with the given ticket using the code from the
+// given method. It returns the token.
+func (c *Client) Verify(ctx context.Context, method Method, ticket, code string) (string, error) {
+	endpoint := method.endpoint()
+	if endpoint == "" {
+		return "", errors.Errorf("cannot verify using %s", method)
+	}
+
+	var body struct {
+		Code   string `json:"code"`
+		Ticket string `json:"ticket"`
+	}
+	body.Code = code
+	body.Ticket = ticket
+
+	var resp loginResponse
+	if err := c.request(ctx, &resp, endpoint, body); err != nil {
+		return "", err
+	}
+
+	if resp.Token == "" {
+		return "", errors.New("Discord accepted the code but returned no token")
+	}
+
+	return resp.Token, nil
+}
+
+func (c *Client) request(ctx context.Context, resp any, endpoint string, body any) error {
+	err := c.Client.WithContext(ctx).RequestJSON(
+		resp, "POST", endpoint,
+		httputil.WithJSONBody(body),
+	)
+	return wrapError(err)
+}
@@ -0,0 +1,276 @@
+package passwordauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/pkg/errors"
+)
+
+// newTestClient points the endpoints at a server that calls handler and
+// returns a client without a token.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	endpoints := []*string{
+		&EndpointLogin,
+		&EndpointTOTP,
+		&EndpointSMS,
+		&EndpointSMSSend,
+		&EndpointBackup,
+	}
+	for _, endpoint := range endpoints {
+		old := *endpoint
+		*endpoint = srv.URL + "/" + strings.TrimPrefix(old, api.EndpointAuth)
+		t.Cleanup(func() { *endpoint = old })
+	}
+
+	return NewClient(api.NewClient(""))
+}
+
+// respond returns a handler that replies to every request with the given
+// status and body.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		token     string
+		challenge *Challenge
+		// check checks the error. The error must be nil if it is nil.
+		check func(error) bool
+	}{
+		{
+			name:   "token",
+			status: 200,
+			body:   `{"user_id": "1", "token": "secret-token"}`,
+			token:  "secret-token",
+		},
+		{
+			name:   "no token",
+			status: 200,
+			body:   `{"user_id": "1"}`,
+			check:  func(err error) bool { return err != nil },
+		},
+		{
+			name:   "authenticator app and backup codes",
+			status: 200,
+			body:   `{"user_id": "1", "ticket": "ticket", "mfa": true, "totp": true, "backup": true}`,
+			challenge: &Challenge{
+				UserID:  1,
+				Ticket:  "ticket",
+				Methods: []Method{TOTP, Backup},
+			},
+		},
+		{
+			name:   "every method",
+			status: 200,
+			body: `{"user_id": "1", "ticket": "ticket", "mfa": true,
+				"totp": true, "sms": true, "backup": true, "webauthn": "{}"}`,
+			challenge: &Challenge{
+				UserID:  1,
+				Ticket:  "ticket",
+				Methods: []Method{TOTP, SMS, Backup, WebAuthn},
+			},
+		},
+		{
+			name:   "security key only",
+			status: 200,
+			body:   `{"user_id": "1", "ticket": "ticket", "mfa": true, "webauthn": "{}"}`,
+			check:  func(err error) bool { return errors.Is(err, ErrWebAuthnRequired) },
+		},
+		{
+			name:   "no methods",
+			status: 200,
+			body:   `{"user_id": "1", "ticket": "ticket", "mfa": true}`,
+			check:  func(err error) bool { return err != nil && !errors.Is(err, ErrWebAuthnRequired) },
+		},
+		{
+			name:   "captcha",
+			status: 400,
+			body:   `{"captcha_key": ["captcha-required"], "captcha_sitekey": "site-key", "captcha_service": "hcaptcha"}`,
+			check: func(err error) bool {
+				var captchaErr *CaptchaError
+				return errors.As(err, &captchaErr) && captchaErr.Service == "hcaptcha"
+			},
+		},
+		{
+			name:   "invalid login",
+			status: 400,
+			body: `{"code": 50035, "message": "Invalid Form Body", "errors": {
+				"login": {"_errors": [{"code": "INVALID_LOGIN", "message": "Login or password is invalid."}]}
+			}}`,
+			check: func(err error) bool {
+				var apiErr *Error
+				if !errors.As(err, &apiErr) || apiErr.Code != CodeInvalidFormBody {
+					return false
+				}
+				_, ok := apiErr.Field("login")
+				return ok
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request map[string]any
+
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/login" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				respond(test.status, test.body)(w, r)
+			})
+
+			token, challenge, err := client.Login(context.Background(), "user@example.com", "hunter2")
+
+			if test.check != nil {
+				if !test.check(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+			} else if err != nil {
+				t.Fatal("cannot log in:", err)
+			}
+
+			if token != test.token {
+				t.Errorf("expected token %q, got %q", test.token, token)
+			}
+			if !reflect.DeepEqual(challenge, test.challenge) {
+				t.Errorf("expected challenge %+v, got %+v", test.challenge, challenge)
+			}
+
+			if request["login"] != "user@example.com" || request["password"] != "hunter2" {
+				t.Errorf("unexpected request body %v", request)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		method Method
+		path   string
+		status int
+		body   string
+		token  string
+		// check checks the error. The error must be nil if it is nil.
+		check func(error) bool
+	}{
+		{
+			name:   "authenticator app",
+			method: TOTP,
+			path:   "/mfa/totp",
+			status: 200,
+			body:   `{"token": "secret-token"}`,
+			token:  "secret-token",
+		},
+		{
+			name:   "SMS",
+			method: SMS,
+			path:   "/mfa/sms",
+			status: 200,
+			body:   `{"token": "secret-token"}`,
+			token:  "secret-token",
+		},
+		{
+			name:   "backup code",
+			method: Backup,
+			path:   "/mfa/backup",
+			status: 200,
+			body:   `{"token": "secret-token"}`,
+			token:  "secret-token",
+		},
+		{
+			name:   "no token",
+			method: TOTP,
+			path:   "/mfa/totp",
+			status: 200,
+			body:   `{}`,
+			check:  func(err error) bool { return err != nil },
+		},
+		{
+			name:   "invalid code",
+			method: TOTP,
+			path:   "/mfa/totp",
+			status: 400,
+			body:   `{"code": 60008, "message": "Invalid two-factor code"}`,
+			check: func(err error) bool {
+				var apiErr *Error
+				return errors.As(err, &apiErr) && apiErr.Code == CodeInvalidTwoFactor
+			},
+		},
+		{
+			name:   "security key",
+			method: WebAuthn,
+			check:  func(err error) bool { return err != nil },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request map[string]any
+
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if test.path == "" || r.URL.Path != test.path {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				respond(test.status, test.body)(w, r)
+			})
+
+			token, err := client.Verify(context.Background(), test.method, "ticket", "123456")
+
+			if test.check != nil {
+				if !test.check(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+			} else if err != nil {
+				t.Fatal("cannot verify:", err)
+			}
+
+			if token != test.token {
+				t.Errorf("expected token %q, got %q", test.token, token)
+			}
+
+			if test.path != "" && (request["code"] != "123456" || request["ticket"] != "ticket") {
+				t.Errorf("unexpected request body %v", request)
+			}
+		})
+	}
+}
+
+func TestSendSMS(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mfa/sms/send" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		respond(200, `{"phone": "+*******1234"}`)(w, r)
+	})
+
+	phone, err := client.SendSMS(context.Background(), "ticket")
+	if err != nil {
+		t.Fatal("cannot send SMS:", err)
+	}
+	if phone != "+*******1234" {
+		t.Errorf("expected phone %q, got %q", "+*******1234", phone)
+	}
+}
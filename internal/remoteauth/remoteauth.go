@@ -0,0 +1,377 @@
+// Package remoteauth implements Discord's remote authentication handshake,
+// which lets the user log in by scanning a QR code with the Discord mobile app
+// instead of typing in their credentials.
+//
+// The handshake goes like this:
+//
+//  1. The client connects to the remote auth gateway, which says hello.
+//  2. The client generates an RSA keypair and sends the public key.
+//  3. The gateway sends a nonce encrypted with the public key. The client
+//     proves that it has the private key by sending back its SHA-256 hash.
+//  4. The gateway sends the fingerprint of the public key, which the client
+//     shows as a QR code.
+//  5. Once the code is scanned, the gateway sends the encrypted details of the
+//     user, which the client shows so that the user knows it worked.
+//  6. Once the user confirms on their phone, the gateway sends a ticket. The
+//     client exchanges the ticket for the encrypted token over the REST API.
+package remoteauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// DefaultGatewayURL is the URL of Discord's remote auth gateway.
+const DefaultGatewayURL = "wss://remote-auth-gateway.discord.gg/?v=2"
+
+// Origin is the Origin header that the gateway requires.
+const Origin = "https://discord.com"
+
+// QRCodeURLPrefix is prepended to the fingerprint to form the URL that is
+// encoded in the QR code.
+const QRCodeURLPrefix = "https://discord.com/ra/"
+
+// ErrCancelled is returned if the user cancels the login on their phone.
+var ErrCancelled = errors.New("login was cancelled on the other device")
+
+// ErrTimeout is returned if the gateway closes the connection because the QR
+// code wasn't scanned in time.
+var ErrTimeout = errors.New("QR code expired")
+
+// PendingUser is the user that scanned the QR code but hasn't confirmed the
+// login yet.
+type PendingUser struct {
+	ID            discord.UserID
+	Discriminator string
+	Avatar        discord.Hash
+	Username      string
+}
+
+// AvatarURL returns the URL of the user's avatar.
+func (u PendingUser) AvatarURL() string {
+	user := discord.User{
+		ID:            u.ID,
+		Discriminator: u.Discriminator,
+		Avatar:        u.Avatar,
+		Username:      u.Username,
+	}
+	return user.AvatarURL()
+}
+
+// parsePendingUser parses the user payload, which looks like
+// "id:discriminator:avatar:username". The username may contain colons.
+func parsePendingUser(payload string) (PendingUser, error) {
+	parts := strings.SplitN(payload, ":", 4)
+	if len(parts) != 4 {
+		return PendingUser{}, fmt.Errorf("invalid user payload with %d parts", len(parts))
+	}
+
+	id, err := discord.ParseSnowflake(parts[0])
+	if err != nil {
+		return PendingUser{}, errors.Wrap(err, "invalid user ID")
+	}
+
+	return PendingUser{
+		ID:            discord.UserID(id),
+		Discriminator: parts[1],
+		Avatar:        discord.Hash(parts[2]),
+		Username:      parts[3],
+	}, nil
+}
+
+// Options configures Login.
+type Options struct {
+	// GatewayURL is the URL of the remote auth gateway. It defaults to
+	// DefaultGatewayURL.
+	GatewayURL string
+	// Dialer is used to connect to the gateway. It defaults to
+	// websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+	// Client is used to exchange the ticket for the token. It must not have a
+	// token. It defaults to api.NewClient("").
+	Client *api.Client
+
+	// OnQRCode is called with the URL to show as a QR code.
+	OnQRCode func(url string)
+	// OnPendingUser is called once the QR code is scanned.
+	OnPendingUser func(PendingUser)
+}
+
+// Message is a message sent over the remote auth gateway. Only the fields
+// relevant to Op are set.
+type Message struct {
+	Op string `json:"op"`
+
+	HeartbeatInterval    int    `json:"heartbeat_interval,omitempty"`
+	TimeoutMs            int    `json:"timeout_ms,omitempty"`
+	EncodedPublicKey     string `json:"encoded_public_key,omitempty"`
+	EncryptedNonce       string `json:"encrypted_nonce,omitempty"`
+	Proof                string `json:"proof,omitempty"`
+	Fingerprint          string `json:"fingerprint,omitempty"`
+	EncryptedUserPayload string `json:"encrypted_user_payload,omitempty"`
+	Ticket               string `json:"ticket,omitempty"`
+}
+
+// Message ops.
+const (
+	OpHello             = "hello"
+	OpInit              = "init"
+	OpNonceProof        = "nonce_proof"
+	OpPendingRemoteInit = "pending_remote_init"
+	OpPendingTicket     = "pending_ticket"
+	OpPendingLogin      = "pending_login"
+	OpCancel            = "cancel"
+	OpHeartbeat         = "heartbeat"
+	OpHeartbeatAck      = "heartbeat_ack"
+)
+
+// TicketLoginRequest is the body of the request that exchanges a ticket for
+// the token.
+type TicketLoginRequest struct {
+	Ticket string `json:"ticket"`
+}
+
+// TicketLoginResponse is the response to TicketLoginRequest.
+type TicketLoginResponse struct {
+	EncryptedToken string `json:"encrypted_token"`
+}
+
+// TicketLoginEndpoint is the endpoint that exchanges a ticket for the token.
+var TicketLoginEndpoint = api.EndpointMe + "/remote-auth/login"
+
+// Login runs the handshake until the user confirms the login on their phone,
+// and then returns the token. It blocks until then, so the caller should
+// cancel ctx if the user goes away.
+func Login(ctx context.Context, opts Options) (string, error) {
+	if opts.GatewayURL == "" {
+		opts.GatewayURL = DefaultGatewayURL
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+	if opts.Client == nil {
+		opts.Client = api.NewClient("")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot generate key")
+	}
+
+	header := http.Header{"Origin": {Origin}}
+
+	conn, _, err := opts.Dialer.DialContext(ctx, opts.GatewayURL, header)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot connect to remote auth gateway")
+	}
+	defer conn.Close()
+
+	// Unblock reads once ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	h := &handshake{
+		opts: opts,
+		conn: conn,
+		key:  key,
+	}
+
+	token, err := h.run(ctx)
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return token, err
+}
+
+type handshake struct {
+	opts Options
+	conn *websocket.Conn
+	wmu  sync.Mutex // guards writes to conn
+	key  *rsa.PrivateKey
+}
+
+func (h *handshake) run(ctx context.Context) (string, error) {
+	var hello Message
+	if err := h.read(&hello); err != nil {
+		return "", err
+	}
+	if hello.Op != OpHello {
+		return "", fmt.Errorf("expected hello, got %q", hello.Op)
+	}
+
+	heartbeatCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if hello.HeartbeatInterval > 0 {
+		interval := time.Duration(hello.HeartbeatInterval) * time.Millisecond
+		go h.heartbeat(heartbeatCtx, interval)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&h.key.PublicKey)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot encode public key")
+	}
+
+	if err := h.write(Message{
+		Op:               OpInit,
+		EncodedPublicKey: base64.StdEncoding.EncodeToString(publicKey),
+	}); err != nil {
+		return "", err
+	}
+
+	for {
+		var msg Message
+		if err := h.read(&msg); err != nil {
+			return "", err
+		}
+
+		switch msg.Op {
+		case OpNonceProof:
+			nonce, err := h.decrypt(msg.EncryptedNonce)
+			if err != nil {
+				return "", errors.Wrap(err, "cannot decrypt nonce")
+			}
+
+			if err := h.write(Message{
+				Op:    OpNonceProof,
+				Proof: Proof(nonce),
+			}); err != nil {
+				return "", err
+			}
+
+		case OpPendingRemoteInit:
+			if msg.Fingerprint != Fingerprint(publicKey) {
+				return "", errors.New("gateway sent a fingerprint for the wrong key")
+			}
+
+			if h.opts.OnQRCode != nil {
+				h.opts.OnQRCode(QRCodeURLPrefix + msg.Fingerprint)
+			}
+
+		case OpPendingTicket:
+			payload, err := h.decrypt(msg.EncryptedUserPayload)
+			if err != nil {
+				return "", errors.Wrap(err, "cannot decrypt user")
+			}
+
+			user, err := parsePendingUser(string(payload))
+			if err != nil {
+				return "", err
+			}
+
+			if h.opts.OnPendingUser != nil {
+				h.opts.OnPendingUser(user)
+			}
+
+		case OpPendingLogin:
+			return h.exchangeTicket(ctx, msg.Ticket)
+
+		case OpCancel:
+			return "", ErrCancelled
+		}
+	}
+}
+
+func (h *handshake) heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.write(Message{Op: OpHeartbeat}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *handshake) exchangeTicket(ctx context.Context, ticket string) (string, error) {
+	var resp TicketLoginResponse
+
+	err := h.opts.Client.WithContext(ctx).RequestJSON(
+		&resp, "POST", TicketLoginEndpoint,
+		httputil.WithJSONBody(TicketLoginRequest{Ticket: ticket}),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot exchange ticket for token")
+	}
+
+	token, err := h.decrypt(resp.EncryptedToken)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot decrypt token")
+	}
+
+	return string(token), nil
+}
+
+func (h *handshake) decrypt(b64 string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(sha256.New(), nil, h.key, ciphertext, nil)
+}
+
+func (h *handshake) read(msg *Message) error {
+	if err := h.conn.ReadJSON(msg); err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == CloseTimeout {
+			return ErrTimeout
+		}
+		return errors.Wrap(err, "cannot read from remote auth gateway")
+	}
+	return nil
+}
+
+// write is safe to call concurrently, since heartbeats are sent from their
+// own goroutine.
+func (h *handshake) write(msg Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+
+	h.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := h.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		return errors.Wrap(err, "cannot write to remote auth gateway")
+	}
+	return nil
+}
+
+// CloseTimeout is the close code that the gateway uses once the QR code
+// expires.
+const CloseTimeout = 4003
+
+// Proof returns the proof for the given decrypted nonce.
+func Proof(nonce []byte) string {
+	sum := sha256.Sum256(nonce)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Fingerprint returns the fingerprint of the given public key in PKIX form.
+func Fingerprint(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package remoteauth_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/api"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/fakediscord"
+	"libdb.so/dissent/internal/remoteauth"
+)
+
+var me = discord.User{
+	ID:            1,
+	Username:      "user:with:colons",
+	Discriminator: "0001",
+	Avatar:        "avatar",
+}
+
+// startServer starts a fake server and points the ticket exchange at it.
+func startServer(t *testing.T, token string) *fakediscord.Server {
+	t.Helper()
+
+	srv := fakediscord.New(token, me)
+	if err := srv.Start("127.0.0.1:0"); err != nil {
+		t.Fatal("cannot start fake server:", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	endpoint := remoteauth.TicketLoginEndpoint
+	remoteauth.TicketLoginEndpoint = srv.URL() + api.Path + "/users/@me/remote-auth/login"
+	t.Cleanup(func() { remoteauth.TicketLoginEndpoint = endpoint })
+
+	return srv
+}
+
+func fingerprint(t *testing.T, url string) string {
+	t.Helper()
+
+	fingerprint, ok := strings.CutPrefix(url, remoteauth.QRCodeURLPrefix)
+	if !ok {
+		t.Errorf("QR code URL %q does not start with %q", url, remoteauth.QRCodeURLPrefix)
+	}
+	return fingerprint
+}
+
+func TestLogin(t *testing.T) {
+	srv := startServer(t, "secret-token")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var pending remoteauth.PendingUser
+
+	token, err := remoteauth.Login(ctx, remoteauth.Options{
+		GatewayURL: srv.RemoteAuthURL(),
+		OnQRCode: func(url string) {
+			fingerprint := fingerprint(t, url)
+			go func() {
+				if err := srv.ApproveRemoteAuth(fingerprint); err != nil {
+					t.Error("cannot approve remote auth:", err)
+				}
+			}()
+		},
+		OnPendingUser: func(user remoteauth.PendingUser) {
+			pending = user
+		},
+	})
+	if err != nil {
+		t.Fatal("cannot log in:", err)
+	}
+
+	if token != "secret-token" {
+		t.Errorf("expected token %q, got %q", "secret-token", token)
+	}
+
+	want := remoteauth.PendingUser{
+		ID:            me.ID,
+		Discriminator: me.Discriminator,
+		Avatar:        me.Avatar,
+		Username:      me.Username,
+	}
+	if pending != want {
+		t.Errorf("expected pending user %+v, got %+v", want, pending)
+	}
+}
+
+func TestLoginCancelled(t *testing.T) {
+	srv := startServer(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := remoteauth.Login(ctx, remoteauth.Options{
+		GatewayURL: srv.RemoteAuthURL(),
+		OnQRCode: func(url string) {
+			fingerprint := fingerprint(t, url)
+			go func() {
+				if err := srv.CancelRemoteAuth(fingerprint); err != nil {
+					t.Error("cannot cancel remote auth:", err)
+				}
+			}()
+		},
+	})
+	if !errors.Is(err, remoteauth.ErrCancelled) {
+		t.Fatalf("expected ErrCancelled, got %v", err)
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	timeout := fakediscord.RemoteAuthTimeout
+	fakediscord.RemoteAuthTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { fakediscord.RemoteAuthTimeout = timeout })
+
+	srv := startServer(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := remoteauth.Login(ctx, remoteauth.Options{
+		GatewayURL: srv.RemoteAuthURL(),
+	})
+	if !errors.Is(err, remoteauth.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestLoginContextCancelled(t *testing.T) {
+	srv := startServer(t, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := remoteauth.Login(ctx, remoteauth.Options{
+		GatewayURL: srv.RemoteAuthURL(),
+		OnQRCode:   func(string) { cancel() },
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
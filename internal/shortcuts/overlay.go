@@ -0,0 +1,65 @@
+package shortcuts
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+)
+
+// ShowWindow shows the shortcuts that are currently set in a
+// gtk.ShortcutsWindow. Disabled shortcuts are left out.
+func ShowWindow(ctx context.Context) {
+	// GtkShortcutsWindow can only be filled in using GtkBuilder.
+	builder := gtk.NewBuilderFromString(overlayXML())
+
+	win := builder.GetObject("shortcuts").Cast().(*gtk.ShortcutsWindow)
+	win.SetTransientFor(app.GTKWindowFromContext(ctx))
+	win.SetModal(true)
+	win.Present()
+}
+
+func overlayXML() string {
+	var b strings.Builder
+	b.WriteString(`<interface><object class="GtkShortcutsWindow" id="shortcuts">`)
+	b.WriteString(`<child><object class="GtkShortcutsSection">`)
+	b.WriteString(`<property name="section-name">shortcuts</property>`)
+
+	for _, section := range Sections() {
+		var group strings.Builder
+		for _, s := range section {
+			accel := Accel(s.Action)
+			if accel == "" {
+				continue
+			}
+
+			group.WriteString(`<child><object class="GtkShortcutsShortcut">`)
+			writeProperty(&group, "title", s.Name.String())
+			writeProperty(&group, "accelerator", accel)
+			group.WriteString(`</object></child>`)
+		}
+
+		if group.Len() == 0 {
+			continue
+		}
+
+		b.WriteString(`<child><object class="GtkShortcutsGroup">`)
+		writeProperty(&b, "title", section[0].Section.String())
+		b.WriteString(group.String())
+		b.WriteString(`</object></child>`)
+	}
+
+	b.WriteString(`</object></child>`)
+	b.WriteString(`</object></interface>`)
+	return b.String()
+}
+
+func writeProperty(b *strings.Builder, name, value string) {
+	b.WriteString(`<property name="`)
+	b.WriteString(name)
+	b.WriteString(`">`)
+	xml.EscapeText(b, []byte(value))
+	b.WriteString(`</property>`)
+}
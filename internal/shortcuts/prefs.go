@@ -0,0 +1,183 @@
+package shortcuts
+
+import (
+	"context"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+var editorCSS = cssutil.Applier("shortcuts-editor", `
+	.shortcuts-editor > *:not(:first-child) {
+		margin-top: 12px;
+	}
+	.shortcuts-editor-capture {
+		margin-top: 12px;
+	}
+`)
+
+func (p *accelPrefs) CreateWidget(_ context.Context, save func()) gtk.Widgetter {
+	type row struct {
+		*adw.ActionRow
+		shortcut Shortcut
+		accel    *gtk.Button
+		reset    *gtk.Button
+	}
+
+	var rows []row
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	editorCSS(box)
+
+	for _, section := range Sections() {
+		group := adw.NewPreferencesGroup()
+		group.SetTitle(section[0].Section.String())
+
+		for _, s := range section {
+			r := row{
+				ActionRow: adw.NewActionRow(),
+				shortcut:  s,
+			}
+			r.SetTitle(s.Name.String())
+
+			r.accel = gtk.NewButton()
+			r.accel.AddCSSClass("flat")
+			r.accel.SetVAlign(gtk.AlignCenter)
+			r.accel.SetTooltipText(locale.Get("Change Shortcut"))
+			r.accel.ConnectClicked(func() { promptAccel(r.accel, s, save) })
+
+			r.reset = gtk.NewButtonFromIconName("edit-undo-symbolic")
+			r.reset.AddCSSClass("flat")
+			r.reset.SetVAlign(gtk.AlignCenter)
+			r.reset.SetTooltipText(locale.Get("Reset to Default"))
+			r.reset.ConnectClicked(func() {
+				Reset(s.Action)
+				save()
+			})
+
+			r.AddSuffix(r.accel)
+			r.AddSuffix(r.reset)
+			r.SetActivatableWidget(r.accel)
+			group.Add(r)
+
+			rows = append(rows, r)
+		}
+
+		box.Append(group)
+	}
+
+	resetAll := gtk.NewButtonWithLabel(locale.Get("Reset All"))
+	resetAll.SetHAlign(gtk.AlignEnd)
+	resetAll.ConnectClicked(func() {
+		ResetAll()
+		save()
+	})
+	box.Append(resetAll)
+
+	update := func() {
+		for _, r := range rows {
+			accel := Accel(r.shortcut.Action)
+			r.accel.SetLabel(Label(accel))
+			r.reset.SetVisible(!IsDefault(r.shortcut.Action))
+
+			// Conflicts can only come from a hand-edited preferences file,
+			// since the editor replaces the old shortcut.
+			if other, ok := Conflict(r.shortcut.Action, accel); ok {
+				r.accel.AddCSSClass("error")
+				r.SetSubtitle(locale.Sprintf("Also used by %s", other.Name.String()))
+			} else {
+				r.accel.RemoveCSSClass("error")
+				r.SetSubtitle("")
+			}
+		}
+	}
+
+	p.SubscribeWidget(box, update)
+	update()
+
+	return box
+}
+
+// promptAccel asks the user to press the new accelerator of the shortcut.
+func promptAccel(parent gtk.Widgetter, s Shortcut, save func()) {
+	body := locale.Sprintf(
+		"Press the new shortcut for “%s”, or Backspace to disable it.",
+		s.Name.String())
+
+	dialog := adw.NewAlertDialog(locale.Get("Change Shortcut"), body)
+	dialog.AddResponse("cancel", locale.Get("_Cancel"))
+	dialog.AddResponse("set", locale.Get("_Set"))
+	dialog.SetResponseAppearance("set", adw.ResponseSuggested)
+	dialog.SetResponseEnabled("set", false)
+	dialog.SetCloseResponse("cancel")
+
+	captured := gtk.NewLabel(Label(Accel(s.Action)))
+	captured.AddCSSClass("title-2")
+	captured.AddCSSClass("shortcuts-editor-capture")
+	dialog.SetExtraChild(captured)
+
+	var accel string
+	var conflict *Shortcut
+
+	keys := gtk.NewEventControllerKey()
+	keys.SetPropagationPhase(gtk.PhaseCapture)
+	keys.ConnectKeyPressed(func(val, _ uint, state gdk.ModifierType) bool {
+		val = gdk.KeyvalToLower(val)
+		state &= gtk.AcceleratorGetDefaultModMask()
+
+		switch {
+		case val == gdk.KEY_Escape && state == 0:
+			// Let the dialog close.
+			return false
+		case val == gdk.KEY_BackSpace && state == 0:
+			accel = ""
+		case !gtk.AcceleratorValid(val, state):
+			// Only modifiers were pressed so far.
+			return true
+		case !Allowed(s, val, state):
+			accel = ""
+			conflict = nil
+			captured.SetText(gtk.AcceleratorGetLabel(val, state))
+			dialog.SetBody(locale.Get(
+				"This shortcut needs a modifier such as Ctrl or Alt, unless it is a function key."))
+			dialog.SetResponseEnabled("set", false)
+			return true
+		default:
+			accel = gtk.AcceleratorName(val, state)
+		}
+
+		captured.SetText(Label(accel))
+		dialog.SetResponseEnabled("set", true)
+
+		if other, ok := Conflict(s.Action, accel); ok {
+			conflict = &other
+			dialog.SetBody(locale.Sprintf(
+				"This shortcut is already used by “%s”. Setting it removes it from there.",
+				other.Name.String()))
+			dialog.SetResponseAppearance("set", adw.ResponseDestructive)
+		} else {
+			conflict = nil
+			dialog.SetBody(body)
+			dialog.SetResponseAppearance("set", adw.ResponseSuggested)
+		}
+
+		return true
+	})
+	dialog.AddController(keys)
+
+	dialog.ConnectResponse(func(response string) {
+		if response != "set" {
+			return
+		}
+		if conflict != nil {
+			Set(conflict.Action, "")
+		}
+		Set(s.Action, accel)
+		save()
+	})
+
+	dialog.Present(parent)
+}
@@ -0,0 +1,159 @@
+// Package shortcuts keeps the registry of keyboard shortcuts. Every shortcut
+// has a default accelerator, which the user can change in the preferences.
+// Shortcuts of actions are set as the accelerators of the application, while
+// widgets check their own shortcuts using Matches.
+package shortcuts
+
+import "github.com/diamondburned/gotkit/app/locale"
+
+// Shortcut is something that can be done using a keyboard shortcut.
+type Shortcut struct {
+	// Action is the detailed name of the action, such as "win.quick-switcher".
+	// Shortcuts of widgets are named after the widget instead, such as
+	// "composer.send".
+	Action string
+	// Section groups the shortcut with others.
+	Section locale.Localized
+	// Name describes what the shortcut does.
+	Name locale.Localized
+	// Default is the default accelerator in the format of
+	// gtk.AcceleratorParse, such as "<Ctrl>K", or an empty string for none.
+	Default string
+	// Widget is true if the shortcut is checked by a widget using Matches
+	// instead of being the accelerator of an action.
+	Widget bool
+}
+
+// registry holds every shortcut in the order that they are shown.
+var registry = []Shortcut{
+	{
+		Action:  "app.preferences",
+		Section: "General",
+		Name:    "Preferences",
+		Default: "<Ctrl>comma",
+	},
+	{
+		Action:  "app.shortcuts",
+		Section: "General",
+		Name:    "Keyboard Shortcuts",
+		Default: "<Ctrl>question",
+	},
+	{
+		Action:  "app.quit",
+		Section: "General",
+		Name:    "Quit",
+		Default: "<Ctrl>Q",
+	},
+
+	{
+		Action:  "win.quick-switcher",
+		Section: "Navigation",
+		Name:    "Quick Switcher",
+		Default: "<Ctrl>K",
+	},
+	{
+		Action:  "win.open-dms",
+		Section: "Navigation",
+		Name:    "Direct Messages",
+	},
+	{
+		Action:  "win.go-back",
+		Section: "Navigation",
+		Name:    "Go Back",
+		Default: "<Alt>Left",
+	},
+	{
+		Action:  "win.go-forward",
+		Section: "Navigation",
+		Name:    "Go Forward",
+		Default: "<Alt>Right",
+	},
+
+	{
+		Action:  "win.split-left-right",
+		Section: "Chat",
+		Name:    "Split Left and Right",
+	},
+	{
+		Action:  "win.split-top-bottom",
+		Section: "Chat",
+		Name:    "Split Top and Bottom",
+	},
+	{
+		Action:  "win.close-split",
+		Section: "Chat",
+		Name:    "Close Split",
+	},
+
+	{
+		Action:  "win.set-online",
+		Section: "Status",
+		Name:    "Set Online",
+	},
+	{
+		Action:  "win.set-idle",
+		Section: "Status",
+		Name:    "Set Idle",
+	},
+	{
+		Action:  "win.set-dnd",
+		Section: "Status",
+		Name:    "Set Do Not Disturb",
+	},
+	{
+		Action:  "win.set-invisible",
+		Section: "Status",
+		Name:    "Set Invisible",
+	},
+
+	{
+		Action:  "composer.send",
+		Section: "Composer",
+		Name:    "Send Message",
+		Default: "Return",
+		Widget:  true,
+	},
+	{
+		Action:  "composer.escape",
+		Section: "Composer",
+		Name:    "Stop Replying or Editing",
+		Default: "Escape",
+		Widget:  true,
+	},
+	{
+		Action:  "composer.edit-last-message",
+		Section: "Composer",
+		Name:    "Edit Last Message",
+		Default: "Up",
+		Widget:  true,
+	},
+}
+
+// All returns every shortcut in the registry.
+func All() []Shortcut {
+	return append([]Shortcut(nil), registry...)
+}
+
+// Lookup returns the shortcut of the action.
+func Lookup(action string) (Shortcut, bool) {
+	for _, s := range registry {
+		if s.Action == action {
+			return s, true
+		}
+	}
+	return Shortcut{}, false
+}
+
+// Sections returns the shortcuts grouped by their sections, in the order that
+// the sections first appear.
+func Sections() [][]Shortcut {
+	var sections [][]Shortcut
+	for _, s := range registry {
+		if n := len(sections); n > 0 && sections[n-1][0].Section == s.Section {
+			sections[n-1] = append(sections[n-1], s)
+		} else {
+			sections = append(sections, []Shortcut{s})
+		}
+	}
+	return sections
+}
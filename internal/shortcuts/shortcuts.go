@@ -0,0 +1,246 @@
+package shortcuts
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+)
+
+// userAccels holds the accelerators that the user changed. It is saved along
+// with the other preferences.
+var userAccels = &accelPrefs{
+	Pubsub:    *prefs.NewPubsub(),
+	overrides: make(map[string]string),
+}
+
+func init() {
+	prefs.RegisterProp(userAccels)
+}
+
+// Accel returns the accelerator of the action, which is its default unless the
+// user changed it. It returns an empty string if the shortcut is disabled.
+func Accel(action string) string {
+	s, _ := Lookup(action)
+	if accel, ok := userAccels.override(action); ok && allowedAccel(s, accel) {
+		return accel
+	}
+	return s.Default
+}
+
+// Allowed returns true if the key and modifiers can be used as the accelerator
+// of the shortcut. Shortcuts of actions need a modifier other than Shift,
+// unless the key is a function key, since they would otherwise take the key
+// away from every text entry. Shortcuts of widgets only apply to the widget,
+// so they can use any key.
+func Allowed(s Shortcut, key uint, mods gdk.ModifierType) bool {
+	if s.Widget {
+		return true
+	}
+	if key >= gdk.KEY_F1 && key <= gdk.KEY_F35 {
+		return true
+	}
+	return mods&^gdk.ShiftMask != 0
+}
+
+func allowedAccel(s Shortcut, accel string) bool {
+	if accel == "" {
+		return true
+	}
+	key, mods, ok := gtk.AcceleratorParse(accel)
+	return ok && Allowed(s, key, mods)
+}
+
+// IsDefault returns true if the action uses its default accelerator.
+func IsDefault(action string) bool {
+	_, ok := userAccels.override(action)
+	return !ok
+}
+
+// Set changes the accelerator of the action. An empty accelerator disables the
+// shortcut.
+func Set(action, accel string) {
+	s, ok := Lookup(action)
+	if !ok {
+		return
+	}
+
+	accel = normalize(accel)
+	if accel == normalize(s.Default) {
+		userAccels.set(action, "", false)
+	} else {
+		userAccels.set(action, accel, true)
+	}
+}
+
+// Reset changes the accelerator of the action back to its default.
+func Reset(action string) {
+	userAccels.set(action, "", false)
+}
+
+// ResetAll changes the accelerators of all actions back to their defaults.
+func ResetAll() {
+	userAccels.reset()
+}
+
+// Conflict returns the shortcut other than the one of the action that uses the
+// given accelerator.
+func Conflict(action, accel string) (Shortcut, bool) {
+	accel = normalize(accel)
+	if accel == "" {
+		return Shortcut{}, false
+	}
+
+	for _, s := range registry {
+		if s.Action != action && normalize(Accel(s.Action)) == accel {
+			return s, true
+		}
+	}
+
+	return Shortcut{}, false
+}
+
+// Matches returns true if the key event matches the shortcut of the action.
+// It is meant to be called by key controllers of widgets.
+func Matches(action string, keyval uint, state gdk.ModifierType) bool {
+	accel := Accel(action)
+	if accel == "" {
+		return false
+	}
+
+	key, mods, ok := gtk.AcceleratorParse(accel)
+	if !ok {
+		return false
+	}
+
+	state &= gtk.AcceleratorGetDefaultModMask()
+	return gdk.KeyvalToLower(keyval) == gdk.KeyvalToLower(key) && state == mods
+}
+
+// Label returns the accelerator as shown to the user, such as "Ctrl+K".
+func Label(accel string) string {
+	if accel == "" {
+		return locale.Get("Disabled")
+	}
+
+	key, mods, ok := gtk.AcceleratorParse(accel)
+	if !ok {
+		return accel
+	}
+
+	return gtk.AcceleratorGetLabel(key, mods)
+}
+
+// Apply sets the accelerators of all actions in the registry on the
+// application. They are set again whenever the user changes them.
+func Apply(app *gtk.Application) {
+	userAccels.Subscribe(func() {
+		for _, s := range registry {
+			if s.Widget {
+				continue
+			}
+
+			var accels []string
+			if accel := Accel(s.Action); accel != "" {
+				accels = []string{accel}
+			}
+
+			app.SetAccelsForAction(s.Action, accels)
+		}
+	})
+}
+
+// normalize returns the accelerator in the format of gtk.AcceleratorName, so
+// that the same accelerator written differently can be compared. It returns an
+// empty string if the accelerator is invalid.
+func normalize(accel string) string {
+	if accel == "" {
+		return ""
+	}
+
+	key, mods, ok := gtk.AcceleratorParse(accel)
+	if !ok {
+		return ""
+	}
+
+	return gtk.AcceleratorName(gdk.KeyvalToLower(key), mods)
+}
+
+// accelPrefs is the preference holding the changed accelerators. It is shown
+// as the shortcut editor.
+type accelPrefs struct {
+	prefs.Pubsub
+
+	mu sync.RWMutex
+	// overrides maps actions to their accelerators. An empty accelerator
+	// disables the shortcut.
+	overrides map[string]string
+}
+
+func (p *accelPrefs) override(action string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	accel, ok := p.overrides[action]
+	return accel, ok
+}
+
+func (p *accelPrefs) set(action, accel string, ok bool) {
+	p.mu.Lock()
+	if ok {
+		p.overrides[action] = accel
+	} else {
+		delete(p.overrides, action)
+	}
+	p.mu.Unlock()
+
+	p.Publish()
+}
+
+func (p *accelPrefs) reset() {
+	p.mu.Lock()
+	p.overrides = make(map[string]string)
+	p.mu.Unlock()
+
+	p.Publish()
+}
+
+func (p *accelPrefs) MarshalJSON() ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return json.Marshal(p.overrides)
+}
+
+func (p *accelPrefs) UnmarshalJSON(b []byte) error {
+	var overrides map[string]string
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return err
+	}
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+
+	p.mu.Lock()
+	p.overrides = overrides
+	p.mu.Unlock()
+
+	p.Publish()
+	return nil
+}
+
+func (p *accelPrefs) Meta() prefs.PropMeta {
+	return prefs.PropMeta{
+		Name:    "Keyboard Shortcuts",
+		Section: "Shortcuts",
+		Description: "Click a shortcut to change it. " +
+			"Shortcuts that are already taken can replace the old ones.",
+	}
+}
+
+func (p *accelPrefs) WidgetIsLarge() bool {
+	return true
+}
@@ -21,7 +21,7 @@ import (
 	"libdb.so/dissent/internal/signaling"
 )
 
-var revealStateKey = app.NewStateKey[bool]("collapsed-channels-state")
+var revealStateKey = gtkcord.NewAccountStateKey[bool]("collapsed-channels-state")
 
 type channelItemState struct {
 	state  *gtkcord.State
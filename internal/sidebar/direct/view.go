@@ -115,32 +115,29 @@ func NewChannelView(ctx context.Context) *ChannelView {
 	vis := gtkutil.WithVisibility(ctx, v)
 
 	state := gtkcord.FromContext(ctx)
-	state.BindHandler(vis, func(ev gateway.Event) {
-		// TODO: Channel events
-
-		switch ev := ev.(type) {
-		case *gateway.ChannelCreateEvent:
-			if !ev.GuildID.IsValid() {
-				v.Invalidate() // recreate everything
-			}
-		case *gateway.ChannelDeleteEvent:
-			v.deleteCh(ev.ID)
-
-		case *gateway.MessageCreateEvent:
-			if ch, ok := v.channels[ev.ChannelID]; ok {
-				ch.Invalidate()
-			}
-		case *read.UpdateEvent:
-			if ch, ok := v.channels[ev.ChannelID]; ok {
-				ch.Invalidate()
-			}
+
+	// TODO: Channel events
+	gtkcord.OnCancellable(state, vis, func(ev *gateway.ChannelCreateEvent) {
+		if !ev.GuildID.IsValid() {
+			v.Invalidate() // recreate everything
+		}
+	})
+
+	gtkcord.OnCancellable(state, vis, func(ev *gateway.ChannelDeleteEvent) {
+		v.deleteCh(ev.ID)
+	})
+
+	gtkcord.OnCancellable(state, vis, func(ev *gateway.MessageCreateEvent) {
+		if ch, ok := v.channels[ev.ChannelID]; ok {
+			ch.Invalidate()
 		}
-	},
-		(*gateway.ChannelCreateEvent)(nil),
-		(*gateway.ChannelDeleteEvent)(nil),
-		(*gateway.MessageCreateEvent)(nil),
-		(*read.UpdateEvent)(nil),
-	)
+	})
+
+	gtkcord.OnCancellable(state, vis, func(ev *read.UpdateEvent) {
+		if ch, ok := v.channels[ev.ChannelID]; ok {
+			ch.Invalidate()
+		}
+	})
 
 	return &v
 }
@@ -163,6 +160,11 @@ func (v *ChannelView) SelectChannel(chID discord.ChannelID) {
 		"channel_id", chID)
 }
 
+// ScrolledWindow returns the scrolled window of the channel list.
+func (v *ChannelView) ScrolledWindow() *gtk.ScrolledWindow {
+	return v.scroll
+}
+
 // Invalidate invalidates the whole channel view.
 func (v *ChannelView) Invalidate() {
 	state := gtkcord.FromContext(v.ctx)
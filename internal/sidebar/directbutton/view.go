@@ -47,21 +47,17 @@ func NewView(ctx context.Context) *View {
 	vis := gtkutil.WithVisibility(ctx, v)
 
 	state := gtkcord.FromContext(ctx)
-	state.BindHandler(vis, func(ev gateway.Event) {
-		switch ev := ev.(type) {
-		case *read.UpdateEvent:
-			if !ev.GuildID.IsValid() {
-				v.Invalidate()
-			}
-		case *gateway.MessageCreateEvent:
-			if !ev.GuildID.IsValid() {
-				v.Invalidate()
-			}
+	gtkcord.OnCancellable(state, vis, func(ev *read.UpdateEvent) {
+		if !ev.GuildID.IsValid() {
+			v.Invalidate()
 		}
-	},
-		(*read.UpdateEvent)(nil),
-		(*gateway.MessageCreateEvent)(nil),
-	)
+	})
+
+	gtkcord.OnCancellable(state, vis, func(ev *gateway.MessageCreateEvent) {
+		if !ev.GuildID.IsValid() {
+			v.Invalidate()
+		}
+	})
 
 	return &v
 }
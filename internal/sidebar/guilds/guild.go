@@ -109,21 +109,11 @@ func (g *Guild) viewChild() {}
 func (g *Guild) InvalidateUnread() {
 	state := gtkcord.FromContext(g.ctx)
 
-	var mentions int
-
-	chs, _ := state.Cabinet.Channels(g.id)
-	for _, ch := range chs {
-		read := state.ReadState.ReadState(ch.ID)
-		if read != nil {
-			mentions += read.MentionCount
-		}
-	}
-
 	g.SetIndicator(state.GuildIsUnread(g.id, ningen.GuildUnreadOpts{
 		UnreadOpts: ningen.UnreadOpts{},
 		Types:      gtkcord.AllowedChannelTypes,
 	}))
-	g.Mentions.SetCount(mentions)
+	g.Mentions.SetCount(state.GuildMentionCount(g.id))
 
 	if g.parent != nil {
 		g.parent.InvalidateUnread()
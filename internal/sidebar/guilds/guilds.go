@@ -54,49 +54,60 @@ func NewView(ctx context.Context) *View {
 	cancellable := gtkutil.WithVisibility(ctx, v)
 
 	state := gtkcord.FromContext(ctx)
-	state.BindHandler(cancellable, func(ev gateway.Event) {
-		switch ev := ev.(type) {
-		case *gateway.ReadyEvent, *gateway.ResumedEvent:
-			// Recreate the whole list in case we have some new info.
-			v.Invalidate()
 
-		case *read.UpdateEvent:
+	// Recreate the whole list in case we have some new info.
+	gtkcord.OnCancellable(state, cancellable, func(*gateway.ReadyEvent) {
+		v.Invalidate()
+	})
+
+	gtkcord.OnCancellable(state, cancellable, func(*gateway.ResumedEvent) {
+		v.Invalidate()
+	})
+
+	gtkcord.OnCancellable(state, cancellable, func(ev *read.UpdateEvent) {
+		if guild := v.Guild(ev.GuildID); guild != nil {
+			guild.InvalidateUnread()
+		}
+	})
+
+	gtkcord.OnCancellable(state, cancellable, func(ev *gateway.ChannelCreateEvent) {
+		if ev.GuildID.IsValid() {
 			if guild := v.Guild(ev.GuildID); guild != nil {
 				guild.InvalidateUnread()
 			}
-		case *gateway.ChannelCreateEvent:
-			if ev.GuildID.IsValid() {
-				if guild := v.Guild(ev.GuildID); guild != nil {
-					guild.InvalidateUnread()
-				}
-			}
-		case *gateway.GuildCreateEvent:
-			if guild := v.Guild(ev.ID); guild != nil {
-				guild.Update(&ev.Guild)
-			} else {
-				v.AddGuild(&ev.Guild)
-			}
-		case *gateway.GuildUpdateEvent:
-			if guild := v.Guild(ev.ID); guild != nil {
-				guild.Invalidate()
-			}
-		case *gateway.GuildDeleteEvent:
-			if ev.Unavailable {
-				if guild := v.Guild(ev.ID); guild != nil {
-					guild.SetUnavailable()
+		}
+	})
 
-					parent := gtk.BaseWidget(guild.Parent())
-					parent.ActivateAction("win.reset-view", nil)
-					return
-				}
-			}
+	gtkcord.OnCancellable(state, cancellable, func(ev *gateway.GuildCreateEvent) {
+		if guild := v.Guild(ev.ID); guild != nil {
+			guild.Update(&ev.Guild)
+		} else {
+			v.AddGuild(&ev.Guild)
+		}
+	})
+
+	gtkcord.OnCancellable(state, cancellable, func(ev *gateway.GuildUpdateEvent) {
+		if guild := v.Guild(ev.ID); guild != nil {
+			guild.Invalidate()
+		}
+	})
+
+	gtkcord.OnCancellable(state, cancellable, func(ev *gateway.GuildDeleteEvent) {
+		if ev.Unavailable {
+			if guild := v.Guild(ev.ID); guild != nil {
+				guild.SetUnavailable()
 
-			guild := v.RemoveGuild(ev.ID)
-			if guild != nil && guild.IsSelected() {
 				parent := gtk.BaseWidget(guild.Parent())
 				parent.ActivateAction("win.reset-view", nil)
+				return
 			}
 		}
+
+		guild := v.RemoveGuild(ev.ID)
+		if guild != nil && guild.IsSelected() {
+			parent := gtk.BaseWidget(guild.Parent())
+			parent.ActivateAction("win.reset-view", nil)
+		}
 	})
 
 	return &v
@@ -8,6 +8,7 @@ import (
 
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
@@ -52,8 +53,9 @@ var sidebarCSS = cssutil.Applier("sidebar-sidebar", `
 	}
 `)
 
-// NewSidebar creates a new Sidebar.
-func NewSidebar(ctx context.Context) *Sidebar {
+// NewSidebar creates a new Sidebar. accountItems returns the items of the
+// Accounts menu, and it is called every time the menu is opened.
+func NewSidebar(ctx context.Context, accountItems func() []gtkutil.PopoverMenuItem) *Sidebar {
 	s := Sidebar{
 		ctx: ctx,
 	}
@@ -97,20 +99,26 @@ func NewSidebar(ctx context.Context) *Sidebar {
 	s.Right.SetVisibleChild(s.placeholder)
 	s.Right.SetTransitionType(gtk.StackTransitionTypeCrossfade)
 
-	userBar := newUserBar(ctx, []gtkutil.PopoverMenuItem{
-		gtkutil.MenuItem("Quick Switcher", "win.quick-switcher"),
-		gtkutil.MenuSeparator("User Settings"),
-		gtkutil.Submenu("Set _Status", []gtkutil.PopoverMenuItem{
-			gtkutil.MenuItem("_Online", "win.set-online"),
-			gtkutil.MenuItem("_Idle", "win.set-idle"),
-			gtkutil.MenuItem("_Do Not Disturb", "win.set-dnd"),
-			gtkutil.MenuItem("In_visible", "win.set-invisible"),
-		}),
-		gtkutil.MenuSeparator(""),
-		gtkutil.MenuItem("_Preferences", "app.preferences"),
-		gtkutil.MenuItem("_About", "app.about"),
-		gtkutil.MenuItem("_Logs", "app.logs"),
-		gtkutil.MenuItem("_Quit", "app.quit"),
+	userBar := newUserBar(ctx, func() []gtkutil.PopoverMenuItem {
+		return []gtkutil.PopoverMenuItem{
+			gtkutil.MenuItem("Quick Switcher", "win.quick-switcher"),
+			gtkutil.MenuSeparator("User Settings"),
+			gtkutil.Submenu("Set _Status", []gtkutil.PopoverMenuItem{
+				gtkutil.MenuItem("_Online", "win.set-online"),
+				gtkutil.MenuItem("_Idle", "win.set-idle"),
+				gtkutil.MenuItem("_Do Not Disturb", "win.set-dnd"),
+				gtkutil.MenuItem("In_visible", "win.set-invisible"),
+			}),
+			gtkutil.Submenu("_Accounts", accountItems()),
+			gtkutil.MenuItem("Log _Out…", "win.log-out"),
+			gtkutil.MenuSeparator(""),
+			gtkutil.MenuItem("_Preferences", "app.preferences"),
+			gtkutil.MenuItem("_Keyboard Shortcuts", "app.shortcuts"),
+			gtkutil.MenuItem("_About", "app.about"),
+			gtkutil.MenuItem("_Logs", "app.logs"),
+			gtkutil.MenuItem("_Network Inspector", "app.network"),
+			gtkutil.MenuItem("_Quit", "app.quit"),
+		}
 	})
 
 	// TODO: consider if we can merge this ToolbarView with the one in channels
@@ -149,9 +157,11 @@ func (s *Sidebar) stackSelect(w gtk.Widgetter) {
 	if w == nil {
 		s.Right.SetVisibleChild(s.placeholder)
 	} else {
-		// This should do nothing if the widget is already in the stack.
-		// Maybe???
-		s.Right.AddChild(w)
+		// The widget may still be in the stack if it was shown again before
+		// its transition finished.
+		if gtk.BaseWidget(w).Parent() == nil {
+			s.Right.AddChild(w)
+		}
 		s.Right.SetVisibleChild(w)
 
 		w := gtk.BaseWidget(w)
@@ -162,6 +172,11 @@ func (s *Sidebar) stackSelect(w gtk.Widgetter) {
 		gtkutil.NotifyProperty(s.Right, "transition-running", func() bool {
 			// Remove the widget when the transition is done.
 			if !s.Right.TransitionRunning() {
+				if old == s.current.w {
+					// It was shown again in the meantime.
+					return true
+				}
+
 				s.Right.Remove(old)
 
 				w := gtk.BaseWidget(old)
@@ -178,6 +193,66 @@ func (s *Sidebar) stackSelect(w gtk.Widgetter) {
 	}
 }
 
+// State is the state of the sidebar that a tab keeps, so that the sidebar can
+// be shown exactly as it was when the tab is switched back to.
+type State struct {
+	w      gtk.Widgetter // *channels.View or *direct.ChannelView
+	scroll float64
+}
+
+// SaveState returns the current state of the sidebar. It returns nil if no
+// guild or DMs are shown.
+func (s *Sidebar) SaveState() *State {
+	if s.current.w == nil {
+		return nil
+	}
+
+	state := State{w: s.current.w}
+	if scroll := scrolledWindow(s.current.w); scroll != nil {
+		state.scroll = scroll.VAdjustment().Value()
+	}
+
+	return &state
+}
+
+// RestoreState shows the sidebar as it was when the state was saved. The same
+// channel list is shown again, so its selection and expanded categories are
+// kept as they were.
+func (s *Sidebar) RestoreState(state *State) {
+	if state.w == s.current.w {
+		return
+	}
+
+	s.unselect()
+
+	switch w := state.w.(type) {
+	case *channels.View:
+		s.Guilds.SetSelectedGuild(w.GuildID())
+	case *direct.ChannelView:
+		s.DMView.SetSelected(true)
+	}
+
+	s.stackSelect(state.w)
+
+	if scroll := scrolledWindow(state.w); scroll != nil {
+		vadj := scroll.VAdjustment()
+		// Wait for the list to be allocated again, otherwise the value is
+		// clamped.
+		glib.IdleAdd(func() { vadj.SetValue(state.scroll) })
+	}
+}
+
+func scrolledWindow(w gtk.Widgetter) *gtk.ScrolledWindow {
+	switch w := w.(type) {
+	case *channels.View:
+		return w.Scroll
+	case *direct.ChannelView:
+		return w.ScrolledWindow()
+	default:
+		return nil
+	}
+}
+
 // OpenDMs opens the DMs view. It automatically loads the DMs on first open, so
 // the returned ChannelView is guaranteed to be ready.
 func (s *Sidebar) OpenDMs() *direct.ChannelView {
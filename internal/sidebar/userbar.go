@@ -36,7 +36,9 @@ var userBarCSS = cssutil.Applier("user-bar", `
 	}
 `)
 
-func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *userBar {
+// newUserBar creates a new userBar. menuActions is called every time the menu
+// is opened.
+func newUserBar(ctx context.Context, menuActions func() []gtkutil.PopoverMenuItem) *userBar {
 	b := userBar{ctx: ctx}
 	b.avatar = onlineimage.NewAvatar(ctx, imgutil.HTTPProvider, gtkcord.UserBarAvatarSize)
 	b.avatar.AddCSSClass("user-bar-avatar")
@@ -60,7 +62,7 @@ func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *use
 	b.menu.SetHasFrame(false)
 	b.menu.SetVAlign(gtk.AlignCenter)
 	b.menu.ConnectClicked(func() {
-		p := gtkutil.NewPopoverMenuCustom(b.menu, gtk.PosTop, menuActions)
+		p := gtkutil.NewPopoverMenuCustom(b.menu, gtk.PosTop, menuActions())
 		p.ConnectHide(func() { b.menu.SetActive(false) })
 		gtkutil.PopupFinally(p)
 	})
@@ -78,27 +80,15 @@ func newUserBar(ctx context.Context, menuActions []gtkutil.PopoverMenuItem) *use
 	vis := gtkutil.WithVisibility(ctx, b)
 
 	client := gtkcord.FromContext(ctx)
-	client.BindHandler(vis,
-		func(ev gateway.Event) {
-			switch ev := ev.(type) {
-			case *gateway.UserUpdateEvent:
-				b.updateUser(&ev.User)
-			case
-				*gateway.PresenceUpdateEvent,
-				*gateway.PresencesReplaceEvent,
-				*gateway.SessionsReplaceEvent,
-				*gateway.UserSettingsUpdateEvent,
-				*gateway.ReadyEvent:
-				b.invalidatePresence()
-			}
-		},
-		(*gateway.UserUpdateEvent)(nil),
-		(*gateway.PresenceUpdateEvent)(nil),
-		(*gateway.PresencesReplaceEvent)(nil),
-		(*gateway.SessionsReplaceEvent)(nil),
-		(*gateway.UserSettingsUpdateEvent)(nil),
-		(*gateway.ReadyEvent)(nil),
-	)
+	gtkcord.OnCancellable(client, vis, func(ev *gateway.UserUpdateEvent) {
+		b.updateUser(&ev.User)
+	})
+
+	gtkcord.OnCancellable(client, vis, func(*gateway.PresenceUpdateEvent) { b.invalidatePresence() })
+	gtkcord.OnCancellable(client, vis, func(*gateway.PresencesReplaceEvent) { b.invalidatePresence() })
+	gtkcord.OnCancellable(client, vis, func(*gateway.SessionsReplaceEvent) { b.invalidatePresence() })
+	gtkcord.OnCancellable(client, vis, func(*gateway.UserSettingsUpdateEvent) { b.invalidatePresence() })
+	gtkcord.OnCancellable(client, vis, func(*gateway.ReadyEvent) { b.invalidatePresence() })
 
 	me, _ := client.Me()
 	if me != nil {
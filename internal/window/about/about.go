@@ -2,6 +2,7 @@ package about
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
 	"runtime/debug"
@@ -10,6 +11,7 @@ import (
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/components/logui"
+	"libdb.so/dissent/internal/gtkcord"
 )
 
 // New creates a new about window.
@@ -35,7 +37,7 @@ func New(ctx context.Context) *adw.AboutDialog {
 	build, ok := debug.ReadBuildInfo()
 	if ok {
 		about.AddCreditSection("Dependency Authors", modAuthors(build.Deps))
-		about.SetDebugInfo(debugInfo(build))
+		about.SetDebugInfo(debugInfo(ctx, build))
 		about.SetDebugInfoFilename("dissent-debuginfo")
 
 		version := buildVersion(build.Settings)
@@ -111,7 +113,7 @@ func modAuthors(mods []*debug.Module) []string {
 	return authors
 }
 
-func debugInfo(build *debug.BuildInfo) string {
+func debugInfo(ctx context.Context, build *debug.BuildInfo) string {
 	var s strings.Builder
 	fmt.Fprintf(&s, "Version: %s", buildVersion(build.Settings))
 	s.WriteString("\n")
@@ -120,6 +122,21 @@ func debugInfo(build *debug.BuildInfo) string {
 	s.WriteString(build.String())
 	s.WriteString("\n\n")
 
+	if state := gtkcord.FromContext(ctx); state != nil {
+		if cmd, ok := state.IdentifyCommand(); ok {
+			b, _ := json.MarshalIndent(cmd, "", "  ")
+			s.WriteString("Gateway Identify (token removed):\n")
+			s.Write(b)
+			s.WriteString("\n\n")
+		}
+	}
+
+	if stats, ok := gtkcord.TrackedHandlers(); ok {
+		s.WriteString("Tracked state handlers:\n")
+		s.WriteString(stats.String())
+		s.WriteString("\n")
+	}
+
 	s.WriteString("Last 50 log lines:\n")
 	s.WriteString(lastNLogLines(50))
 	s.WriteString("\n\n")
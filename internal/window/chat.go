@@ -15,6 +15,7 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/ningen/v3/states/read"
@@ -27,24 +28,36 @@ import (
 	"libdb.so/dissent/internal/window/quickswitcher"
 )
 
-var lastGuildKey = app.NewSingleStateKey[discord.GuildID]("last-guild-state")
-var lastChannelKey = app.NewStateKey[discord.ChannelID]("guild-last-open")
-
-// TODO: refactor this to support TabOverview. We do this by refactoring Sidebar
-// out completely and merging it into ChatPage. We can then get rid of the logic
-// to keep the Sidebar in sync with the ChatPage, since each tab will have its
-// own Sidebar.
+var lastGuildKey = gtkcord.NewAccountSingleStateKey[discord.GuildID]("last-guild-state")
+var lastChannelKey = gtkcord.NewAccountStateKey[discord.ChannelID]("guild-last-open")
 
+// ChatPage is the page shown while logged in. All tabs share one Sidebar, and
+// each tab keeps the state of the Sidebar, which is restored when the tab is
+// selected again.
 type ChatPage struct {
 	*adw.OverlaySplitView
 	Sidebar     *sidebar.Sidebar
 	RightHeader *adw.HeaderBar
 	rightTitle  *adw.Bin
 
+	historyBack    *gtk.Button
+	historyForward *gtk.Button
+
+	// ConnectionBanner is shown while the gateway is reconnecting.
+	ConnectionBanner *adw.Banner
+	// OfflineIndicator is shown in the header while the gateway is not
+	// connected, since everything shown may be out of date.
+	OfflineIndicator *gtk.Box
+
 	tabView *adw.TabView
 
 	lastGuildState   *app.TypedSingleState[discord.GuildID]
 	lastChannelState *app.TypedState[discord.ChannelID]
+	openTabsState    *app.TypedSingleState[savedTabs]
+	tabsRestored     bool
+	// detached is true if the page is in a window that tabs were moved into.
+	// Only the page of the window that owns the state saves its tabs.
+	detached bool
 
 	lastGuild discord.GuildID
 
@@ -52,8 +65,10 @@ type ChatPage struct {
 	// On view change, these buttons will be removed.
 	lastButtons []gtk.Widgetter
 
-	tabs map[uintptr]*chatTab // K: *adw.TabPage
-	ctx  context.Context
+	tabs      map[uintptr]*chatTab // K: *adw.TabPage
+	activeTab *chatTab             // tab that the sidebar belongs to
+	menuPage  *adw.TabPage         // page of the last opened tab menu
+	ctx       context.Context
 }
 
 type chatPageView struct {
@@ -75,6 +90,11 @@ var chatPageCSS = cssutil.Applier("window-chatpage", `
 	.right-header-channel-icon {
 		margin-right: 4px;
 	}
+	.window-chatpage-offline {
+		margin: 0 6px;
+		font-weight: bold;
+		color: @warning_color;
+	}
 `)
 
 func NewChatPage(ctx context.Context, w *Window) *ChatPage {
@@ -83,6 +103,7 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 		tabs:             make(map[uintptr]*chatTab),
 		lastGuildState:   lastGuildKey.Acquire(ctx),
 		lastChannelState: lastChannelKey.Acquire(ctx),
+		openTabsState:    openTabsKey.Acquire(ctx),
 	}
 
 	p.tabView = adw.NewTabView()
@@ -92,15 +113,26 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 		p.onActiveTabChange(p.tabView.SelectedPage())
 	})
 	p.tabView.ConnectClosePage(func(page *adw.TabPage) bool {
-		_, ok := p.tabs[page.Native()]
+		tab, ok := p.tabs[page.Native()]
 		if ok {
+			if p.activeTab == tab {
+				p.activeTab = nil
+			}
 			delete(p.tabs, page.Native())
 			p.tabView.ClosePageFinish(page, true)
+			p.saveTabs()
 		}
 		return gdk.EVENT_STOP
 	})
-
-	p.Sidebar = sidebar.NewSidebar(ctx)
+	p.tabView.ConnectPageReordered(func(*adw.TabPage, int) { p.saveTabs() })
+	p.tabView.ConnectPageDetached(func(page *adw.TabPage, _ int) { p.tabDetached(page) })
+	p.tabView.ConnectPageAttached(func(page *adw.TabPage, _ int) { p.tabAttached(page) })
+	p.tabView.ConnectCreateWindow(p.createTabWindow)
+	p.bindTabMenu()
+
+	p.Sidebar = sidebar.NewSidebar(ctx, func() []gtkutil.PopoverMenuItem {
+		return accountMenuItems(ctx)
+	})
 	p.Sidebar.SetHAlign(gtk.AlignStart)
 
 	p.rightTitle = adw.NewBin()
@@ -109,10 +141,43 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 
 	back := backbutton.New()
 
+	p.historyBack = gtk.NewButtonFromIconName("go-previous-symbolic")
+	p.historyBack.SetTooltipText("Go Back")
+	p.historyBack.SetSensitive(false)
+	p.historyBack.ConnectClicked(p.GoBack)
+
+	p.historyForward = gtk.NewButtonFromIconName("go-next-symbolic")
+	p.historyForward.SetTooltipText("Go Forward")
+	p.historyForward.SetSensitive(false)
+	p.historyForward.ConnectClicked(p.GoForward)
+
+	historyButtons := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	historyButtons.AddCSSClass("linked")
+	historyButtons.Append(p.historyBack)
+	historyButtons.Append(p.historyForward)
+
 	newTabButton := gtk.NewButtonFromIconName("list-add-symbolic")
 	newTabButton.SetTooltipText("Open a New Tab")
 	newTabButton.ConnectClicked(func() { p.newTab() })
 
+	splitButton := gtk.NewMenuButton()
+	splitButton.SetIconName("view-dual-symbolic")
+	splitButton.SetTooltipText("Split View")
+	splitButton.SetMenuModel(gtkutil.MenuPair([][2]string{
+		{"Split _Left and Right", "win.split-left-right"},
+		{"Split _Top and Bottom", "win.split-top-bottom"},
+		{"_Close Split", "win.close-split"},
+	}))
+
+	offlineIcon := gtk.NewImageFromIconName("network-offline-symbolic")
+	offlineLabel := gtk.NewLabel(locale.Get("Offline"))
+
+	p.OfflineIndicator = gtk.NewBox(gtk.OrientationHorizontal, 4)
+	p.OfflineIndicator.AddCSSClass("window-chatpage-offline")
+	p.OfflineIndicator.SetTooltipText(locale.Get("Showing what was already loaded until Discord reconnects"))
+	p.OfflineIndicator.Append(offlineIcon)
+	p.OfflineIndicator.Append(offlineLabel)
+
 	p.RightHeader = adw.NewHeaderBar()
 	p.RightHeader.AddCSSClass("titlebar")
 	p.RightHeader.AddCSSClass("right-header")
@@ -121,8 +186,15 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 	p.RightHeader.SetShowBackButton(false) // this is useless with OverlaySplitView
 	p.RightHeader.SetShowTitle(false)
 	p.RightHeader.PackStart(back)
+	p.RightHeader.PackStart(historyButtons)
 	p.RightHeader.PackStart(p.rightTitle)
 	p.RightHeader.PackEnd(newTabButton)
+	p.RightHeader.PackEnd(splitButton)
+	p.RightHeader.PackEnd(p.OfflineIndicator)
+
+	p.ConnectionBanner = adw.NewBanner("")
+	p.ConnectionBanner.SetButtonLabel(locale.Get("Retry Now"))
+	p.ConnectionBanner.ConnectButtonClicked(w.RetryNow)
 
 	tabBar := adw.NewTabBar()
 	tabBar.AddCSSClass("window-chatpage-tabbar")
@@ -134,6 +206,7 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 	rightBox.SetTopBarStyle(adw.ToolbarFlat)
 	rightBox.SetHExpand(true)
 	rightBox.AddTopBar(p.RightHeader)
+	rightBox.AddTopBar(p.ConnectionBanner)
 	rightBox.AddTopBar(tabBar)
 	rightBox.SetContent(p.tabView)
 
@@ -154,6 +227,9 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 	w.AddBreakpoint(breakpoint)
 
 	state := gtkcord.FromContext(ctx)
+	p.OfflineIndicator.SetVisible(state.IsOffline())
+	gtkcord.OnConnectionChange(state, p, p.OfflineIndicator.SetVisible)
+
 	w.ConnectDestroy(state.AddHandler(
 		func(*gateway.MessageCreateEvent) { p.updateWindowTitle() },
 		func(*gateway.MessageUpdateEvent) { p.updateWindowTitle() },
@@ -165,6 +241,18 @@ func NewChatPage(ctx context.Context, w *Window) *ChatPage {
 	return &p
 }
 
+// ShowConnectionBanner reveals the banner with the given text, which tells the
+// user that the chat may be out of date.
+func (p *ChatPage) ShowConnectionBanner(text string) {
+	p.ConnectionBanner.SetTitle(text)
+	p.ConnectionBanner.SetRevealed(true)
+}
+
+// HideConnectionBanner hides the banner once the gateway is connected again.
+func (p *ChatPage) HideConnectionBanner() {
+	p.ConnectionBanner.SetRevealed(false)
+}
+
 // OpenQuickSwitcher opens the Quick Switcher dialog.
 func (p *ChatPage) OpenQuickSwitcher() { quickswitcher.ShowDialog(p.ctx) }
 
@@ -180,19 +268,26 @@ func (p *ChatPage) SwitchToPlaceholder() {
 	p.onActiveTabChange(p.tabView.Page(tab))
 }
 
-// SwitchToMessages reopens a new message page of the same channel ID if the
-// user is opening one. Otherwise, the placeholder is seen.
+// SwitchToMessages restores the tabs that were open last time. If there are
+// none, it reopens a new message page of the last opened channel instead.
+// Otherwise, the placeholder is seen.
 func (p *ChatPage) SwitchToMessages() {
-	tab := p.currentTab()
-	tab.switchToPlaceholder()
-
-	// Restore the last opened channel if there is one.
-	p.lastGuildState.Get(func(id discord.GuildID) {
-		if id.IsValid() {
-			p.OpenGuild(id)
-		} else {
-			p.OpenDMs()
+	p.restoreTabs(func(restored bool) {
+		if restored {
+			return
 		}
+
+		tab := p.currentTab()
+		tab.switchToPlaceholder()
+
+		// Restore the last opened channel if there is one.
+		p.lastGuildState.Get(func(id discord.GuildID) {
+			if id.IsValid() {
+				p.OpenGuild(id)
+			} else {
+				p.OpenDMs()
+			}
+		})
 	})
 }
 
@@ -235,8 +330,9 @@ func (p *ChatPage) OpenChannel(chID discord.ChannelID) {
 	var tab *chatTab
 	var reselect bool
 	for _, t := range p.tabs {
-		if t.alreadyOpens(chID) {
+		if pane := t.paneOpening(chID); pane != nil {
 			tab = t
+			tab.focus(pane)
 			reselect = true
 			break
 		}
@@ -266,6 +362,17 @@ func (p *ChatPage) OpenChannel(chID discord.ChannelID) {
 	}
 }
 
+// OpenMessage opens the channel with the given ID and scrolls to the message.
+// The message is added to the history as a jump.
+func (p *ChatPage) OpenMessage(chID discord.ChannelID, msgID discord.MessageID) {
+	p.OpenChannel(chID)
+
+	tab := p.currentTab()
+	if pane := tab.focused; pane.alreadyOpens(chID) {
+		pane.openMessage(msgID)
+	}
+}
+
 func updateTabInfo(ctx context.Context, page *adw.TabPage, chID discord.ChannelID) {
 	if chID.IsValid() {
 		page.SetIcon(gio.NewThemedIcon("channel-symbolic"))
@@ -301,15 +408,65 @@ func (p *ChatPage) currentTab() *chatTab {
 func (p *ChatPage) newTab() *chatTab {
 	tab := newChatTab(p.ctx)
 
-	page := p.tabView.Append(tab)
-	updateTabInfo(p.ctx, page, 0)
-
-	p.tabs[page.Native()] = tab
+	page := p.addTab(tab, false)
 	p.tabView.SetSelectedPage(page)
 
 	return tab
 }
 
+// addTab adds the tab to the end of the tab view.
+func (p *ChatPage) addTab(tab *chatTab, pinned bool) *adw.TabPage {
+	var page *adw.TabPage
+	if pinned {
+		page = p.tabView.AppendPinned(tab)
+	} else {
+		page = p.tabView.Append(tab)
+	}
+	updateTabInfo(p.ctx, page, tab.channelID())
+	p.registerTab(page, tab)
+	return page
+}
+
+func (p *ChatPage) registerTab(page *adw.TabPage, tab *chatTab) {
+	tab.onFocus = func() {
+		// The tab is named after its focused pane.
+		updateTabInfo(p.ctx, page, tab.channelID())
+
+		selected := p.tabView.SelectedPage()
+		if selected != nil && selected.Native() == page.Native() {
+			p.onActiveTabChange(page)
+		}
+	}
+	tab.onNavigate = p.updateHistoryButtons
+
+	p.tabs[page.Native()] = tab
+}
+
+// SplitView splits the current tab into two panes, so that two channels can be
+// watched at once. The new pane is focused. If the tab is already split, then
+// only its orientation is changed.
+func (p *ChatPage) SplitView(orientation gtk.Orientation) {
+	tab := p.currentTab()
+	tab.split(orientation)
+	p.saveTabs()
+}
+
+// CloseSplit closes the focused pane of the current tab if it is split.
+func (p *ChatPage) CloseSplit() {
+	page := p.tabView.SelectedPage()
+	if page == nil {
+		return
+	}
+
+	tab := p.tabs[page.Native()]
+	if tab == nil || !tab.isSplit() {
+		return
+	}
+
+	tab.unsplit()
+	p.saveTabs()
+}
+
 func (p *ChatPage) onActiveTabChange(page *adw.TabPage) {
 	// Remove the previous header buttons.
 	for _, button := range p.lastButtons {
@@ -329,11 +486,13 @@ func (p *ChatPage) onActiveTabChange(page *adw.TabPage) {
 			return
 		}
 
+		// Restored tabs only load their channel once selected.
+		tab.load()
 		chID = tab.channelID()
 
 		// Add the new header buttons.
-		if tab.messageView != nil {
-			p.lastButtons = tab.messageView.HeaderButtons()
+		if view := tab.messageView(); view != nil {
+			p.lastButtons = view.HeaderButtons()
 			for i := len(p.lastButtons) - 1; i >= 0; i-- {
 				button := p.lastButtons[i]
 				p.RightHeader.PackEnd(button)
@@ -341,13 +500,34 @@ func (p *ChatPage) onActiveTabChange(page *adw.TabPage) {
 		}
 	}
 
+	p.updateHistoryButtons()
+	p.saveTabs()
+
+	// Each tab keeps its own sidebar, so the previous tab's sidebar is kept
+	// before the new one is shown.
+	var restored bool
+	if tab != p.activeTab {
+		if p.activeTab != nil {
+			p.activeTab.sidebar = p.Sidebar.SaveState()
+		}
+		p.activeTab = tab
+
+		if tab != nil && tab.sidebar != nil {
+			p.Sidebar.RestoreState(tab.sidebar)
+			restored = true
+		}
+	}
+
 	// Update the left guild list and channel list.
-	if chID.IsValid() {
+	switch {
+	case restored:
+		// The tab's sidebar is shown as it was left.
+	case chID.IsValid():
 		// TODO: it really has to get rid of this SelectChannel call...
 		// It's really hard for it to try and have a SetSelectedChannel function
 		// because of how the SelectionChanged signal works.
 		p.Sidebar.SelectChannel(chID)
-	} else {
+	default:
 		// Hack to ensure that the guild item is selected when we have no
 		// channel on display.
 		if p.lastGuild.IsValid() {
@@ -399,15 +579,227 @@ func (p *ChatPage) updateWindowTitle() {
 	win.SetTitle(title)
 }
 
+// chatTab is a tab of the ChatPage. It shows a single pane, or two panes once
+// it is split. Everything done to the tab is done to its focused pane.
 type chatTab struct {
+	*adw.Bin
+	panes   []*chatPane // 1 or 2
+	focused *chatPane
+	paned   *gtk.Paned // nil unless split
+	ctx     context.Context
+
+	// sidebar is the state of the sidebar when the tab was last selected.
+	sidebar *sidebar.State
+
+	// onFocus is called when the user focuses the other pane.
+	onFocus func()
+	// onNavigate is called when the history of a pane changes without its
+	// channel being switched.
+	onNavigate func()
+}
+
+var chatTabCSS = cssutil.Applier("window-chat-tab", `
+	.window-chat-split > .window-message-page {
+		border-top: 2px solid transparent;
+	}
+	.window-chat-split > .window-message-page.window-chat-pane-focused {
+		border-top-color: alpha(@accent_color, 0.65);
+	}
+`)
+
+func newChatTab(ctx context.Context) *chatTab {
+	t := chatTab{ctx: ctx}
+
+	pane := t.newPane()
+	t.panes = []*chatPane{pane}
+	t.focused = pane
+
+	t.Bin = adw.NewBin()
+	t.Bin.SetChild(pane)
+	chatTabCSS(t)
+
+	return &t
+}
+
+func (t *chatTab) newPane() *chatPane {
+	pane := newChatPane(t.ctx)
+	pane.onNavigate = func() {
+		if t.onNavigate != nil {
+			t.onNavigate()
+		}
+	}
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectEnter(func() { t.focus(pane) })
+	pane.AddController(focus)
+
+	// Clicking on something that can't be focused should still focus the
+	// pane.
+	click := gtk.NewGestureClick()
+	click.SetPropagationPhase(gtk.PhaseCapture)
+	click.ConnectPressed(func(int, float64, float64) { t.focus(pane) })
+	pane.AddController(click)
+
+	return pane
+}
+
+func (t *chatTab) focus(pane *chatPane) {
+	if t.focused == pane {
+		return
+	}
+
+	t.focused = pane
+	t.updateFocusedPane()
+
+	if t.onFocus != nil {
+		t.onFocus()
+	}
+}
+
+func (t *chatTab) updateFocusedPane() {
+	for _, pane := range t.panes {
+		if t.isSplit() && pane == t.focused {
+			pane.AddCSSClass("window-chat-pane-focused")
+		} else {
+			pane.RemoveCSSClass("window-chat-pane-focused")
+		}
+	}
+}
+
+func (t *chatTab) isSplit() bool {
+	return t.paned != nil
+}
+
+// split splits the tab into two panes with the given orientation and focuses
+// the new one. If the tab is already split, then only the orientation is
+// changed.
+func (t *chatTab) split(orientation gtk.Orientation) {
+	if t.paned != nil {
+		t.paned.SetOrientation(orientation)
+		return
+	}
+
+	first := t.panes[0]
+	second := t.newPane()
+	t.panes = append(t.panes, second)
+
+	t.Bin.SetChild(nil)
+
+	t.paned = gtk.NewPaned(orientation)
+	t.paned.AddCSSClass("window-chat-split")
+	t.paned.SetShrinkStartChild(false)
+	t.paned.SetShrinkEndChild(false)
+	t.paned.SetStartChild(first)
+	t.paned.SetEndChild(second)
+	t.Bin.SetChild(t.paned)
+
+	t.focus(second)
+}
+
+// unsplit closes the focused pane, leaving the other one.
+func (t *chatTab) unsplit() {
+	if t.paned == nil {
+		return
+	}
+
+	var kept *chatPane
+	for _, pane := range t.panes {
+		if pane != t.focused {
+			kept = pane
+		}
+	}
+
+	t.paned.SetStartChild(nil)
+	t.paned.SetEndChild(nil)
+	t.paned = nil
+
+	t.panes = []*chatPane{kept}
+	t.Bin.SetChild(kept)
+
+	t.focus(kept)
+}
+
+// splitOrientation returns the orientation of the split. It returns false if
+// the tab isn't split.
+func (t *chatTab) splitOrientation() (gtk.Orientation, bool) {
+	if t.paned == nil {
+		return 0, false
+	}
+	return t.paned.Orientation(), true
+}
+
+// paneOpening returns the pane that has the channel open, or nil if none does.
+func (t *chatTab) paneOpening(id discord.ChannelID) *chatPane {
+	for _, pane := range t.panes {
+		if pane.alreadyOpens(id) {
+			return pane
+		}
+	}
+	return nil
+}
+
+func (t *chatTab) alreadyOpens(id discord.ChannelID) bool {
+	return t.focused.alreadyOpens(id)
+}
+
+func (t *chatTab) channelID() discord.ChannelID {
+	return t.focused.channelID()
+}
+
+// setContext sets the context of the tab once it is moved into another window.
+// The channels are opened again once load is called.
+func (t *chatTab) setContext(ctx context.Context) {
+	t.ctx = ctx
+	// The sidebar belongs to the previous window.
+	t.sidebar = nil
+	for _, pane := range t.panes {
+		pane.setContext(ctx)
+	}
+}
+
+func (t *chatTab) messageView() *messages.View {
+	return t.focused.messageView
+}
+
+// openLazily sets the channel that the focused pane opens once load is called.
+func (t *chatTab) openLazily(id discord.ChannelID) {
+	t.focused.openLazily(id)
+}
+
+// load opens the channels given to openLazily. Every pane is loaded, since they
+// are all seen at once.
+func (t *chatTab) load() {
+	for _, pane := range t.panes {
+		pane.load()
+	}
+}
+
+func (t *chatTab) switchToPlaceholder() bool {
+	return t.focused.switchToPlaceholder()
+}
+
+func (t *chatTab) switchToChannel(id discord.ChannelID) bool {
+	return t.focused.switchToChannel(id)
+}
+
+// chatPane shows a single message view, or a placeholder if no channel is open.
+type chatPane struct {
 	*gtk.Stack
 	placeholder gtk.Widgetter
 	messageView *messages.View // nilable
 	ctx         context.Context
+
+	// lazyChannel is opened once load is called.
+	lazyChannel discord.ChannelID
+
+	history paneHistory
+	// onNavigate is called when the history changes without the channel
+	// being switched, such as when the user jumps to a message.
+	onNavigate func()
 }
 
-func newChatTab(ctx context.Context) *chatTab {
-	var t chatTab
+func newChatPane(ctx context.Context) *chatPane {
+	var t chatPane
 	t.ctx = ctx
 	t.placeholder = newEmptyMessagePlaceholder()
 
@@ -420,30 +812,78 @@ func newChatTab(ctx context.Context) *chatTab {
 	return &t
 }
 
-func (t *chatTab) alreadyOpens(id discord.ChannelID) bool {
+func (t *chatPane) alreadyOpens(id discord.ChannelID) bool {
 	return t.channelID() == id
 }
 
-func (t *chatTab) channelID() discord.ChannelID {
+func (t *chatPane) channelID() discord.ChannelID {
 	if t.messageView == nil {
-		return 0
+		return t.lazyChannel
 	}
 	return t.messageView.ChannelID()
 }
 
-func (t *chatTab) switchToPlaceholder() bool {
+// openLazily sets the channel to open once load is called, which is once the
+// tab is selected. This avoids fetching messages for tabs that aren't seen.
+func (t *chatPane) openLazily(id discord.ChannelID) {
+	t.lazyChannel = id
+}
+
+func (t *chatPane) setContext(ctx context.Context) {
+	t.saveAnchor()
+	t.ctx = ctx
+	if t.messageView != nil {
+		t.lazyChannel = t.messageView.ChannelID()
+	}
+}
+
+// load opens the channel given to openLazily, if any.
+func (t *chatPane) load() {
+	id := t.lazyChannel
+	if !id.IsValid() {
+		return
+	}
+
+	if entry, ok := t.history.current(); ok && entry.channelID == id {
+		// Reopen the channel where it was left.
+		t.show(entry, false)
+	} else {
+		t.switchToChannel(id)
+	}
+}
+
+func (t *chatPane) switchToPlaceholder() bool {
 	return t.switchToChannel(0)
 }
 
-func (t *chatTab) switchToChannel(id discord.ChannelID) bool {
-	if t.alreadyOpens(id) {
+// switchToChannel opens the channel as a new entry in the history.
+func (t *chatPane) switchToChannel(id discord.ChannelID) bool {
+	if t.alreadyOpens(id) && !t.lazyChannel.IsValid() {
 		return false
 	}
 
+	t.saveAnchor()
+	t.history.push(historyEntry{channelID: id})
+	t.show(historyEntry{channelID: id}, false)
+
+	return true
+}
+
+// show shows the history entry. If reuse is true and the entry is in the
+// channel that is already open, then the view is only scrolled.
+func (t *chatPane) show(entry historyEntry, reuse bool) {
+	t.lazyChannel = 0
+
 	old := t.messageView
+	if reuse && old != nil && old.ChannelID() == entry.channelID {
+		old.RestoreAnchor(entry.anchor)
+		return
+	}
 
-	if id.IsValid() {
-		t.messageView = messages.NewView(t.ctx, id)
+	if entry.channelID.IsValid() {
+		t.messageView = messages.NewView(t.ctx, entry.channelID)
+		t.messageView.OnJump(t.jumped)
+		t.messageView.RestoreAnchor(entry.anchor)
 		t.messageView.FetchBacklog()
 
 		t.Stack.AddChild(t.messageView)
@@ -465,8 +905,6 @@ func (t *chatTab) switchToChannel(id discord.ChannelID) bool {
 			return false
 		})
 	}
-
-	return true
 }
 
 func newEmptyMessagePlaceholder() gtk.Widgetter {
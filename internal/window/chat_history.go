@@ -0,0 +1,143 @@
+package window
+
+import (
+	"github.com/diamondburned/arikawa/v3/discord"
+	"libdb.so/dissent/internal/messages"
+)
+
+// maxHistory is the maximum number of entries kept in the history of a pane.
+const maxHistory = 100
+
+// historyEntry is a place that the user has navigated to in a pane.
+type historyEntry struct {
+	channelID discord.ChannelID // 0 for the placeholder
+	anchor    messages.Anchor
+}
+
+// paneHistory is the back and forward history of a pane.
+type paneHistory struct {
+	entries []historyEntry
+	index   int // index of the shown entry
+}
+
+func (h *paneHistory) current() (historyEntry, bool) {
+	if len(h.entries) == 0 {
+		return historyEntry{}, false
+	}
+	return h.entries[h.index], true
+}
+
+// push adds the entry after the shown one, dropping the entries that could be
+// gone forward to.
+func (h *paneHistory) push(entry historyEntry) {
+	if len(h.entries) > 0 {
+		h.entries = h.entries[:h.index+1]
+	}
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+
+	h.index = len(h.entries) - 1
+}
+
+// move moves delta entries back or forward and returns the entry to show.
+func (h *paneHistory) move(delta int) (historyEntry, bool) {
+	i := h.index + delta
+	if i < 0 || i >= len(h.entries) {
+		return historyEntry{}, false
+	}
+
+	h.index = i
+	return h.entries[i], true
+}
+
+func (h *paneHistory) canMove(delta int) bool {
+	i := h.index + delta
+	return i >= 0 && i < len(h.entries)
+}
+
+// saveAnchor keeps the scroll position of the shown entry, so that it is
+// restored once the user comes back to it. Entries of messages that were
+// jumped to keep their message instead.
+func (t *chatPane) saveAnchor() {
+	if t.messageView == nil || len(t.history.entries) == 0 {
+		return
+	}
+
+	entry := &t.history.entries[t.history.index]
+	if entry.channelID == t.messageView.ChannelID() && !entry.anchor.MessageID.IsValid() {
+		entry.anchor = t.messageView.Anchor()
+	}
+}
+
+// jumped adds the message that the user jumped to into the history.
+func (t *chatPane) jumped(id discord.MessageID) {
+	t.saveAnchor()
+	t.history.push(historyEntry{
+		channelID: t.channelID(),
+		anchor:    messages.Anchor{MessageID: id},
+	})
+
+	if t.onNavigate != nil {
+		t.onNavigate()
+	}
+}
+
+// openMessage jumps to the message in the open channel once it is loaded.
+func (t *chatPane) openMessage(id discord.MessageID) {
+	if t.messageView == nil {
+		return
+	}
+
+	t.jumped(id)
+	t.messageView.RestoreAnchor(messages.Anchor{MessageID: id})
+}
+
+// navigate goes delta entries back or forward in the history.
+func (t *chatPane) navigate(delta int) bool {
+	if !t.history.canMove(delta) {
+		return false
+	}
+
+	t.saveAnchor()
+	entry, _ := t.history.move(delta)
+	t.show(entry, true)
+
+	return true
+}
+
+// GoBack goes back to the previous channel or message in the current tab.
+func (p *ChatPage) GoBack() { p.navigateHistory(-1) }
+
+// GoForward goes forward to the next channel or message in the current tab.
+func (p *ChatPage) GoForward() { p.navigateHistory(+1) }
+
+func (p *ChatPage) navigateHistory(delta int) {
+	page := p.tabView.SelectedPage()
+	if page == nil {
+		return
+	}
+
+	tab := p.tabs[page.Native()]
+	if tab == nil || !tab.focused.navigate(delta) {
+		return
+	}
+
+	updateTabInfo(p.ctx, page, tab.channelID())
+	p.onActiveTabChange(page)
+}
+
+func (p *ChatPage) updateHistoryButtons() {
+	var back, forward bool
+	if page := p.tabView.SelectedPage(); page != nil {
+		if tab := p.tabs[page.Native()]; tab != nil {
+			back = tab.focused.history.canMove(-1)
+			forward = tab.focused.history.canMove(+1)
+		}
+	}
+
+	p.historyBack.SetSensitive(back)
+	p.historyForward.SetSensitive(forward)
+}
@@ -0,0 +1,123 @@
+package window
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+)
+
+func TestPaneHistory(t *testing.T) {
+	type step struct {
+		push  discord.ChannelID // pushed if non-zero
+		move  int               // moved by if non-zero and push is zero
+		moved bool              // whether move succeeded
+
+		entries []discord.ChannelID
+		index   int
+		back    bool
+		forward bool
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "push",
+			steps: []step{
+				{push: 1, entries: []discord.ChannelID{1}, index: 0},
+				{push: 2, entries: []discord.ChannelID{1, 2}, index: 1, back: true},
+				{push: 3, entries: []discord.ChannelID{1, 2, 3}, index: 2, back: true},
+			},
+		},
+		{
+			name: "move",
+			steps: []step{
+				{push: 1, entries: []discord.ChannelID{1}, index: 0},
+				{push: 2, entries: []discord.ChannelID{1, 2}, index: 1, back: true},
+				{move: -1, moved: true, entries: []discord.ChannelID{1, 2}, index: 0, forward: true},
+				{move: -1, moved: false, entries: []discord.ChannelID{1, 2}, index: 0, forward: true},
+				{move: +1, moved: true, entries: []discord.ChannelID{1, 2}, index: 1, back: true},
+				{move: +1, moved: false, entries: []discord.ChannelID{1, 2}, index: 1, back: true},
+			},
+		},
+		{
+			name: "push truncates forward",
+			steps: []step{
+				{push: 1, entries: []discord.ChannelID{1}, index: 0},
+				{push: 2, entries: []discord.ChannelID{1, 2}, index: 1, back: true},
+				{push: 3, entries: []discord.ChannelID{1, 2, 3}, index: 2, back: true},
+				{move: -2, moved: true, entries: []discord.ChannelID{1, 2, 3}, index: 0, forward: true},
+				{push: 4, entries: []discord.ChannelID{1, 4}, index: 1, back: true},
+			},
+		},
+		{
+			name: "empty",
+			steps: []step{
+				{move: -1, moved: false, index: 0},
+				{move: +1, moved: false, index: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h paneHistory
+
+			for i, step := range test.steps {
+				if step.push != 0 {
+					h.push(historyEntry{channelID: step.push})
+				} else {
+					entry, ok := h.move(step.move)
+					if ok != step.moved {
+						t.Fatalf("step %d: expected move to return %v, got %v", i, step.moved, ok)
+					}
+					if ok && entry.channelID != step.entries[step.index] {
+						t.Fatalf("step %d: expected channel %d, got %d", i, step.entries[step.index], entry.channelID)
+					}
+				}
+
+				var channels []discord.ChannelID
+				for _, entry := range h.entries {
+					channels = append(channels, entry.channelID)
+				}
+
+				if !slices.Equal(channels, step.entries) {
+					t.Fatalf("step %d: expected entries %v, got %v", i, step.entries, channels)
+				}
+				if h.index != step.index {
+					t.Fatalf("step %d: expected index %d, got %d", i, step.index, h.index)
+				}
+				if back := h.canMove(-1); back != step.back {
+					t.Errorf("step %d: expected canMove(-1) to be %v, got %v", i, step.back, back)
+				}
+				if forward := h.canMove(+1); forward != step.forward {
+					t.Errorf("step %d: expected canMove(+1) to be %v, got %v", i, step.forward, forward)
+				}
+			}
+		})
+	}
+}
+
+func TestPaneHistoryLimit(t *testing.T) {
+	var h paneHistory
+	for i := 1; i <= maxHistory+10; i++ {
+		h.push(historyEntry{channelID: discord.ChannelID(i)})
+	}
+
+	if len(h.entries) != maxHistory {
+		t.Fatalf("expected %d entries, got %d", maxHistory, len(h.entries))
+	}
+	if h.index != maxHistory-1 {
+		t.Fatalf("expected index %d, got %d", maxHistory-1, h.index)
+	}
+	if first := h.entries[0].channelID; first != 11 {
+		t.Fatalf("expected the oldest entries to be dropped, first is %d", first)
+	}
+
+	entry, ok := h.current()
+	if !ok || entry.channelID != maxHistory+10 {
+		t.Fatalf("expected the last pushed entry to be current, got %d", entry.channelID)
+	}
+}
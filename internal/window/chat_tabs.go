@@ -0,0 +1,271 @@
+package window
+
+import (
+	"log/slog"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"libdb.so/ctxt"
+	"libdb.so/dissent/internal/gtkcord"
+)
+
+var openTabsKey = gtkcord.NewAccountSingleStateKey[savedTabs]("open-tabs")
+
+// savedTabs is the set of open tabs, which is restored on the next start.
+type savedTabs struct {
+	Tabs []savedTab `json:"tabs"`
+	// Selected is the index of the selected tab.
+	Selected int `json:"selected"`
+}
+
+// savedTab is a single tab in savedTabs.
+type savedTab struct {
+	// ChannelID is the channel opened in the tab. It is 0 for empty tabs.
+	ChannelID discord.ChannelID `json:"channel_id,omitempty"`
+	Pinned    bool              `json:"pinned,omitempty"`
+	// Split is either "horizontal" or "vertical" if the tab is split, in
+	// which case SplitChannelID is the channel opened in the second pane.
+	Split          string            `json:"split,omitempty"`
+	SplitChannelID discord.ChannelID `json:"split_channel_id,omitempty"`
+}
+
+func splitString(orientation gtk.Orientation) string {
+	if orientation == gtk.OrientationVertical {
+		return "vertical"
+	}
+	return "horizontal"
+}
+
+func parseSplit(split string) (gtk.Orientation, bool) {
+	switch split {
+	case "horizontal":
+		return gtk.OrientationHorizontal, true
+	case "vertical":
+		return gtk.OrientationVertical, true
+	default:
+		return 0, false
+	}
+}
+
+// bindTabMenu adds the context menu of each tab.
+func (p *ChatPage) bindTabMenu() {
+	pin := gtkutil.ActionFunc("pin", func() { p.setMenuPagePinned(true) })
+	unpin := gtkutil.ActionFunc("unpin", func() { p.setMenuPagePinned(false) })
+	move := gtkutil.ActionFunc("move-to-window", func() {
+		if p.menuPage != nil {
+			p.moveToNewWindow(p.menuPage)
+		}
+	})
+	closeTab := gtkutil.ActionFunc("close", func() {
+		if p.menuPage != nil {
+			p.tabView.ClosePage(p.menuPage)
+		}
+	})
+
+	p.tabView.InsertActionGroup("tab", gtkutil.ActionGroup(pin, unpin, move, closeTab))
+	p.tabView.SetMenuModel(gtkutil.MenuPair([][2]string{
+		{"_Pin Tab", "tab.pin"},
+		{"_Unpin Tab", "tab.unpin"},
+		{"_Move to New Window", "tab.move-to-window"},
+		{"_Close Tab", "tab.close"},
+	}))
+	p.tabView.ConnectSetupMenu(func(page *adw.TabPage) {
+		// The menu is set up again with a nil page once it is closed, which
+		// may be before the action is activated, so keep the last page.
+		if page == nil {
+			return
+		}
+		p.menuPage = page
+		pin.SetEnabled(!page.Pinned())
+		unpin.SetEnabled(page.Pinned())
+		closeTab.SetEnabled(!page.Pinned())
+	})
+}
+
+func (p *ChatPage) setMenuPagePinned(pinned bool) {
+	if p.menuPage != nil {
+		p.tabView.SetPagePinned(p.menuPage, pinned)
+		p.saveTabs()
+	}
+}
+
+// restoreTabs restores the tabs that were open last time. The channels are
+// only loaded once their tabs are selected. It calls done with false if there
+// was nothing to restore.
+func (p *ChatPage) restoreTabs(done func(restored bool)) {
+	if p.tabsRestored || p.detached || p.tabView.NPages() > 0 {
+		p.tabsRestored = true
+		done(false)
+		return
+	}
+
+	p.openTabsState.Exists(func(exists bool) {
+		if !exists {
+			p.tabsRestored = true
+			done(false)
+			return
+		}
+
+		p.openTabsState.Get(func(saved savedTabs) {
+			p.tabsRestored = true
+			done(p.addSavedTabs(saved))
+		})
+	})
+}
+
+func (p *ChatPage) addSavedTabs(saved savedTabs) bool {
+	// Something may have been opened in the meantime.
+	if p.tabView.NPages() > 0 {
+		return false
+	}
+
+	state := gtkcord.FromContext(p.ctx).Offline()
+
+	var selected *adw.TabPage
+	for i, t := range saved.Tabs {
+		if t.ChannelID.IsValid() {
+			if _, err := state.Channel(t.ChannelID); err != nil {
+				slog.Debug(
+					"not restoring tab of unknown channel",
+					"channel_id", t.ChannelID)
+				continue
+			}
+		}
+
+		tab := newChatTab(p.ctx)
+		tab.openLazily(t.ChannelID)
+
+		if orientation, ok := parseSplit(t.Split); ok {
+			tab.split(orientation)
+			if _, err := state.Channel(t.SplitChannelID); err == nil {
+				tab.openLazily(t.SplitChannelID)
+			}
+		}
+
+		page := p.addTab(tab, t.Pinned)
+
+		if i == saved.Selected || selected == nil {
+			selected = page
+		}
+	}
+
+	if selected == nil {
+		return false
+	}
+
+	p.tabView.SetSelectedPage(selected)
+	// The selected page may not have changed, so make sure that it is loaded.
+	p.onActiveTabChange(selected)
+	return true
+}
+
+// saveTabs saves the open tabs. Nothing is saved until the previous tabs are
+// restored.
+func (p *ChatPage) saveTabs() {
+	if !p.tabsRestored || p.detached {
+		return
+	}
+
+	selected := p.tabView.SelectedPage()
+
+	var saved savedTabs
+	for i := range p.tabView.NPages() {
+		page := p.tabView.NthPage(i)
+		tab := p.tabs[page.Native()]
+		if tab == nil {
+			continue
+		}
+
+		if selected != nil && page.Native() == selected.Native() {
+			saved.Selected = len(saved.Tabs)
+		}
+
+		t := savedTab{
+			ChannelID: tab.panes[0].channelID(),
+			Pinned:    page.Pinned(),
+		}
+		if orientation, ok := tab.splitOrientation(); ok {
+			t.Split = splitString(orientation)
+			t.SplitChannelID = tab.panes[1].channelID()
+		}
+
+		saved.Tabs = append(saved.Tabs, t)
+	}
+
+	p.openTabsState.Set(saved)
+}
+
+// movingTabs holds the tabs that are being moved between windows. A tab is
+// added once its page is detached from one tab view and taken once the page is
+// attached to another.
+var movingTabs = make(map[uintptr]*chatTab) // K: *adw.TabPage
+
+// moveToNewWindow moves the page into a new window.
+func (p *ChatPage) moveToNewWindow(page *adw.TabPage) {
+	tabView := p.createTabWindow()
+	if tabView == nil {
+		return
+	}
+
+	p.tabView.TransferPage(page, tabView, 0)
+	tabView.SetSelectedPage(page)
+}
+
+// createTabWindow creates a window for tabs to be moved into and returns its
+// tab view. It is also called when a tab is dragged out of the window.
+func (p *ChatPage) createTabWindow() *adw.TabView {
+	win, _ := ctxt.From[*Window](p.ctx)
+	if win == nil {
+		return nil
+	}
+
+	d := win.newDetachedWindow()
+	if d == nil {
+		return nil
+	}
+
+	d.Present()
+	return d.Chat.tabView
+}
+
+func (p *ChatPage) tabDetached(page *adw.TabPage) {
+	// Closed tabs are already removed.
+	tab := p.tabs[page.Native()]
+	if tab == nil {
+		return
+	}
+
+	delete(p.tabs, page.Native())
+	movingTabs[page.Native()] = tab
+	p.saveTabs()
+
+	// Don't leave an empty window behind.
+	if p.detached && p.tabView.NPages() == 0 {
+		win, _ := ctxt.From[*Window](p.ctx)
+		glib.IdleAdd(func() { win.Close() })
+	}
+}
+
+func (p *ChatPage) tabAttached(page *adw.TabPage) {
+	tab := movingTabs[page.Native()]
+	if tab == nil {
+		return
+	}
+
+	delete(movingTabs, page.Native())
+
+	// The message views are made again in this window, so that they belong
+	// to it.
+	tab.setContext(p.ctx)
+	p.registerTab(page, tab)
+	updateTabInfo(p.ctx, page, tab.channelID())
+
+	if selected := p.tabView.SelectedPage(); selected != nil && selected.Native() == page.Native() {
+		p.onActiveTabChange(page)
+	} else {
+		p.saveTabs()
+	}
+}
@@ -0,0 +1,169 @@
+package login
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/zalando/go-keyring"
+	"libdb.so/dissent/internal/gtkcord"
+)
+
+// Account is an account that the user chose to remember. The token is kept in
+// the secret driver and is never part of Account.
+type Account struct {
+	ID        discord.UserID `json:"id"`
+	Username  string         `json:"username"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	// Encrypted is true if the token is stored in the encrypted file instead
+	// of the keyring.
+	Encrypted bool      `json:"encrypted,omitempty"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// accountsKey is the state of all remembered accounts, keyed by user ID.
+var accountsKey = app.NewStateKey[Account]("accounts")
+
+// legacySecretKey is the secret key of the only account that older versions
+// remembered. It is moved to the account's own key on the next login.
+const legacySecretKey = "account"
+
+// secretKey returns the key of the account's token in the secret driver.
+func (a Account) secretKey() string {
+	return "account:" + a.ID.String()
+}
+
+// driver returns the secret driver that the account's token is stored in. If
+// the token is encrypted, then nil is returned, and the user must be asked for
+// the password.
+func (a Account) driver(ctx context.Context) secret.Driver {
+	if a.Encrypted {
+		return nil
+	}
+	return secret.KeyringDriver(ctx)
+}
+
+// Accounts returns all remembered accounts, most recently used first.
+func Accounts(ctx context.Context) []Account {
+	var accounts []Account
+	accountsKey.Acquire(ctx).Each(func(_ string, account Account) bool {
+		accounts = append(accounts, account)
+		return false
+	})
+
+	slices.SortFunc(accounts, func(a, b Account) int {
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+
+	return accounts
+}
+
+// LookupAccount returns the remembered account with the given ID.
+func LookupAccount(ctx context.Context, id discord.UserID) (Account, bool) {
+	for _, account := range Accounts(ctx) {
+		if account.ID == id {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// storeAccount stores the token of the given user in the secret driver. The
+// returned Account must be saved using saveAccount.
+func storeAccount(ctx context.Context, driver secret.Driver, me *discord.User, token string) (Account, error) {
+	account := Account{
+		ID:        me.ID,
+		Username:  me.Tag(),
+		AvatarURL: me.AvatarURL(),
+		LastUsed:  time.Now(),
+	}
+	_, account.Encrypted = driver.(*secret.EncryptedFile)
+
+	if err := driver.Set(account.secretKey(), []byte(token)); err != nil {
+		return Account{}, err
+	}
+
+	// The token has been moved, so the legacy copy is no longer needed.
+	if legacy, err := driver.Get(legacySecretKey); err == nil && string(legacy) == token {
+		if err := newSecretStores(ctx).delete(legacySecretKey); err != nil {
+			slog.Warn(
+				"cannot delete legacy account secret",
+				"err", err)
+		}
+	}
+
+	return account, nil
+}
+
+// saveAccount adds the account to the list of remembered accounts, replacing
+// the old one if any. It must be called on the main thread.
+func saveAccount(ctx context.Context, account Account) {
+	accountsKey.Acquire(ctx).Set(account.ID.String(), account)
+}
+
+// ForgetAccount removes the account from the list of remembered accounts,
+// clears its app state and deletes its token from both the keyring and the
+// encrypted file. It must be called on the main thread.
+func ForgetAccount(ctx context.Context, id discord.UserID) {
+	account := Account{ID: id}
+
+	accountsKey.Acquire(ctx).Delete(id.String())
+	gtkcord.ForgetAccountState(ctx, id)
+
+	stores := newSecretStores(ctx)
+	go func() {
+		if err := stores.delete(account.secretKey()); err != nil {
+			slog.Warn(
+				"cannot delete account secret",
+				"user_id", id,
+				"err", err)
+		}
+	}()
+}
+
+// secretStores locates the keys of both secret drivers. secret.Driver has no
+// way to delete keys, so this relies on how the drivers store them. Neither
+// needs the password to delete a key.
+type secretStores struct {
+	// keyringService is the keyring service that secret.Keyring uses.
+	keyringService string
+	// encryptedDir is the directory that secret.EncryptedFile writes to.
+	encryptedDir string
+}
+
+func newSecretStores(ctx context.Context) secretStores {
+	a := app.FromContext(ctx)
+	return secretStores{
+		keyringService: a.IDDot("secrets"),
+		encryptedDir:   a.ConfigPath("secrets"),
+	}
+}
+
+// delete deletes the key from both the keyring and the encrypted file. It
+// returns nil if the key doesn't exist in either.
+func (s secretStores) delete(key string) error {
+	var errs []error
+
+	err := keyring.Delete(s.keyringService, key)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnsupportedPlatform) {
+		errs = append(errs, fmt.Errorf("keyring: %w", err))
+	}
+
+	// Each key is a file named after the key in base64.
+	name := base64.RawStdEncoding.EncodeToString([]byte(key))
+	err = os.Remove(filepath.Join(s.encryptedDir, name))
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("encrypted file: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
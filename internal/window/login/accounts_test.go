@@ -0,0 +1,106 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/zalando/go-keyring"
+)
+
+func TestSecretStoresDelete(t *testing.T) {
+	keyring.MockInit()
+
+	// Both drivers that the login page may pick write the same layout, so
+	// delete must work after either of them.
+	drivers := []struct {
+		name string
+		new  func(ctx context.Context) *secret.EncryptedFile
+	}{
+		{
+			name: "salted",
+			new:  secret.SaltedFileDriver,
+		},
+		{
+			name: "passphrase",
+			new: func(ctx context.Context) *secret.EncryptedFile {
+				return secret.EncryptedFileDriver(ctx, "passphrase")
+			},
+		},
+	}
+
+	for _, driver := range drivers {
+		t.Run(driver.name, func(t *testing.T) {
+			stores := secretStores{
+				keyringService: "so.libdb.dissent.test.secrets",
+				encryptedDir:   t.TempDir(),
+			}
+
+			const key = "account:1"
+			const otherKey = "account:2"
+
+			ctx := secret.WithEncryptedFilePath(context.Background(), stores.encryptedDir)
+			encrypted := driver.new(ctx)
+
+			for _, k := range []string{key, otherKey} {
+				if err := keyring.Set(stores.keyringService, k, "token"); err != nil {
+					t.Fatal("cannot set keyring secret:", err)
+				}
+				if err := encrypted.Set(k, []byte("token")); err != nil {
+					t.Fatal("cannot set encrypted secret:", err)
+				}
+			}
+
+			filesBefore := readDirNames(t, stores.encryptedDir)
+
+			if err := stores.delete(key); err != nil {
+				t.Fatal("cannot delete secret:", err)
+			}
+
+			if _, err := keyring.Get(stores.keyringService, key); !errors.Is(err, keyring.ErrNotFound) {
+				t.Error("keyring secret not deleted, got error", err)
+			}
+			// A fresh driver doesn't have anything cached from before.
+			if _, err := driver.new(ctx).Get(key); !errors.Is(err, secret.ErrNotFound) {
+				t.Error("encrypted secret not deleted, got error", err)
+			}
+
+			if _, err := keyring.Get(stores.keyringService, otherKey); err != nil {
+				t.Error("other keyring secret was deleted:", err)
+			}
+			if _, err := driver.new(ctx).Get(otherKey); err != nil {
+				t.Error("other encrypted secret was deleted:", err)
+			}
+
+			// Only the file of the key may be removed. The salt and hash files
+			// must stay, or the other secrets can't be decrypted anymore.
+			filesAfter := readDirNames(t, stores.encryptedDir)
+			if len(filesAfter) != len(filesBefore)-1 {
+				t.Errorf("files before delete: %q, after: %q", filesBefore, filesAfter)
+			}
+
+			// Deleting again must not fail, since there is nothing left to
+			// delete.
+			if err := stores.delete(key); err != nil {
+				t.Error("cannot delete missing secret:", err)
+			}
+		})
+	}
+}
+
+func readDirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal("cannot read directory:", err)
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
@@ -5,14 +5,14 @@ import (
 	"strings"
 
 	"github.com/diamondburned/adaptive"
-	"github.com/diamondburned/arikawa/v3/session"
 	"github.com/diamondburned/chatkit/components/secretdialog"
 	"github.com/diamondburned/chatkit/kits/secret"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
-	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/passwordauth"
 	"libdb.so/dissent/internal/window/login/loading"
 )
 
@@ -23,13 +23,15 @@ type Component struct {
 
 	Loading  *loading.PulsatingBar
 	Methods  *Methods
+	MFA      *MFA // nil unless Discord asked for a second factor
 	Bottom   *gtk.Box
 	Remember *rememberMeBox
 	ErrorRev *gtk.Revealer
 	LogIn    *gtk.Button
 
-	ctx  context.Context
-	page *Page
+	ctx       context.Context
+	page      *Page
+	loginWith *gtk.Label
 }
 
 var componentCSS = cssutil.Applier("login-component", `
@@ -87,9 +89,9 @@ func NewComponent(ctx context.Context, p *Page) *Component {
 
 	c.Loading = loading.NewPulsatingBar(loading.PulseFast | loading.PulseBarOSD)
 
-	loginWith := gtk.NewLabel("Login using:")
-	loginWith.AddCSSClass("login-with")
-	loginWith.SetXAlign(0)
+	c.loginWith = gtk.NewLabel("Login using:")
+	c.loginWith.AddCSSClass("login-with")
+	c.loginWith.SetXAlign(0)
 
 	c.Methods = NewMethods(&c)
 
@@ -124,7 +126,7 @@ func NewComponent(ctx context.Context, p *Page) *Component {
 	})
 
 	c.Inner = gtk.NewBox(gtk.OrientationVertical, 0)
-	c.Inner.Append(loginWith)
+	c.Inner.Append(c.loginWith)
 	c.Inner.Append(c.Methods)
 	c.Inner.Append(c.Remember)
 	c.Inner.Append(c.ErrorRev)
@@ -160,16 +162,21 @@ func (c *Component) Login() {
 
 func (c *Component) login() {
 	switch {
+	case c.MFA != nil:
+		c.verifyMFA()
 	case c.Methods.IsEmail():
 		c.loginEmail(
 			c.Methods.Email.Email.Text(),
 			c.Methods.Email.Password.Text(),
-			c.Methods.Email.TOTP.Text(),
 		)
 	case c.Methods.IsToken():
 		c.loginToken(
 			c.Methods.Token.Token.Text(),
 		)
+	case c.Methods.IsQRCode():
+		// The handshake is already running while the tab is shown, so Log In
+		// just starts over with a new QR code.
+		c.Methods.QRCode.Start()
 	}
 }
 
@@ -183,11 +190,14 @@ func (c *Component) SetDone() {
 	c.Loading.Hide()
 }
 
-func (c *Component) loginEmail(email, password, totp string) {
+func (c *Component) loginEmail(email, password string) {
 	c.SetBusy()
+	c.HideError()
+
+	client := passwordauth.NewClient(gtkcord.NewAPIClient(""))
 
 	gtkutil.Async(c.ctx, func() func() {
-		u, err := session.Login(c.ctx, email, password, totp)
+		token, challenge, err := client.Login(c.ctx, email, password)
 		if err != nil {
 			return func() {
 				c.ShowError(errors.Wrap(err, "cannot login"))
@@ -195,34 +205,111 @@ func (c *Component) loginEmail(email, password, totp string) {
 			}
 		}
 
+		if challenge != nil {
+			return func() {
+				c.showMFA(client, challenge)
+				c.SetDone()
+			}
+		}
+
 		return func() {
-			c.loginToken(u.Token)
+			c.loginToken(token)
 			c.SetDone()
 		}
 	})
 }
 
-func (c *Component) loginToken(token string) {
-	go func() {
-		driver := c.Remember.SecretDriver()
-		if driver == nil {
-			return
+// showMFA replaces the login methods with the second factor prompt.
+func (c *Component) showMFA(client *passwordauth.Client, challenge *passwordauth.Challenge) {
+	c.closeMFA()
+
+	c.MFA = NewMFA(c.ctx, c, client, challenge)
+	c.Inner.InsertChildAfter(c.MFA, c.Methods)
+	c.Methods.Hide()
+	c.loginWith.Hide()
+	c.LogIn.SetLabel("Verify")
+	c.MFA.Focus()
+}
+
+// closeMFA goes back to the login methods, throwing away the ticket.
+func (c *Component) closeMFA() {
+	if c.MFA == nil {
+		return
+	}
+
+	c.Inner.Remove(c.MFA)
+	c.MFA = nil
+	c.Methods.Show()
+	c.loginWith.Show()
+	c.LogIn.SetLabel("Log In")
+	c.HideError()
+}
+
+func (c *Component) verifyMFA() {
+	mfa := c.MFA
+	method := mfa.Method()
+
+	code := strings.TrimSpace(mfa.Code())
+	if method == passwordauth.Backup {
+		code = strings.ReplaceAll(code, "-", "")
+	}
+
+	if code == "" {
+		c.ShowError(errors.New("enter a code first"))
+		mfa.Focus()
+		return
+	}
+
+	c.SetBusy()
+	c.HideError()
+
+	gtkutil.Async(c.ctx, func() func() {
+		token, err := mfa.client.Verify(c.ctx, method, mfa.challenge.Ticket, code)
+		if err != nil {
+			return func() {
+				c.SetDone()
+
+				var apiErr *passwordauth.Error
+				if errors.As(err, &apiErr) && apiErr.Status == 401 {
+					// The ticket has expired, so the user has to start over.
+					c.closeMFA()
+					c.ShowError(errors.New("the login attempt has expired, please log in again"))
+					return
+				}
+
+				c.ShowError(errors.Wrap(err, "cannot verify code"))
+				mfa.Focus()
+			}
 		}
 
-		if err := driver.Set("account", []byte(token)); err != nil {
-			app.Error(c.ctx, errors.Wrap(err, "cannot store account as secret"))
+		return func() {
+			c.loginToken(token)
+			c.closeMFA()
+			c.SetDone()
 		}
-	}()
+	})
+}
 
-	c.page.asyncUseToken(token)
+func (c *Component) loginToken(token string) {
+	c.page.asyncUseToken(token, c.Remember.SecretDriver(), "the login form")
 }
 
 func (c *Component) askDecrypt() {
+	// Decrypt the most recently used encrypted account, or the one stored by
+	// older versions if there is none.
+	key := legacySecretKey
+	for _, account := range Accounts(c.ctx) {
+		if account.Encrypted {
+			key = account.secretKey()
+			break
+		}
+	}
+
 	secretdialog.PromptPassword(
 		c.ctx, secretdialog.PromptDecrypt,
 		func(ok bool, enc *secret.EncryptedFile) {
 			if ok {
-				c.page.asyncLoadFromSecrets(enc)
+				c.page.asyncLoadFromSecrets(enc, key)
 			}
 		},
 	)
@@ -235,12 +322,12 @@ type Methods struct {
 		*gtk.Box
 		Email    *FormEntry
 		Password *FormEntry
-		TOTP     *FormEntry
 	}
 	Token struct { // Token
 		*gtk.Box
 		Token *FormEntry
 	}
+	QRCode *QRCode
 }
 
 var methodsCSS = cssutil.Applier("login-methods", `
@@ -261,12 +348,6 @@ var methodsCSS = cssutil.Applier("login-methods", `
 	.login-methods header tab:checked {
 		background-color: @accent_color;
 	}
-	.login-form-2fa {
-		margin-left: 6px;
-	}
-	.login-form-2fa entry {
-		font-family: monospace;
-	}
 `)
 
 // NewMethods creates a new Methods widget.
@@ -281,33 +362,14 @@ func NewMethods(c *Component) *Methods {
 	m.Email.Password = NewFormEntry("Password")
 	m.Email.Password.AddCSSClass("login-form-password")
 	m.Email.Password.SetHExpand(true)
-	m.Email.Password.FocusNextOnActivate()
+	m.Email.Password.ConnectActivate(c.Login)
 	m.Email.Password.Entry.SetInputPurpose(gtk.InputPurposePassword)
 	m.Email.Password.Entry.SetVisibility(false)
 
-	m.Email.TOTP = NewFormEntry("TOTP")
-	m.Email.TOTP.AddCSSClass("login-form-2fa")
-	m.Email.TOTP.ConnectActivate(c.Login)
-	m.Email.TOTP.Entry.SetInputPurpose(gtk.InputPurposePIN)
-	m.Email.TOTP.Entry.SetPlaceholderText("000000")
-	m.Email.TOTP.Entry.SetMaxLength(6)
-	m.Email.TOTP.Entry.SetWidthChars(6)
-
-	// Hack to collapse the TOTP entry.
-	if text, ok := m.Email.TOTP.Entry.FirstChild().(*gtk.Text); ok {
-		text.SetPropagateTextWidth(true)
-	}
-
-	//   [  0  |  1  |  2  |  3  ]
-	// 0 [ Email                 ]
-	// 1 [ Password        ][TOTP]
-	passwordBox := gtk.NewBox(gtk.OrientationHorizontal, 0)
-	passwordBox.Append(m.Email.Password)
-	passwordBox.Append(m.Email.TOTP)
-
+	// The second factor, if any, is asked for once the password is checked.
 	m.Email.Box = gtk.NewBox(gtk.OrientationVertical, 0)
 	m.Email.Append(m.Email.Email)
-	m.Email.Append(passwordBox)
+	m.Email.Append(m.Email.Password)
 
 	m.Token.Token = NewFormEntry("Token")
 	m.Token.Token.AddCSSClass("login-form-token")
@@ -319,10 +381,13 @@ func NewMethods(c *Component) *Methods {
 	m.Token.SetVAlign(gtk.AlignStart)
 	m.Token.Append(m.Token.Token)
 
+	m.QRCode = NewQRCode(c.ctx, c)
+
 	m.Notebook = gtk.NewNotebook()
 	m.Notebook.SetShowBorder(false)
 	m.Notebook.AppendPage(m.Token, gtk.NewLabel("Token"))
 	m.Notebook.AppendPage(m.Email, gtk.NewLabel("Email"))
+	m.Notebook.AppendPage(m.QRCode, gtk.NewLabel("QR Code"))
 	m.Notebook.SetCurrentPage(0)
 
 	if stack, ok := m.Notebook.LastChild().(*gtk.Stack); ok {
@@ -333,8 +398,9 @@ func NewMethods(c *Component) *Methods {
 	return &m
 }
 
-func (m *Methods) IsToken() bool { return m.CurrentPage() == 0 }
-func (m *Methods) IsEmail() bool { return m.CurrentPage() == 1 }
+func (m *Methods) IsToken() bool  { return m.CurrentPage() == 0 }
+func (m *Methods) IsEmail() bool  { return m.CurrentPage() == 1 }
+func (m *Methods) IsQRCode() bool { return m.CurrentPage() == 2 }
 
 // FormEntry is a widget containing a label and an entry.
 type FormEntry struct {
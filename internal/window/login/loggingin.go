@@ -2,11 +2,45 @@ package login
 
 import (
 	"context"
+	"strings"
+	"time"
 
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 )
 
+// ReconnectStatus describes the gateway while it is trying to reconnect.
+type ReconnectStatus struct {
+	// Attempt is the number of the current attempt, starting from 1. It is 0
+	// if the gateway isn't reconnecting.
+	Attempt int
+	// Code is the websocket close code of the last disconnection. It is -1 if
+	// there is none.
+	Code int
+	// Err is the last error.
+	Err error
+	// NextRetry is when the next attempt will be made. It is zero if the
+	// attempt is being made right now.
+	NextRetry time.Time
+}
+
+// Details returns a short line describing the attempt, such as "Attempt 2 ·
+// Close code 4000 · Retrying in 6s".
+func (s ReconnectStatus) Details(now time.Time) string {
+	parts := []string{locale.Sprintf("Attempt %d", s.Attempt)}
+	if s.Code > 0 {
+		parts = append(parts, locale.Sprintf("Close code %d", s.Code))
+	}
+	if wait := s.NextRetry.Sub(now); wait > 0 {
+		parts = append(parts, locale.Sprintf("Retrying in %ds", int(wait.Round(time.Second).Seconds())))
+	} else {
+		parts = append(parts, locale.Get("Retrying now"))
+	}
+	return strings.Join(parts, " · ")
+}
+
 // LoadingPage is the busy spinner screen that's shown while the application is
 // trying to log in.
 type LoadingPage struct {
@@ -19,6 +53,18 @@ type LoadingPage struct {
 		Spinner *gtk.Spinner
 		Text    *gtk.Label
 	}
+
+	// Status is only shown while reconnecting.
+	Status struct {
+		*gtk.Box
+		Details *gtk.Label
+		Error   *gtk.Label
+		Retry   *gtk.Button
+		Offline *gtk.Button
+	}
+
+	status ReconnectStatus
+	tick   glib.SourceHandle
 }
 
 var loggingInCSS = cssutil.Applier("login-loading", `
@@ -29,6 +75,15 @@ var loggingInCSS = cssutil.Applier("login-loading", `
 		margin-top:  8px;
 		font-size: 1.2em;
 	}
+	.login-loading-status {
+		margin-top: 8px;
+	}
+	.login-loading-error {
+		font-size: 0.9em;
+	}
+	.login-loading-buttons {
+		margin-top: 8px;
+	}
 `)
 
 // NewLoadingPage creates a new logging in loading screen.
@@ -40,11 +95,44 @@ func NewLoadingPage(ctx context.Context) *LoadingPage {
 	l.Content.Text = gtk.NewLabel("Connecting...")
 	l.Content.Text.AddCSSClass("login-loading-text")
 
+	l.Status.Details = gtk.NewLabel("")
+	l.Status.Details.AddCSSClass("login-loading-details")
+	l.Status.Details.AddCSSClass("dim-label")
+
+	l.Status.Error = gtk.NewLabel("")
+	l.Status.Error.AddCSSClass("login-loading-error")
+	l.Status.Error.AddCSSClass("dim-label")
+	l.Status.Error.SetWrap(true)
+	l.Status.Error.SetMaxWidthChars(60)
+	l.Status.Error.SetJustify(gtk.JustifyCenter)
+	l.Status.Error.SetSelectable(true)
+
+	l.Status.Retry = gtk.NewButtonWithLabel(locale.Get("Retry Now"))
+	l.Status.Retry.AddCSSClass("suggested-action")
+	l.Status.Retry.AddCSSClass("pill")
+
+	l.Status.Offline = gtk.NewButtonWithLabel(locale.Get("Work Offline"))
+	l.Status.Offline.AddCSSClass("pill")
+
+	buttons := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	buttons.AddCSSClass("login-loading-buttons")
+	buttons.SetHAlign(gtk.AlignCenter)
+	buttons.Append(l.Status.Retry)
+	buttons.Append(l.Status.Offline)
+
+	l.Status.Box = gtk.NewBox(gtk.OrientationVertical, 4)
+	l.Status.Box.AddCSSClass("login-loading-status")
+	l.Status.Box.Append(l.Status.Details)
+	l.Status.Box.Append(l.Status.Error)
+	l.Status.Box.Append(buttons)
+	l.Status.Box.Hide()
+
 	l.Content.Box = gtk.NewBox(gtk.OrientationVertical, 0)
 	l.Content.Box.SetVAlign(gtk.AlignCenter)
 	l.Content.Box.SetHAlign(gtk.AlignCenter)
 	l.Content.Box.Append(l.Content.Spinner)
 	l.Content.Box.Append(l.Content.Text)
+	l.Content.Box.Append(l.Status)
 
 	l.Content.WindowHandle = gtk.NewWindowHandle()
 	l.Content.WindowHandle.SetVExpand(true)
@@ -57,8 +145,14 @@ func NewLoadingPage(ctx context.Context) *LoadingPage {
 	l.Box.Append(l.Header)
 	l.Box.Append(l.Content)
 
-	l.ConnectMap(l.Content.Spinner.Start)
-	l.ConnectUnmap(l.Content.Spinner.Stop)
+	l.ConnectMap(func() {
+		l.Content.Spinner.Start()
+		l.updateStatus()
+	})
+	l.ConnectUnmap(func() {
+		l.Content.Spinner.Stop()
+		l.stopTick()
+	})
 	loggingInCSS(l)
 
 	return &l
@@ -68,3 +162,57 @@ func NewLoadingPage(ctx context.Context) *LoadingPage {
 func (l *LoadingPage) SetText(text string) {
 	l.Content.Text.SetText(text)
 }
+
+// SetStatus shows why and how the gateway is reconnecting. The time until the
+// next attempt is counted down while the page is shown.
+func (l *LoadingPage) SetStatus(status ReconnectStatus) {
+	l.status = status
+	l.SetText(locale.Get("Reconnecting..."))
+	l.Status.Show()
+	l.updateStatus()
+}
+
+// ClearStatus hides the reconnection status.
+func (l *LoadingPage) ClearStatus() {
+	l.status = ReconnectStatus{}
+	l.stopTick()
+	l.SetText("Connecting...")
+	l.Status.Hide()
+}
+
+func (l *LoadingPage) updateStatus() {
+	l.stopTick()
+	if l.status.Attempt == 0 {
+		return
+	}
+
+	l.Status.Details.SetText(l.status.Details(time.Now()))
+
+	if l.status.Err != nil {
+		l.Status.Error.SetText(l.status.Err.Error())
+		l.Status.Error.Show()
+	} else {
+		l.Status.Error.Hide()
+	}
+
+	if !l.Mapped() || !time.Now().Before(l.status.NextRetry) {
+		return
+	}
+
+	l.tick = glib.TimeoutSecondsAdd(1, func() bool {
+		now := time.Now()
+		l.Status.Details.SetText(l.status.Details(now))
+		if now.Before(l.status.NextRetry) {
+			return true
+		}
+		l.tick = 0
+		return false
+	})
+}
+
+func (l *LoadingPage) stopTick() {
+	if l.tick != 0 {
+		glib.SourceRemove(l.tick)
+		l.tick = 0
+	}
+}
@@ -2,11 +2,15 @@ package login
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 
-	"github.com/diamondburned/arikawa/v3/state"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/chatkit/components/secretdialog"
 	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/pkg/errors"
@@ -63,12 +67,46 @@ func NewPage(ctx context.Context, ctrl LoginController) *Page {
 	return &p
 }
 
-// LoadKeyring loads the session from the keyring.
+// LoadKeyring logs in using the most recently used account if its token is in
+// the keyring. Encrypted tokens are only loaded once the user asks to decrypt
+// them.
 func (p *Page) LoadKeyring() {
-	p.asyncLoadFromSecrets(secret.KeyringDriver(p.ctx))
+	accounts := Accounts(p.ctx)
+	if len(accounts) == 0 {
+		p.asyncLoadFromSecrets(secret.KeyringDriver(p.ctx), legacySecretKey)
+		return
+	}
+
+	if driver := accounts[0].driver(p.ctx); driver != nil {
+		p.asyncLoadFromSecrets(driver, accounts[0].secretKey())
+	}
+}
+
+// LoadAccount logs in using the remembered account with the given ID. The user
+// is asked for the password if the account's token is encrypted.
+func (p *Page) LoadAccount(id discord.UserID) {
+	account, ok := LookupAccount(p.ctx, id)
+	if !ok {
+		p.Login.ShowError(fmt.Errorf("account %v is not remembered", id))
+		return
+	}
+
+	if driver := account.driver(p.ctx); driver != nil {
+		p.asyncLoadFromSecrets(driver, account.secretKey())
+		return
+	}
+
+	secretdialog.PromptPassword(
+		p.ctx, secretdialog.PromptDecrypt,
+		func(ok bool, enc *secret.EncryptedFile) {
+			if ok {
+				p.asyncLoadFromSecrets(enc, account.secretKey())
+			}
+		},
+	)
 }
 
-func (p *Page) asyncLoadFromSecrets(driver secret.Driver) {
+func (p *Page) asyncLoadFromSecrets(driver secret.Driver, key string) {
 	p.Login.Loading.Show()
 	p.Login.SetSensitive(false)
 
@@ -78,37 +116,99 @@ func (p *Page) asyncLoadFromSecrets(driver secret.Driver) {
 	}
 
 	gtkutil.Async(p.ctx, func() func() {
-		b, err := driver.Get("account")
+		b, err := driver.Get(key)
 		if err != nil {
 			slog.Info(
 				"account not found in keyring",
+				"key", key,
 				"err", err)
 			return done
 		}
 
 		return func() {
 			done()
-			p.asyncUseToken(string(b))
+			// Store the token again, which updates the account's details and
+			// moves tokens stored under the legacy key.
+			p.asyncUseToken(string(b), driver, "the keyring")
 		}
 	})
 }
 
+// LoadReplay starts a session that replays the gateway events dumped in dir
+// instead of logging in. It is meant for debugging; see
+// gtkcord.ReplayDirFromEnv.
+func (p *Page) LoadReplay(dir string, realtime bool) {
+	slog.Warn(
+		"ATTENTION: replaying dumped events, no connection to Discord will be made",
+		"dir", dir)
+
+	state := gtkcord.NewReplayState()
+	p.ctrl.Hook(state)
+	p.ctrl.Ready(state)
+
+	go func() {
+		if err := state.ReplayEvents(p.ctx, dir, realtime); err != nil {
+			glib.IdleAdd(func() {
+				p.ctrl.PromptLogin()
+				p.Login.ShowError(errors.Wrap(err, "cannot replay events"))
+			})
+		}
+	}()
+}
+
 // asyncUseToken connects with the given token. If driver != nil, then the token
-// is stored.
-func (p *Page) asyncUseToken(token string) {
-	state := gtkcord.Wrap(state.New(token))
+// is stored and the account is remembered. source describes where the token
+// came from, which is mentioned if Discord rejects it.
+func (p *Page) asyncUseToken(token string, driver secret.Driver, source string) {
+	state := gtkcord.New(p.ctx, token)
 	p.ctrl.Hook(state)
 
 	gtkutil.Async(p.ctx, func() func() {
+		if err := state.RestoreCache(p.ctx); err != nil {
+			slog.Warn(
+				"cannot restore state from on-disk cache, ignoring",
+				"err", err)
+		}
+
 		if err := state.Open(p.ctx); err != nil {
+			if isTokenRejected(err) {
+				err = fmt.Errorf("Discord rejected the token from %s: %w", source, err)
+			} else {
+				err = errors.Wrap(err, "cannot open session")
+			}
 			return func() {
 				p.ctrl.PromptLogin()
-				p.Login.ShowError(errors.Wrap(err, "cannot open session"))
+				p.Login.ShowError(err)
 			}
 		}
 
+		var account Account
+		var accountErr error
+		if driver != nil {
+			me, err := state.Me()
+			if err == nil {
+				account, err = storeAccount(p.ctx, driver, me, token)
+			}
+			accountErr = err
+		}
+
 		return func() {
+			switch {
+			case accountErr != nil:
+				app.Error(p.ctx, errors.Wrap(accountErr, "cannot store account as secret"))
+			case account.ID.IsValid():
+				saveAccount(p.ctx, account)
+			}
+
 			p.ctrl.Ready(state)
 		}
 	})
 }
+
+// Reset clears the login forms, such as after logging out.
+func (p *Page) Reset() {
+	p.Login.closeMFA()
+	p.Login.HideError()
+	p.Login.Methods.Token.Token.Entry.SetText("")
+	p.Login.Methods.Email.Password.Entry.SetText("")
+}
@@ -0,0 +1,252 @@
+package login
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/passwordauth"
+)
+
+// MFA is shown in place of the login methods once Discord asks for a second
+// factor. It has a page for each method that the account can use.
+type MFA struct {
+	*gtk.Box
+	Title    *gtk.Label
+	Switcher *gtk.StackSwitcher
+	Stack    *gtk.Stack
+	TOTP     *FormEntry
+	SMS      struct {
+		*gtk.Box
+		Code   *FormEntry
+		Status *gtk.Label
+		Send   *gtk.Button
+	}
+	Backup *FormEntry
+	Back   *gtk.Button
+
+	ctx       context.Context
+	client    *passwordauth.Client
+	challenge *passwordauth.Challenge
+	cooldown  glib.SourceHandle
+}
+
+var mfaCSS = cssutil.Applier("login-mfa", `
+	.login-mfa-title {
+		font-weight: bold;
+	}
+	.login-mfa stackswitcher {
+		margin-top: 6px;
+	}
+	.login-mfa-sms-status {
+		margin-top: 4px;
+	}
+	.login-mfa-sms-send {
+		margin-top: 6px;
+	}
+	.login-mfa-back {
+		margin-top: 6px;
+	}
+	.login-mfa entry {
+		font-family: monospace;
+	}
+`)
+
+// smsCooldown is how long the user must wait before resending the SMS.
+const smsCooldown = 30 // seconds
+
+var mfaMethodTitles = map[passwordauth.Method]string{
+	passwordauth.TOTP:     "Authenticator",
+	passwordauth.SMS:      "SMS",
+	passwordauth.Backup:   "Backup Code",
+	passwordauth.WebAuthn: "Security Key",
+}
+
+// NewMFA creates a new MFA widget for the given challenge. Pressing Enter in
+// any of the entries presses the Log In button of c.
+func NewMFA(ctx context.Context, c *Component, client *passwordauth.Client, challenge *passwordauth.Challenge) *MFA {
+	m := MFA{
+		ctx:       ctx,
+		client:    client,
+		challenge: challenge,
+	}
+
+	m.Title = gtk.NewLabel("Two-Factor Authentication")
+	m.Title.AddCSSClass("login-mfa-title")
+	m.Title.SetXAlign(0)
+
+	m.Stack = gtk.NewStack()
+	m.Stack.SetTransitionType(gtk.StackTransitionTypeSlideLeftRight)
+	m.Stack.SetVhomogeneous(false)
+	m.Stack.SetInterpolateSize(true)
+
+	for _, method := range challenge.Methods {
+		var page gtk.Widgetter
+
+		switch method {
+		case passwordauth.TOTP:
+			m.TOTP = newMFACodeEntry("Code from your authenticator app", method, c.Login)
+			page = m.TOTP
+
+		case passwordauth.SMS:
+			m.SMS.Code = newMFACodeEntry("Code sent to your phone", method, c.Login)
+
+			m.SMS.Status = gtk.NewLabel("")
+			m.SMS.Status.AddCSSClass("login-mfa-sms-status")
+			m.SMS.Status.AddCSSClass("dim-label")
+			m.SMS.Status.SetXAlign(0)
+			m.SMS.Status.SetWrap(true)
+			m.SMS.Status.Hide()
+
+			m.SMS.Send = gtk.NewButtonWithLabel("Send Code")
+			m.SMS.Send.AddCSSClass("login-mfa-sms-send")
+			m.SMS.Send.SetHAlign(gtk.AlignStart)
+			m.SMS.Send.ConnectClicked(func() { m.sendSMS(c) })
+
+			m.SMS.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+			m.SMS.Box.Append(m.SMS.Code)
+			m.SMS.Box.Append(m.SMS.Status)
+			m.SMS.Box.Append(m.SMS.Send)
+			page = m.SMS.Box
+
+		case passwordauth.Backup:
+			m.Backup = newMFACodeEntry("One of your backup codes", method, c.Login)
+			page = m.Backup
+
+		case passwordauth.WebAuthn:
+			label := gtk.NewLabel("Security keys are not supported yet. Use another method instead.")
+			label.SetWrap(true)
+			label.SetXAlign(0)
+			page = label
+
+		default:
+			continue
+		}
+
+		m.Stack.AddTitled(page, string(method), mfaMethodTitles[method])
+	}
+
+	m.Switcher = gtk.NewStackSwitcher()
+	m.Switcher.SetStack(m.Stack)
+	// Don't bother showing a switcher with a single button.
+	m.Switcher.SetVisible(len(challenge.Methods) > 1)
+
+	m.Back = gtk.NewButtonWithLabel("Use a Different Account")
+	m.Back.AddCSSClass("login-mfa-back")
+	m.Back.AddCSSClass("flat")
+	m.Back.SetHAlign(gtk.AlignStart)
+	m.Back.ConnectClicked(c.closeMFA)
+
+	m.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	m.Box.Append(m.Title)
+	m.Box.Append(m.Switcher)
+	m.Box.Append(m.Stack)
+	m.Box.Append(m.Back)
+	m.ConnectUnmap(m.stopCooldown)
+	mfaCSS(m)
+
+	return &m
+}
+
+func newMFACodeEntry(label string, method passwordauth.Method, activate func()) *FormEntry {
+	e := NewFormEntry(label)
+	e.ConnectActivate(activate)
+	e.Entry.SetMaxLength(method.CodeLength() + 1) // allow a dash in backup codes
+	if method == passwordauth.Backup {
+		e.Entry.SetPlaceholderText("xxxx-xxxx")
+	} else {
+		e.Entry.SetInputPurpose(gtk.InputPurposePIN)
+		e.Entry.SetPlaceholderText("000000")
+	}
+	return e
+}
+
+// Method returns the method that the user chose.
+func (m *MFA) Method() passwordauth.Method {
+	return passwordauth.Method(m.Stack.VisibleChildName())
+}
+
+// Code returns the code that the user entered for the chosen method.
+func (m *MFA) Code() string {
+	var entry *FormEntry
+	switch m.Method() {
+	case passwordauth.TOTP:
+		entry = m.TOTP
+	case passwordauth.SMS:
+		entry = m.SMS.Code
+	case passwordauth.Backup:
+		entry = m.Backup
+	}
+	if entry == nil {
+		return ""
+	}
+	return entry.Text()
+}
+
+// Focus focuses the entry of the chosen method.
+func (m *MFA) Focus() {
+	switch m.Method() {
+	case passwordauth.TOTP:
+		m.TOTP.Entry.GrabFocus()
+	case passwordauth.SMS:
+		m.SMS.Code.Entry.GrabFocus()
+	case passwordauth.Backup:
+		m.Backup.Entry.GrabFocus()
+	}
+}
+
+func (m *MFA) sendSMS(c *Component) {
+	m.SMS.Send.SetSensitive(false)
+	c.HideError()
+
+	ticket := m.challenge.Ticket
+
+	gtkutil.Async(m.ctx, func() func() {
+		phone, err := m.client.SendSMS(m.ctx, ticket)
+		if err != nil {
+			return func() {
+				c.ShowError(errors.Wrap(err, "cannot send SMS"))
+				m.SMS.Send.SetSensitive(true)
+			}
+		}
+
+		return func() {
+			m.SMS.Status.SetText("Sent a code to " + phone + ".")
+			m.SMS.Status.Show()
+			m.SMS.Code.Entry.GrabFocus()
+			m.startCooldown()
+		}
+	})
+}
+
+// startCooldown counts down until the SMS can be resent.
+func (m *MFA) startCooldown() {
+	m.stopCooldown()
+
+	left := smsCooldown
+	m.SMS.Send.SetLabel(fmt.Sprintf("Resend Code (%d)", left))
+
+	m.cooldown = glib.TimeoutSecondsAdd(1, func() bool {
+		left--
+		if left > 0 {
+			m.SMS.Send.SetLabel(fmt.Sprintf("Resend Code (%d)", left))
+			return true
+		}
+
+		m.cooldown = 0
+		m.SMS.Send.SetLabel("Resend Code")
+		m.SMS.Send.SetSensitive(true)
+		return false
+	})
+}
+
+func (m *MFA) stopCooldown() {
+	if m.cooldown != 0 {
+		glib.SourceRemove(m.cooldown)
+		m.cooldown = 0
+	}
+}
@@ -0,0 +1,239 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/components/onlineimage"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/imgutil"
+	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/remoteauth"
+	"rsc.io/qr"
+)
+
+// QRCode is the login method that shows a QR code for the Discord mobile app
+// to scan. The handshake runs while the widget is mapped, which is when the QR
+// code tab is shown.
+type QRCode struct {
+	*gtk.Stack
+	Spinner *gtk.Spinner
+	Code    *gtk.Picture
+	Pending struct {
+		*gtk.Box
+		Avatar *onlineimage.Avatar
+		Name   *gtk.Label
+	}
+	Error struct {
+		*gtk.Box
+		Label *gtk.Label
+		Retry *gtk.Button
+	}
+
+	ctx    context.Context
+	c      *Component
+	cancel context.CancelFunc
+}
+
+var qrCodeCSS = cssutil.Applier("login-qrcode", `
+	.login-qrcode {
+		margin: 8px 0;
+	}
+	.login-qrcode-code {
+		background: white;
+		border-radius: 8px;
+	}
+	.login-qrcode-hint {
+		margin-top: 4px;
+	}
+	.login-qrcode-name {
+		font-weight: bold;
+		margin-top: 6px;
+	}
+`)
+
+const (
+	qrCodePixelsPerModule = 5
+	qrCodeAvatarSize      = 64
+)
+
+const qrCodeHint = "Scan this with the Discord mobile app to log in."
+
+// NewQRCode creates a new QRCode login method.
+func NewQRCode(ctx context.Context, c *Component) *QRCode {
+	q := QRCode{
+		ctx: ctx,
+		c:   c,
+	}
+
+	q.Spinner = gtk.NewSpinner()
+	q.Spinner.SetSizeRequest(32, 32)
+	q.Spinner.SetHAlign(gtk.AlignCenter)
+	q.Spinner.SetVAlign(gtk.AlignCenter)
+
+	q.Code = gtk.NewPicture()
+	q.Code.AddCSSClass("login-qrcode-code")
+	q.Code.SetCanShrink(false)
+	q.Code.SetHAlign(gtk.AlignCenter)
+
+	codeHint := gtk.NewLabel(qrCodeHint)
+	codeHint.AddCSSClass("login-qrcode-hint")
+	codeHint.AddCSSClass("dim-label")
+	codeHint.SetWrap(true)
+	codeHint.SetJustify(gtk.JustifyCenter)
+
+	codeBox := gtk.NewBox(gtk.OrientationVertical, 0)
+	codeBox.Append(q.Code)
+	codeBox.Append(codeHint)
+
+	q.Pending.Avatar = onlineimage.NewAvatar(ctx, imgutil.HTTPProvider, qrCodeAvatarSize)
+	q.Pending.Avatar.SetHAlign(gtk.AlignCenter)
+
+	q.Pending.Name = gtk.NewLabel("")
+	q.Pending.Name.AddCSSClass("login-qrcode-name")
+
+	pendingHint := gtk.NewLabel("Check your phone to confirm the login.")
+	pendingHint.AddCSSClass("login-qrcode-hint")
+	pendingHint.AddCSSClass("dim-label")
+	pendingHint.SetWrap(true)
+	pendingHint.SetJustify(gtk.JustifyCenter)
+
+	q.Pending.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	q.Pending.Box.SetVAlign(gtk.AlignCenter)
+	q.Pending.Box.Append(q.Pending.Avatar)
+	q.Pending.Box.Append(q.Pending.Name)
+	q.Pending.Box.Append(pendingHint)
+
+	q.Error.Label = gtk.NewLabel("")
+	q.Error.Label.AddCSSClass("error")
+	q.Error.Label.SetWrap(true)
+	q.Error.Label.SetJustify(gtk.JustifyCenter)
+
+	q.Error.Retry = gtk.NewButtonWithLabel("Try Again")
+	q.Error.Retry.SetHAlign(gtk.AlignCenter)
+	q.Error.Retry.ConnectClicked(q.Start)
+
+	q.Error.Box = gtk.NewBox(gtk.OrientationVertical, 6)
+	q.Error.Box.SetVAlign(gtk.AlignCenter)
+	q.Error.Box.Append(q.Error.Label)
+	q.Error.Box.Append(q.Error.Retry)
+
+	q.Stack = gtk.NewStack()
+	q.Stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	q.Stack.AddNamed(q.Spinner, "loading")
+	q.Stack.AddNamed(codeBox, "code")
+	q.Stack.AddNamed(q.Pending, "pending")
+	q.Stack.AddNamed(q.Error, "error")
+	q.Stack.SetVisibleChildName("loading")
+
+	// Only keep the handshake running while the QR code can be seen.
+	q.ConnectMap(q.Start)
+	q.ConnectUnmap(q.Stop)
+
+	qrCodeCSS(q)
+	return &q
+}
+
+// Start starts a new handshake, stopping the previous one if any.
+func (q *QRCode) Start() {
+	q.Stop()
+
+	ctx, cancel := context.WithCancel(q.ctx)
+	q.cancel = cancel
+
+	q.Spinner.Start()
+	q.Stack.SetVisibleChildName("loading")
+
+	opts := gtkcord.RemoteAuthOptions()
+	opts.OnQRCode = func(url string) {
+		glib.IdleAdd(func() {
+			if ctx.Err() == nil {
+				q.showCode(url)
+			}
+		})
+	}
+	opts.OnPendingUser = func(user remoteauth.PendingUser) {
+		glib.IdleAdd(func() {
+			if ctx.Err() == nil {
+				q.showPendingUser(user)
+			}
+		})
+	}
+
+	go func() {
+		token, err := remoteauth.Login(ctx, opts)
+		glib.IdleAdd(func() {
+			if ctx.Err() != nil {
+				return
+			}
+
+			cancel()
+			q.cancel = nil
+
+			if err != nil {
+				q.showError(err)
+				return
+			}
+
+			q.c.loginToken(token)
+		})
+	}()
+}
+
+// Stop stops the current handshake, if any.
+func (q *QRCode) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+		q.cancel = nil
+	}
+	q.Spinner.Stop()
+}
+
+func (q *QRCode) showCode(url string) {
+	code, err := qr.Encode(url, qr.M)
+	if err != nil {
+		q.showError(err)
+		return
+	}
+	code.Scale = qrCodePixelsPerModule
+
+	texture, err := gdk.NewTextureFromBytes(glib.NewBytesWithGo(code.PNG()))
+	if err != nil {
+		q.showError(err)
+		return
+	}
+
+	q.Code.SetPaintable(texture)
+	q.Code.SetSizeRequest(texture.Width(), texture.Height())
+	q.Stack.SetVisibleChildName("code")
+	q.Spinner.Stop()
+}
+
+func (q *QRCode) showPendingUser(user remoteauth.PendingUser) {
+	q.Pending.Avatar.SetFromURL(user.AvatarURL())
+	q.Pending.Name.SetText(user.Username)
+	q.Stack.SetVisibleChildName("pending")
+	q.Spinner.Stop()
+}
+
+func (q *QRCode) showError(err error) {
+	slog.Warn(
+		"QR code login failed",
+		"err", err)
+
+	switch {
+	case errors.Is(err, remoteauth.ErrTimeout):
+		q.Error.Label.SetText("The QR code expired.")
+	case errors.Is(err, remoteauth.ErrCancelled):
+		q.Error.Label.SetText("The login was cancelled on your phone.")
+	default:
+		q.Error.Label.SetText("Cannot log in using a QR code: " + err.Error())
+	}
+
+	q.Stack.SetVisibleChildName("error")
+	q.Spinner.Stop()
+}
@@ -0,0 +1,97 @@
+package login
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/diamondburned/chatkit/kits/secret"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+)
+
+// TokenFile is a file containing the token to log in with. It lets the
+// application be launched without going through the login page, such as on
+// kiosks and test machines.
+type TokenFile struct {
+	// Path is the path to the file. The token is the file's content with
+	// surrounding whitespace trimmed.
+	Path string
+	// Remember stores the token in the keyring and remembers the account. The
+	// token is never stored otherwise.
+	Remember bool
+}
+
+// TokenFileFromEnv returns the token file from the DISSENT_TOKEN_FILE
+// environment variable. If DISSENT_REMEMBER_TOKEN is set to 1, then the token is
+// remembered.
+func TokenFileFromEnv() TokenFile {
+	return TokenFile{
+		Path:     os.Getenv("DISSENT_TOKEN_FILE"),
+		Remember: os.Getenv("DISSENT_REMEMBER_TOKEN") == "1",
+	}
+}
+
+func (f TokenFile) read() (string, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot read token file")
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.Errorf("token file %s is empty", f.Path)
+	}
+
+	return token, nil
+}
+
+// LoadTokenFile logs in using the token in the given file.
+func (p *Page) LoadTokenFile(f TokenFile) {
+	p.Login.Loading.Show()
+	p.Login.SetSensitive(false)
+
+	done := func() {
+		p.Login.Loading.Hide()
+		p.Login.SetSensitive(true)
+	}
+
+	gtkutil.Async(p.ctx, func() func() {
+		token, err := f.read()
+		if err != nil {
+			return func() {
+				done()
+				p.ctrl.PromptLogin()
+				p.Login.ShowError(err)
+			}
+		}
+
+		return func() {
+			done()
+
+			var driver secret.Driver
+			if f.Remember {
+				driver = secret.KeyringDriver(p.ctx)
+			}
+
+			p.asyncUseToken(token, driver, "token file "+f.Path)
+		}
+	})
+}
+
+// isTokenRejected returns true if the error means that Discord doesn't accept
+// the token, either over the API or the gateway.
+func isTokenRejected(err error) bool {
+	var httpErr *httputil.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status == http.StatusUnauthorized
+	}
+
+	var closeErr *ws.CloseEvent
+	return errors.As(err, &closeErr) && closeErr.Code == closeAuthenticationFailed
+}
+
+// closeAuthenticationFailed is the gateway close code for an invalid token.
+const closeAuthenticationFailed = 4004
@@ -0,0 +1,163 @@
+package login
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diamondburned/arikawa/v3/utils/httputil"
+	"github.com/diamondburned/arikawa/v3/utils/ws"
+	"github.com/pkg/errors"
+)
+
+func TestTokenFileRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string // nil if the file doesn't exist
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "token",
+			content: ptr("token"),
+			want:    "token",
+		},
+		{
+			name:    "surrounding whitespace",
+			content: ptr("  \ttoken\r\n\n"),
+			want:    "token",
+		},
+		{
+			name:    "empty file",
+			content: ptr(""),
+			wantErr: true,
+		},
+		{
+			name:    "only whitespace",
+			content: ptr(" \n"),
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := TokenFile{Path: filepath.Join(t.TempDir(), "token")}
+			if test.content != nil {
+				if err := os.WriteFile(f.Path, []byte(*test.content), 0600); err != nil {
+					t.Fatal("cannot write token file:", err)
+				}
+			}
+
+			got, err := f.read()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("read() error = %v, want error: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("read() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenFileFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		remember string
+		want     TokenFile
+	}{
+		{
+			name: "unset",
+			want: TokenFile{},
+		},
+		{
+			name: "path",
+			path: "/run/secrets/token",
+			want: TokenFile{Path: "/run/secrets/token"},
+		},
+		{
+			name:     "remember",
+			path:     "/run/secrets/token",
+			remember: "1",
+			want:     TokenFile{Path: "/run/secrets/token", Remember: true},
+		},
+		{
+			name:     "remember only if 1",
+			path:     "/run/secrets/token",
+			remember: "true",
+			want:     TokenFile{Path: "/run/secrets/token"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("DISSENT_TOKEN_FILE", test.path)
+			t.Setenv("DISSENT_REMEMBER_TOKEN", test.remember)
+
+			if got := TokenFileFromEnv(); got != test.want {
+				t.Errorf("TokenFileFromEnv() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsTokenRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unauthorized",
+			err:  &httputil.HTTPError{Status: http.StatusUnauthorized},
+			want: true,
+		},
+		{
+			name: "wrapped unauthorized",
+			err:  errors.Wrap(&httputil.HTTPError{Status: http.StatusUnauthorized}, "cannot get gateway"),
+			want: true,
+		},
+		{
+			name: "other HTTP error",
+			err:  &httputil.HTTPError{Status: http.StatusInternalServerError},
+		},
+		{
+			name: "authentication failed",
+			err:  &ws.CloseEvent{Code: closeAuthenticationFailed},
+			want: true,
+		},
+		{
+			name: "wrapped authentication failed",
+			err:  fmt.Errorf("cannot open: %w", &ws.CloseEvent{Code: closeAuthenticationFailed}),
+			want: true,
+		},
+		{
+			name: "other close code",
+			err:  &ws.CloseEvent{Code: 4000},
+		},
+		{
+			name: "other error",
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "nil",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTokenRejected(test.err); got != test.want {
+				t.Errorf("isTokenRejected(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
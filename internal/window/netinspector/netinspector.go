@@ -0,0 +1,292 @@
+// Package netinspector implements a dialog that shows recent Discord API calls
+// and the state of the gateway connection. It is meant for diagnosing issues
+// such as messages failing to send.
+package netinspector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"libdb.so/dissent/internal/gtkcord"
+)
+
+// Dialog is the network inspector dialog.
+type Dialog struct {
+	*adw.Dialog
+	ctx context.Context
+
+	gateway struct {
+		status    *adw.ActionRow
+		session   *adw.ActionRow
+		sequence  *adw.ActionRow
+		latency   *adw.ActionRow
+		heartbeat *adw.ActionRow
+	}
+
+	requests *gtk.ListBox
+	serial   uint64
+}
+
+// ShowDialog shows a new network inspector dialog.
+func ShowDialog(ctx context.Context) {
+	d := NewDialog(ctx)
+	d.Present(app.GTKWindowFromContext(ctx))
+}
+
+var dialogCSS = cssutil.Applier("netinspector-dialog", `
+	.netinspector-request {
+		padding: 6px 12px;
+	}
+	.netinspector-request-status {
+		min-width: 3em;
+	}
+`)
+
+// NewDialog creates a new network inspector dialog.
+func NewDialog(ctx context.Context) *Dialog {
+	d := Dialog{ctx: ctx}
+
+	newRow := func(title string) *adw.ActionRow {
+		row := adw.NewActionRow()
+		row.SetTitle(locale.Get(title))
+		row.AddCSSClass("property")
+		return row
+	}
+
+	d.gateway.status = newRow("Status")
+	d.gateway.session = newRow("Session ID")
+	d.gateway.sequence = newRow("Sequence")
+	d.gateway.latency = newRow("Heartbeat Latency")
+	d.gateway.heartbeat = newRow("Last Heartbeat")
+
+	gatewayGroup := adw.NewPreferencesGroup()
+	gatewayGroup.SetTitle(locale.Get("Gateway"))
+	gatewayGroup.Add(d.gateway.status)
+	gatewayGroup.Add(d.gateway.session)
+	gatewayGroup.Add(d.gateway.sequence)
+	gatewayGroup.Add(d.gateway.latency)
+	gatewayGroup.Add(d.gateway.heartbeat)
+
+	d.requests = gtk.NewListBox()
+	d.requests.SetSelectionMode(gtk.SelectionNone)
+	d.requests.AddCSSClass("boxed-list")
+	d.requests.SetPlaceholder(gtk.NewLabel(locale.Get("No requests yet.")))
+
+	requestsGroup := adw.NewPreferencesGroup()
+	requestsGroup.SetTitle(locale.Get("Recent Requests"))
+	requestsGroup.SetDescription(locale.Get("Newest first. Tokens are redacted."))
+	requestsGroup.Add(d.requests)
+
+	page := adw.NewPreferencesPage()
+	page.Add(gatewayGroup)
+	page.Add(requestsGroup)
+
+	copyButton := gtk.NewButtonFromIconName("edit-copy-symbolic")
+	copyButton.SetTooltipText(locale.Get("Copy as Text"))
+	copyButton.ConnectClicked(func() {
+		clipboard := d.Clipboard()
+		clipboard.SetText(d.String())
+	})
+
+	header := adw.NewHeaderBar()
+	header.PackStart(copyButton)
+
+	toolbarView := adw.NewToolbarView()
+	toolbarView.AddTopBar(header)
+	toolbarView.SetContent(page)
+
+	d.Dialog = adw.NewDialog()
+	d.SetContentWidth(600)
+	d.SetContentHeight(650)
+	d.SetTitle(app.FromContext(ctx).SuffixedTitle(locale.Get("Network Inspector")))
+	d.SetChild(toolbarView)
+	dialogCSS(d)
+
+	d.update()
+
+	refresh := glib.TimeoutSecondsAdd(1, func() bool {
+		d.update()
+		return true
+	})
+	d.ConnectClosed(func() { glib.SourceRemove(refresh) })
+
+	return &d
+}
+
+func (d *Dialog) state() *gtkcord.State {
+	return gtkcord.FromContext(d.ctx)
+}
+
+func (d *Dialog) update() {
+	state := d.state()
+	if state == nil {
+		d.gateway.status.SetSubtitle(locale.Get("Not logged in"))
+		return
+	}
+
+	info := state.GatewayInfo()
+
+	status := locale.Get("Disconnected")
+	if info.Connected {
+		status = locale.Get("Connected")
+	}
+
+	d.gateway.status.SetSubtitle(status)
+	d.gateway.session.SetSubtitle(orNone(info.SessionID))
+	d.gateway.sequence.SetSubtitle(strconv.FormatInt(info.Sequence, 10))
+	d.gateway.latency.SetSubtitle(formatLatency(info.Latency))
+	d.gateway.heartbeat.SetSubtitle(formatTime(info.LastHeartbeat))
+
+	requests := state.Requests()
+	if serial := requests.Serial(); serial != d.serial {
+		d.serial = serial
+		d.updateRequests(requests.Records())
+	}
+}
+
+func (d *Dialog) updateRequests(records []gtkcord.RequestRecord) {
+	d.requests.RemoveAll()
+
+	for _, record := range slices.Backward(records) {
+		d.requests.Append(newRequestRow(record))
+	}
+}
+
+func newRequestRow(record gtkcord.RequestRecord) gtk.Widgetter {
+	status := gtk.NewLabel(statusText(record.Status))
+	status.AddCSSClass("netinspector-request-status")
+	status.AddCSSClass("monospace")
+	status.SetXAlign(0)
+	switch {
+	case record.Status == 0 || record.Status >= 500:
+		status.AddCSSClass("error")
+	case record.Status >= 400:
+		status.AddCSSClass("warning")
+	default:
+		status.AddCSSClass("success")
+	}
+
+	path := gtk.NewLabel(record.Method + " " + record.Path)
+	path.AddCSSClass("monospace")
+	path.SetXAlign(0)
+	path.SetHExpand(true)
+	path.SetEllipsize(pango.EllipsizeMiddle)
+	path.SetTooltipText(record.Method + " " + record.Path)
+
+	latency := gtk.NewLabel(formatLatency(record.Latency))
+	latency.AddCSSClass("numeric")
+
+	top := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	top.Append(status)
+	top.Append(path)
+	top.Append(latency)
+
+	details := gtk.NewLabel(requestDetails(record))
+	details.AddCSSClass("dim-label")
+	details.AddCSSClass("caption")
+	details.SetXAlign(0)
+	details.SetWrap(true)
+	details.SetWrapMode(pango.WrapWordChar)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.AddCSSClass("netinspector-request")
+	box.Append(top)
+	box.Append(details)
+
+	return box
+}
+
+func requestDetails(record gtkcord.RequestRecord) string {
+	details := []string{record.Time.Format(time.TimeOnly)}
+
+	rl := record.RateLimit
+	if rl.Bucket != "" {
+		details = append(details, locale.Sprintf("bucket %s", rl.Bucket))
+	}
+	if rl.Limit != "" {
+		details = append(details, locale.Sprintf("%s of %s left", rl.Remaining, rl.Limit))
+	}
+	if rl.ResetAfter != "" {
+		details = append(details, locale.Sprintf("resets in %ss", rl.ResetAfter))
+	}
+	if rl.Global {
+		details = append(details, locale.Get("global rate limit"))
+	}
+
+	return strings.Join(details, " · ")
+}
+
+// String formats everything in the dialog as plain text, which is suitable
+// for pasting into bug reports.
+func (d *Dialog) String() string {
+	state := d.state()
+	if state == nil {
+		return "Not logged in"
+	}
+
+	var b strings.Builder
+
+	info := state.GatewayInfo()
+	fmt.Fprintf(&b, "Gateway connected: %v\n", info.Connected)
+	fmt.Fprintf(&b, "Gateway session: %s\n", orNone(info.SessionID))
+	fmt.Fprintf(&b, "Gateway sequence: %d\n", info.Sequence)
+	fmt.Fprintf(&b, "Heartbeat latency: %s\n", formatLatency(info.Latency))
+	fmt.Fprintf(&b, "Last heartbeat: %s\n", formatTime(info.LastHeartbeat))
+	b.WriteString("\n")
+
+	for _, record := range state.Requests().Records() {
+		fmt.Fprintf(&b, "%s %s %s %s %s",
+			record.Time.Format(time.TimeOnly),
+			statusText(record.Status),
+			formatLatency(record.Latency),
+			record.Method, record.Path)
+
+		rl := record.RateLimit
+		if rl.Bucket != "" {
+			fmt.Fprintf(&b, " (bucket=%s remaining=%s/%s reset_after=%s global=%v)",
+				rl.Bucket, rl.Remaining, rl.Limit, rl.ResetAfter, rl.Global)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func statusText(status int) string {
+	if status == 0 {
+		return "ERR"
+	}
+	return strconv.Itoa(status)
+}
+
+func formatLatency(d time.Duration) string {
+	if d <= 0 {
+		return "—"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Format(time.TimeOnly)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
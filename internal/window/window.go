@@ -3,12 +3,15 @@ package window
 import (
 	"context"
 	"log/slog"
+	"sync"
 
 	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/app/prefs"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
@@ -46,14 +49,59 @@ type Window struct {
 	win *app.Window
 	ctx context.Context
 
+	baseCtx     context.Context // ctx without the state
+	state       *gtkcord.State
+	unhook      []func()
+	actionsOnce sync.Once
+	reconnect   reconnectState
+
+	// owner is the window that owns the state if this window was detached
+	// from it. The state is only closed by its owner.
+	owner           *Window
+	detached        []*Window
+	onWindowCreated []func(*Window)
+	onMention       []func(*gateway.MessageCreateEvent)
+
+	// pendingLink is opened once the chat is shown.
+	pendingLink *gtkcord.Link
+	// pendingActions are activated once the chat is shown.
+	pendingActions []func()
+
 	Stack   *gtk.Stack
 	Login   *login.Page
 	Loading *login.LoadingPage
 	Chat    *ChatPage
 }
 
-// NewWindow creates a new Window.
+// NewWindow creates a new Window. It logs in using the most recently used
+// account.
 func NewWindow(ctx context.Context) *Window {
+	w := newWindow(ctx)
+	if dir, realtime := gtkcord.ReplayDirFromEnv(); dir != "" {
+		w.Login.LoadReplay(dir, realtime)
+	} else {
+		w.Login.LoadKeyring()
+	}
+	return w
+}
+
+// NewTokenFileWindow creates a new Window that logs in using the token in the
+// given file.
+func NewTokenFileWindow(ctx context.Context, f login.TokenFile) *Window {
+	w := newWindow(ctx)
+	w.Login.LoadTokenFile(f)
+	return w
+}
+
+// NewAccountWindow creates a new Window that logs in using the remembered
+// account with the given ID.
+func NewAccountWindow(ctx context.Context, id discord.UserID) *Window {
+	w := newWindow(ctx)
+	w.Login.LoadAccount(id)
+	return w
+}
+
+func newWindow(ctx context.Context) *Window {
 	appInstance := app.FromContext(ctx)
 
 	win := adw.NewApplicationWindow(appInstance.Application)
@@ -69,11 +117,12 @@ func NewWindow(ctx context.Context) *Window {
 		ctx:               ctx,
 	}
 	w.ctx = ctxt.With(w.ctx, &w)
+	w.baseCtx = w.ctx
 
 	w.Login = login.NewPage(ctx, &loginWindow{Window: &w})
-	w.Login.LoadKeyring()
-
 	w.Loading = login.NewLoadingPage(ctx)
+	w.Loading.Status.Retry.ConnectClicked(w.RetryNow)
+	w.Loading.Status.Offline.ConnectClicked(w.WorkOffline)
 
 	w.Stack = gtk.NewStack()
 	w.Stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
@@ -82,6 +131,16 @@ func NewWindow(ctx context.Context) *Window {
 	w.Stack.SetVisibleChild(w.Login)
 	win.SetContent(w.Stack)
 
+	// Close the session along with the window, since other windows may keep
+	// the application running.
+	win.ConnectCloseRequest(func() bool {
+		w.closeSession(false)
+		return false
+	})
+	appInstance.ConnectShutdown(func() {
+		w.closeSession(false)
+	})
+
 	w.SwitchToLoginPage()
 	return &w
 }
@@ -92,7 +151,7 @@ func (w *Window) Context() context.Context {
 
 func (w *Window) initChatPage() {
 	w.Chat = NewChatPage(w.ctx, w)
-	w.Stack.AddChild(w.Chat)
+	w.Stack.AddNamed(w.Chat, "chat")
 }
 
 // It's not happy with how this requires a check for ChatPage, but it makes
@@ -108,9 +167,31 @@ func (w *Window) initActions() {
 		"open-dms":       func() { w.useChatPage((*ChatPage).OpenDMs) },
 		"reset-view":     func() { w.useChatPage((*ChatPage).ResetView) },
 		"quick-switcher": func() { w.useChatPage((*ChatPage).OpenQuickSwitcher) },
+		"add-account":    func() { w.sessionWindow().AddAccount() },
+		"log-out":        func() { w.sessionWindow().AskLogOut() },
+		"split-left-right": func() {
+			w.useChatPage(func(p *ChatPage) { p.SplitView(gtk.OrientationHorizontal) })
+		},
+		"split-top-bottom": func() {
+			w.useChatPage(func(p *ChatPage) { p.SplitView(gtk.OrientationVertical) })
+		},
+		"close-split": func() { w.useChatPage((*ChatPage).CloseSplit) },
+		"go-back":     func() { w.useChatPage((*ChatPage).GoBack) },
+		"go-forward":  func() { w.useChatPage((*ChatPage).GoForward) },
 	})
 
 	gtkutil.AddActionCallbacks(w, map[string]gtkutil.ActionCallback{
+		"switch-account": {
+			ArgType: gtkcord.SnowflakeVariant,
+			Func: func(variant *glib.Variant) {
+				id := discord.UserID(variant.Int64())
+				if accountWindows.Value() {
+					w.ActivateAction("app.open-account", variant)
+				} else {
+					w.sessionWindow().SwitchAccount(id)
+				}
+			},
+		},
 		"open-channel": {
 			ArgType: gtkcord.SnowflakeVariant,
 			Func: func(variant *glib.Variant) {
@@ -118,7 +199,18 @@ func (w *Window) initActions() {
 				slog.Debug(
 					"opening channel from window-scoped action",
 					"channel_id", id)
-				w.useChatPage(func(p *ChatPage) { p.OpenChannel(id) })
+				w.OpenLink(gtkcord.Link{ChannelID: id})
+			},
+		},
+		"open-message": {
+			ArgType: gtkcord.MessageVariant,
+			Func: func(variant *glib.Variant) {
+				chID, msgID := gtkcord.MessageFromVariant(variant)
+				slog.Debug(
+					"opening message from window-scoped action",
+					"channel_id", chID,
+					"message_id", msgID)
+				w.OpenLink(gtkcord.Link{ChannelID: chID, MessageID: msgID})
 			},
 		},
 		"open-guild": {
@@ -132,10 +224,6 @@ func (w *Window) initActions() {
 			},
 		},
 	})
-
-	gtkutil.AddActionShortcuts(w, map[string]string{
-		"<Ctrl>K": "win.quick-switcher",
-	})
 }
 
 func (w *Window) SwitchToChatPage() {
@@ -196,3 +284,53 @@ func newEmptyHeader() *gtk.Box {
 	emptyHeaderCSS(b)
 	return b
 }
+
+// ActivateWhenReady activates the window action with the given name. If the
+// window isn't logged in yet, then the action is activated once it is, since
+// the actions are only added by then.
+func (w *Window) ActivateWhenReady(name string, args *glib.Variant) {
+	if w.Chat != nil {
+		w.ActivateAction(name, args)
+		return
+	}
+
+	w.pendingActions = append(w.pendingActions, func() {
+		w.ActivateAction(name, args)
+	})
+}
+
+// OpenLink opens the channel or message that the link points to. If the window
+// isn't logged in yet, then the link is opened once it is.
+func (w *Window) OpenLink(link gtkcord.Link) {
+	if w.Chat == nil {
+		w.pendingLink = &link
+		return
+	}
+
+	state := gtkcord.FromContext(w.ctx)
+	if _, err := state.Cabinet.Channel(link.ChannelID); err != nil {
+		slog.Warn(
+			"cannot open link to unknown channel",
+			"channel_id", link.ChannelID,
+			"err", err)
+		w.showUnknownChannel()
+		return
+	}
+
+	if link.MessageID.IsValid() {
+		w.Chat.OpenMessage(link.ChannelID, link.MessageID)
+	} else {
+		w.Chat.OpenChannel(link.ChannelID)
+	}
+}
+
+func (w *Window) showUnknownChannel() {
+	dialog := adw.NewAlertDialog(
+		locale.Get("Channel Unavailable"),
+		locale.Get("This link goes to a channel that you can't see. "+
+			"You may not be in its server, or you may not have permission to view it."))
+	dialog.AddResponse("close", locale.Get("_Close"))
+	dialog.SetDefaultResponse("close")
+	dialog.SetCloseResponse("close")
+	dialog.Present(w)
+}
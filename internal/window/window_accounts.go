@@ -0,0 +1,225 @@
+package window
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/locale"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/window/login"
+)
+
+var accountWindows = prefs.NewBool(false, prefs.PropMeta{
+	Name:    "Open Accounts in New Windows",
+	Section: "Discord",
+	Description: "Open each account in its own window when switching accounts " +
+		"instead of replacing the current one.",
+})
+
+// AccountID returns the ID of the account that the window is logged in as. It
+// returns 0 if the window is not logged in.
+func (w *Window) AccountID() discord.UserID {
+	if w.state == nil {
+		return 0
+	}
+	me, _ := w.state.Cabinet.Me()
+	if me == nil {
+		return 0
+	}
+	return me.ID
+}
+
+// SwitchAccount logs out of the current account and logs in using the
+// remembered account with the given ID. The current account stays remembered.
+func (w *Window) SwitchAccount(id discord.UserID) {
+	if id == w.AccountID() {
+		return
+	}
+
+	slog.Info(
+		"switching account",
+		"user_id", id)
+
+	w.closeSession(true)
+	w.SwitchToLoginPage()
+	w.Login.LoadAccount(id)
+}
+
+// AddAccount logs out of the current account and shows the login page, so that
+// the user can log in to another account. The current account stays
+// remembered.
+func (w *Window) AddAccount() {
+	w.closeSession(true)
+	w.SwitchToLoginPage()
+}
+
+// AskLogOut asks the user whether to log out of the current account, and
+// whether to also log out of every other device.
+func (w *Window) AskLogOut() {
+	if w.state == nil {
+		return
+	}
+
+	dialog := adw.NewAlertDialog(
+		locale.Get("Log Out?"),
+		locale.Get("Dissent will forget this account, including its token, "+
+			"last opened channels and drafts. "+
+			"Logging out everywhere also logs out every other device that uses this account."))
+	dialog.AddResponse("cancel", locale.Get("_Cancel"))
+	dialog.AddResponse("everywhere", locale.Get("Log Out _Everywhere"))
+	dialog.AddResponse("log-out", locale.Get("_Log Out"))
+	dialog.SetResponseAppearance("everywhere", adw.ResponseDestructive)
+	dialog.SetResponseAppearance("log-out", adw.ResponseDestructive)
+	dialog.SetDefaultResponse("cancel")
+	dialog.SetCloseResponse("cancel")
+	dialog.Choose(w.ctx, w, func(res gio.AsyncResulter) {
+		switch dialog.ChooseFinish(res) {
+		case "log-out":
+			w.LogOut(false)
+		case "everywhere":
+			w.LogOut(true)
+		}
+	})
+}
+
+// LogOut logs out of the current account and shows the login page. The
+// account is forgotten: its token, app state and on-disk cache are deleted. If
+// everywhere is true, then the token and all other sessions of the account are
+// also revoked by Discord.
+func (w *Window) LogOut(everywhere bool) {
+	id := w.AccountID()
+	state := w.detachSession()
+	if state == nil {
+		return
+	}
+
+	slog.Info(
+		"logging out",
+		"user_id", id,
+		"everywhere", everywhere)
+
+	if id.IsValid() {
+		login.ForgetAccount(w.baseCtx, id)
+	}
+
+	w.Login.Reset()
+	w.SwitchToLoginPage()
+
+	ctx := w.baseCtx
+	go func() {
+		if everywhere {
+			// Don't let closing the window cancel this.
+			err := state.LogOutEverywhere(context.WithoutCancel(ctx))
+			if err != nil {
+				app.Error(ctx, errors.Wrap(err, "cannot log out everywhere"))
+			}
+		}
+
+		if err := state.DeleteCache(ctx); err != nil {
+			slog.Warn(
+				"cannot delete on-disk cache",
+				"err", err)
+		}
+
+		if err := state.Close(); err != nil {
+			slog.Error("error closing session", "err", err)
+		}
+	}()
+}
+
+// closeSession unhooks the current state from the window, removes the chat
+// page and closes the state. If async is true, the state is closed in the
+// background.
+func (w *Window) closeSession(async bool) {
+	state := w.detachSession()
+	if state == nil {
+		return
+	}
+
+	if w.owner != nil {
+		// The state is still used by its owner.
+		return
+	}
+
+	if async {
+		go closeState(state)
+	} else {
+		closeState(state)
+	}
+}
+
+// detachSession unhooks the current state from the window and removes the
+// chat page. Windows that were detached from this one are closed. The state is
+// returned for the caller to close.
+func (w *Window) detachSession() *gtkcord.State {
+	state := w.state
+	if state == nil {
+		return nil
+	}
+
+	w.closeDetached()
+
+	for _, unhook := range w.unhook {
+		unhook()
+	}
+
+	w.unhook = nil
+	w.state = nil
+	w.ctx = w.baseCtx
+
+	if w.Chat != nil {
+		w.Stack.Remove(w.Chat)
+		w.Chat = nil
+	}
+
+	return state
+}
+
+func closeState(state *gtkcord.State) {
+	if err := state.CloseCache(); err != nil {
+		slog.Warn("cannot save state to on-disk cache", "err", err)
+	}
+
+	slog.Info("Closing Discord session...")
+
+	if err := state.Close(); err != nil {
+		slog.Error("error closing session", "err", err)
+	}
+}
+
+// accountMenuItems returns the menu items for switching between remembered
+// accounts. The current account is shown but disabled.
+func accountMenuItems(ctx context.Context) []gtkutil.PopoverMenuItem {
+	me, _ := gtkcord.FromContext(ctx).Cabinet.Me()
+	accounts := login.Accounts(ctx)
+
+	items := make([]gtkutil.PopoverMenuItem, 0, len(accounts)+2)
+	for _, account := range accounts {
+		// Escape underscores so that they aren't taken as mnemonics.
+		name := strings.ReplaceAll(account.Username, "_", "__")
+		action := fmt.Sprintf("win.switch-account(int64 %d)", account.ID)
+
+		if me != nil && account.ID == me.ID {
+			name = locale.Sprintf("%s (Current)", name)
+			action = ""
+		}
+
+		items = append(items, gtkutil.MenuItem(locale.Localized(name), action))
+	}
+
+	items = append(items,
+		gtkutil.MenuSeparator(""),
+		gtkutil.MenuItem("A_dd Account", "win.add-account"),
+	)
+
+	return items
+}
@@ -0,0 +1,63 @@
+package window
+
+import (
+	"slices"
+
+	"libdb.so/dissent/internal/gtkcord"
+)
+
+// ConnectWindowCreated calls f for every window that is created from this one,
+// such as when a tab is moved into a new window. Windows created from those
+// windows are also passed to f.
+func (w *Window) ConnectWindowCreated(f func(*Window)) {
+	w.onWindowCreated = append(w.onWindowCreated, f)
+}
+
+// newDetachedWindow creates a window that shares the state of w, which is
+// used to show tabs that are moved out of w. The window is closed along with
+// the session of w, since it doesn't own the state.
+func (w *Window) newDetachedWindow() *Window {
+	owner := w.sessionWindow()
+	if owner.state == nil {
+		return nil
+	}
+
+	d := newWindow(owner.baseCtx)
+	d.owner = owner
+	d.ctx = gtkcord.InjectState(d.baseCtx, owner.state)
+	d.state = owner.state
+
+	d.actionsOnce.Do(d.initActions)
+	d.initChatPage()
+	d.Chat.detached = true
+	d.Stack.SetVisibleChild(d.Chat)
+	d.SetTitle("")
+
+	owner.detached = append(owner.detached, d)
+	d.ConnectDestroy(func() {
+		owner.detached = slices.DeleteFunc(owner.detached, func(o *Window) bool { return o == d })
+	})
+
+	for _, f := range owner.onWindowCreated {
+		f(d)
+	}
+
+	return d
+}
+
+// sessionWindow returns the window that owns the state, which is w itself
+// unless w was detached from another window.
+func (w *Window) sessionWindow() *Window {
+	if w.owner != nil {
+		return w.owner
+	}
+	return w
+}
+
+// closeDetached closes the windows that were detached from w.
+func (w *Window) closeDetached() {
+	for _, d := range slices.Clone(w.detached) {
+		d.Close()
+	}
+	w.detached = nil
+}
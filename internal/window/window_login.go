@@ -1,141 +1,367 @@
 package window
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"sync"
+	"time"
 
 	"github.com/diamondburned/arikawa/v3/discord"
 	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/arikawa/v3/utils/ws"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app/locale"
 	"github.com/diamondburned/gotkit/app/notify"
+	"github.com/diamondburned/gotkit/app/prefs"
 	"github.com/diamondburned/ningen/v3"
+	"github.com/pkg/errors"
 	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/window/login"
 )
 
+var offlineMode = prefs.NewBool(false, prefs.PropMeta{
+	Name:    "Stay in offline mode while reconnecting",
+	Section: "Discord",
+	Description: "Keep showing channels that were already loaded while the " +
+		"connection is lost, instead of switching to the loading screen after " +
+		"a few seconds.",
+})
+
 type loginWindow struct {
 	*Window
-	readyOnce sync.Once
 }
 
 func (w *loginWindow) Hook(state *gtkcord.State) {
-	w.ctx = gtkcord.InjectState(w.ctx, state)
+	// Drop the previous state, which is left over if it failed to open.
+	w.closeSession(true)
+
+	w.ctx = gtkcord.InjectState(w.baseCtx, state)
+	w.state = state
 
+	w.reconnect = reconnectState{}
+	w.Loading.ClearStatus()
 	w.Reconnecting()
-	var reconnecting glib.SourceHandle
-
-	// When the websocket closes, the screen must be changed to a busy one. The
-	// websocket may close if it's disconnected unexpectedly.
-	state.BindWidget(w, func(ev gateway.Event) {
-		switch ev := ev.(type) {
-		case *ningen.ConnectedEvent:
-			slog.Info(
-				"Discord gateway connected",
-				"event", ev.EventType())
-
-			// Cancel the 3s delay if we're already connected during that.
-			if reconnecting != 0 {
-				glib.SourceRemove(reconnecting)
-				reconnecting = 0
-			}
 
-			w.Connected()
+	w.unhook = append(w.unhook, w.reconnect.stop)
 
-		case *ws.BackgroundErrorEvent:
-			slog.Warn(
-				"Discord gateway background error",
-				"err", ev.Err)
-
-		case *ws.CloseEvent:
-			slog.Info(
-				"Discord gateway closed",
-				"err", ev.Err,
-				"code", ev.Code)
-
-		case *ningen.DisconnectedEvent:
-			slog.Info(
-				"Discord gateway disconnected",
-				"err", ev.Err,
-				"code", ev.Code)
-
-			if ev.IsLoggedOut() {
-				w.PromptLogin()
-				return
-			}
+	// Move the state from before it was kept for each account. This is done
+	// before the chat page is created, since it reads the state.
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *gateway.ReadyEvent) {
+		gtkcord.MigrateAccountState(w.baseCtx, ev.User.ID)
+	}))
 
-			// Add a 3s delay in case we have a sudden disruption that
-			// immediately recovers.
-			reconnecting = glib.TimeoutSecondsAdd(3, func() {
-				w.Reconnecting()
-				reconnecting = 0
-			})
+	// When the websocket closes, the user is told about it. The websocket may
+	// close if it's disconnected unexpectedly.
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *ningen.ConnectedEvent) {
+		slog.Info(
+			"Discord gateway connected",
+			"event", ev.EventType())
 
-		case *gateway.ReadyEvent:
-			if ev.UserSettings != nil {
-				switch ev.UserSettings.Theme {
-				case "dark":
-					SetPreferDarkTheme(true)
-				case "light":
-					SetPreferDarkTheme(false)
-				}
-			}
+		w.Connected()
+	}))
 
-		case *gateway.MessageCreateEvent:
-			mentions := state.MessageMentions(&ev.Message)
-			if mentions == 0 {
-				return
-			}
+	// Show the cached state right away. It is replaced once the gateway
+	// connects.
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *gtkcord.CacheRestoredEvent) {
+		slog.Info(
+			"showing state restored from on-disk cache",
+			"saved_at", ev.SavedAt)
 
-			if state.Status() == discord.DoNotDisturbStatus {
-				return
+		w.Connected()
+	}))
+
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *ws.BackgroundErrorEvent) {
+		slog.Warn(
+			"Discord gateway background error",
+			"err", ev.Err)
+
+		// The gateway sends a ConnectionError for every failed attempt before
+		// it waits to try again.
+		var connErr ws.ConnectionError
+		if errors.As(ev.Err, &connErr) && !errors.Is(connErr.Err, context.Canceled) {
+			w.reconnectFailed(connErr.Err)
+		}
+	}))
+
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *ws.CloseEvent) {
+		slog.Info(
+			"Discord gateway closed",
+			"err", ev.Err,
+			"code", ev.Code)
+	}))
+
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *ningen.DisconnectedEvent) {
+		slog.Info(
+			"Discord gateway disconnected",
+			"err", ev.Err,
+			"code", ev.Code)
+
+		if ev.IsLoggedOut() {
+			w.PromptLogin()
+			return
+		}
+
+		w.disconnected(ev.Code, ev.Err)
+	}))
+
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *gateway.ReadyEvent) {
+		if ev.UserSettings != nil {
+			switch ev.UserSettings.Theme {
+			case "dark":
+				SetPreferDarkTheme(true)
+			case "light":
+				SetPreferDarkTheme(false)
 			}
+		}
+	}))
 
-			avatarURL := gtkcord.InjectAvatarSize(ev.Author.AvatarURL())
-
-			notify.Send(w.ctx, notify.Notification{
-				ID: notify.HashID(ev.ChannelID),
-				Title: fmt.Sprintf(
-					"%s (%s)",
-					state.AuthorDisplayName(ev),
-					gtkcord.ChannelNameFromID(w.ctx, ev.ChannelID),
-				),
-				Body:  state.MessagePreview(&ev.Message),
-				Icon:  notify.IconURL(w.ctx, avatarURL, notify.IconName("avatar-default-symbolic")),
-				Sound: notify.MessageSound,
-				Action: notify.Action{
-					ActionID: "app.open-channel",
-					Argument: gtkcord.NewChannelIDVariant(ev.ChannelID),
-				},
-			})
+	w.unhook = append(w.unhook, gtkcord.On(state, w, func(ev *gateway.MessageCreateEvent) {
+		mentions := state.MessageMentions(&ev.Message)
+		if mentions == 0 {
+			return
 		}
-	})
-}
 
-func (w *loginWindow) Ready(state *gtkcord.State) {
-	app := w.Application()
-	app.ConnectShutdown(func() {
-		slog.Info("Closing Discord session...")
+		for _, f := range w.onMention {
+			f(ev)
+		}
 
-		if err := state.Close(); err != nil {
-			slog.Error("error closing session", "err", err)
+		if state.Status() == discord.DoNotDisturbStatus {
+			return
 		}
-	})
+
+		avatarURL := gtkcord.InjectAvatarSize(ev.Author.AvatarURL())
+
+		notify.Send(w.ctx, notify.Notification{
+			ID: notify.HashID(ev.ChannelID),
+			Title: fmt.Sprintf(
+				"%s (%s)",
+				state.AuthorDisplayName(ev),
+				gtkcord.ChannelNameFromID(w.ctx, ev.ChannelID),
+			),
+			Body:  state.MessagePreview(&ev.Message),
+			Icon:  notify.IconURL(w.ctx, avatarURL, notify.IconName("avatar-default-symbolic")),
+			Sound: notify.MessageSound,
+			// The action goes through the application, so it must say which
+			// account's window should open the channel.
+			Action: notify.Action{
+				ActionID: "app.open-account-channel",
+				Argument: gtkcord.NewAccountChannelVariant(w.AccountID(), ev.ChannelID),
+			},
+		})
+	}))
+}
+
+// ConnectMention calls f for every new message that mentions the user. Windows
+// that were detached from w share its state, so they never call f themselves.
+func (w *Window) ConnectMention(f func(*gateway.MessageCreateEvent)) {
+	w.onMention = append(w.onMention, f)
 }
 
+// Ready does nothing. The state is closed by the window when it is closed or
+// when the user switches accounts.
+func (w *loginWindow) Ready(state *gtkcord.State) {}
+
 func (w *loginWindow) Reconnecting() {
 	w.Stack.SetVisibleChild(w.Loading)
 	w.SetTitle("Connecting")
 }
 
 func (w *loginWindow) Connected() {
-	w.readyOnce.Do(func() {
+	w.actionsOnce.Do(w.initActions)
+	w.reconnect.stop()
+	w.reconnect = reconnectState{}
+	w.Loading.ClearStatus()
+
+	if w.Chat == nil {
 		w.initChatPage()
-		w.initActions()
-	})
-	w.Window.SwitchToChatPage()
+	}
+
+	// Don't reset the view if the chat was kept on screen while the gateway
+	// was reconnecting.
+	w.Chat.HideConnectionBanner()
+	if !w.chatVisible() {
+		w.Window.SwitchToChatPage()
+	}
+
+	if link := w.pendingLink; link != nil {
+		w.pendingLink = nil
+		w.OpenLink(*link)
+	}
+
+	pending := w.pendingActions
+	w.pendingActions = nil
+	for _, activate := range pending {
+		activate()
+	}
 }
 
 func (w *loginWindow) PromptLogin() {
 	w.Window.SwitchToLoginPage()
 }
+
+// reconnectGracePeriod is how long the chat is kept on screen after the
+// gateway disconnects. Most disconnections recover well within this.
+const reconnectGracePeriod = 15 // seconds
+
+// reconnectState keeps track of the gateway while it is reconnecting.
+type reconnectState struct {
+	status login.ReconnectStatus
+	// grace switches to the loading page once the grace period is over.
+	grace glib.SourceHandle
+	// retry retries on its own after a manual retry fails, since the gateway
+	// won't retry after that.
+	retry    glib.SourceHandle
+	retrying bool
+	// offline is true if the user chose to keep using the chat.
+	offline bool
+}
+
+func (r *reconnectState) stop() {
+	if r.grace != 0 {
+		glib.SourceRemove(r.grace)
+		r.grace = 0
+	}
+	if r.retry != 0 {
+		glib.SourceRemove(r.retry)
+		r.retry = 0
+	}
+}
+
+// disconnected is called when the gateway disconnects and starts reconnecting.
+// The chat stays on screen with a banner in offline mode. Otherwise, it only
+// stays for the grace period, and the loading page shows the details after
+// that.
+func (w *Window) disconnected(code int, err error) {
+	r := &w.reconnect
+	r.status.Attempt++
+	r.status.Code = code
+	r.status.Err = err
+	r.status.NextRetry = time.Time{}
+
+	if offlineMode.Value() {
+		r.offline = true
+	}
+
+	if w.chatVisible() && !r.offline && r.grace == 0 {
+		r.grace = glib.TimeoutSecondsAdd(reconnectGracePeriod, func() {
+			r.grace = 0
+			w.SetTitle("Connecting")
+			w.Stack.SetVisibleChild(w.Loading)
+		})
+	}
+
+	w.updateReconnecting()
+}
+
+// reconnectFailed is called when an attempt to reconnect fails.
+func (w *Window) reconnectFailed(err error) {
+	r := &w.reconnect
+	if r.status.Attempt == 0 {
+		// Not reconnecting. The initial connection reports its own errors.
+		return
+	}
+
+	delay := gateway.DefaultGatewayOpts.ReconnectDelay(r.status.Attempt - 1)
+	r.status.Attempt++
+	r.status.Err = err
+	r.status.NextRetry = time.Now().Add(delay)
+
+	w.updateReconnecting()
+}
+
+func (w *Window) updateReconnecting() {
+	r := &w.reconnect
+
+	w.Loading.SetStatus(r.status)
+	w.Loading.Status.Retry.SetSensitive(!r.retrying)
+	// Working offline needs something to show.
+	w.Loading.Status.Offline.SetVisible(w.Chat != nil)
+
+	w.useChatPage(func(p *ChatPage) {
+		p.ShowConnectionBanner(locale.Sprintf(
+			"Connection lost. Reconnecting (attempt %d)...", r.status.Attempt))
+		// An empty label hides the button.
+		if r.retrying {
+			p.ConnectionBanner.SetButtonLabel("")
+		} else {
+			p.ConnectionBanner.SetButtonLabel(locale.Get("Retry Now"))
+		}
+	})
+}
+
+// RetryNow reconnects right away instead of waiting for the next attempt.
+func (w *Window) RetryNow() {
+	r := &w.reconnect
+	state := w.state
+	if state == nil || r.retrying {
+		return
+	}
+
+	if r.retry != 0 {
+		glib.SourceRemove(r.retry)
+		r.retry = 0
+	}
+
+	r.retrying = true
+	r.status.NextRetry = time.Time{}
+	w.updateReconnecting()
+
+	ctx := w.ctx
+
+	go func() {
+		// Open closes the old gateway, which interrupts its wait before the
+		// next attempt.
+		err := state.Open(ctx)
+
+		glib.IdleAdd(func() {
+			if w.state != state {
+				return
+			}
+
+			r.retrying = false
+			if err == nil {
+				// ConnectedEvent takes care of the rest.
+				return
+			}
+
+			slog.Warn(
+				"cannot reconnect to Discord",
+				"err", err)
+
+			if r.status.Attempt == 0 {
+				r.status.Attempt = 1
+				r.status.Code = -1
+			}
+			w.reconnectFailed(err)
+
+			wait := max(time.Until(r.status.NextRetry), 0)
+			r.retry = glib.TimeoutAdd(uint(wait.Milliseconds()), func() {
+				r.retry = 0
+				w.RetryNow()
+			})
+		})
+	}()
+}
+
+// WorkOffline goes back to the chat while the gateway keeps reconnecting in
+// the background.
+func (w *Window) WorkOffline() {
+	if w.Chat == nil {
+		return
+	}
+
+	r := &w.reconnect
+	r.offline = true
+	if r.grace != 0 {
+		glib.SourceRemove(r.grace)
+		r.grace = 0
+	}
+
+	if !w.chatVisible() {
+		w.SwitchToChatPage()
+	}
+}
+
+func (w *Window) chatVisible() bool {
+	return w.Chat != nil && w.Stack.VisibleChildName() == "chat"
+}
@@ -4,9 +4,16 @@ import (
 	"context"
 	"embed"
 	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/diamondburned/adaptive"
+	"github.com/diamondburned/arikawa/v3/discord"
+	"github.com/diamondburned/arikawa/v3/gateway"
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/locale"
@@ -15,9 +22,15 @@ import (
 	"github.com/diamondburned/gotkit/components/prefui"
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/httputil"
+	"github.com/pkg/errors"
+	"libdb.so/dissent/internal/automation"
 	"libdb.so/dissent/internal/gtkcord"
+	"libdb.so/dissent/internal/shortcuts"
 	"libdb.so/dissent/internal/window"
 	"libdb.so/dissent/internal/window/about"
+	"libdb.so/dissent/internal/window/login"
+	"libdb.so/dissent/internal/window/netinspector"
 
 	_ "github.com/diamondburned/gotkit/gtkutil/aggressivegc"
 	_ "libdb.so/dissent/internal/icons"
@@ -63,33 +76,165 @@ func init() {
 
 func main() {
 	m := manager{}
-	m.app = app.New(context.Background(), "so.libdb.dissent", "Dissent")
+	// Discord links, such as discord://-/channels/..., are given to us as
+	// files to open. The command line is handled by the primary instance, so
+	// that running dissent again can control it.
+	m.app = app.NewWithFlags(
+		context.Background(), "so.libdb.dissent", "Dissent",
+		gio.ApplicationHandlesOpen|gio.ApplicationHandlesCommandLine)
 	m.app.AddJSONActions(map[string]interface{}{
 		"app.preferences": func() { prefui.ShowDialog(m.win.Context()) },
+		"app.shortcuts":   func() { shortcuts.ShowWindow(m.win.Context()) },
 		"app.about":       func() { about.New(m.win.Context()).Present(m.win) },
 		"app.logs":        func() { logui.ShowDefaultViewer(m.win.Context()) },
+		"app.network":     func() { netinspector.ShowDialog(m.win.Context()) },
 		"app.quit":        func() { m.app.Quit() },
 	})
 	m.app.AddActionCallbacks(map[string]gtkutil.ActionCallback{
-		"app.open-channel": m.forwardSignalToWindow("open-channel", gtkcord.SnowflakeVariant),
-		"app.open-guild":   m.forwardSignalToWindow("open-guild", gtkcord.SnowflakeVariant),
+		"app.open-channel":   m.forwardSignalToWindow("open-channel", gtkcord.SnowflakeVariant),
+		"app.open-guild":     m.forwardSignalToWindow("open-guild", gtkcord.SnowflakeVariant),
+		"app.open-message":   m.forwardSignalToWindow("open-message", gtkcord.MessageVariant),
+		"app.set-online":     m.forwardSignalToWindow("set-online", nil),
+		"app.set-idle":       m.forwardSignalToWindow("set-idle", nil),
+		"app.set-dnd":        m.forwardSignalToWindow("set-dnd", nil),
+		"app.set-invisible":  m.forwardSignalToWindow("set-invisible", nil),
+		"app.quick-switcher": m.forwardSignalToWindow("quick-switcher", nil),
+		"app.open-account": {
+			ArgType: gtkcord.SnowflakeVariant,
+			Func: func(args *glib.Variant) {
+				m.openAccount(discord.UserID(args.Int64()))
+			},
+		},
+		"app.open-account-channel": {
+			ArgType: gtkcord.AccountChannelVariant,
+			Func: func(args *glib.Variant) {
+				userID, chID := gtkcord.AccountChannelFromVariant(args)
+				win := m.openAccount(userID)
+				win.ActivateWhenReady("open-channel", gtkcord.NewChannelIDVariant(chID))
+			},
+		},
 	})
-	m.app.AddActionShortcuts(map[string]string{
-		"<Ctrl>Q": "app.quit",
+	m.app.AddMainOption(
+		"token-file", 0, glib.OptionFlagNone, glib.OptionArgFilename,
+		"Log in using the token in the given file instead of the login page "+
+			"(also $DISSENT_TOKEN_FILE)",
+		"PATH")
+	m.app.AddMainOption(
+		"remember-token", 0, glib.OptionFlagNone, glib.OptionArgNone,
+		"Remember the account logged in using --token-file", "")
+	m.app.AddMainOption(
+		"open-channel", 0, glib.OptionFlagNone, glib.OptionArgInt64,
+		"Open the channel with the given ID", "ID")
+	m.app.AddMainOption(
+		"open-guild", 0, glib.OptionFlagNone, glib.OptionArgInt64,
+		"Open the guild with the given ID", "ID")
+	m.app.AddMainOption(
+		"status", 0, glib.OptionFlagNone, glib.OptionArgString,
+		"Set the status to online, idle, dnd or invisible", "STATUS")
+	m.app.AddMainOption(
+		"quick-switcher", 0, glib.OptionFlagNone, glib.OptionArgNone,
+		"Show the quick switcher", "")
+	m.app.ConnectHandleLocalOptions(m.handleLocalOptions)
+	m.app.ConnectCommandLine(m.commandLine)
+	m.app.ConnectStartup(func() {
+		shortcuts.Apply(m.app.Application)
+		m.startAutomation()
 	})
+	m.app.ConnectShutdown(m.stopAutomation)
 	m.app.ConnectActivate(func() { m.activate(m.app.Context()) })
+	m.app.ConnectOpen(func(files []gio.Filer, _ string) { m.open(m.app.Context(), files) })
 	m.app.RunMain()
 }
 
 type manager struct {
-	app *app.Application
-	win *window.Window
+	app  *app.Application
+	ctx  context.Context  // context for new windows
+	win  *window.Window   // main window
+	wins []*window.Window // all windows including win
+	// focused is the window that was focused last. Actions such as
+	// open-channel are sent to it.
+	focused *window.Window
+
+	tokenFile login.TokenFile // from the command line or environment
+
+	automation *automation.Service // nil if not exported
+}
+
+func (m *manager) handleLocalOptions(opts *glib.VariantDict) int {
+	m.tokenFile = login.TokenFileFromEnv()
+
+	if v := opts.LookupValue("token-file", glib.NewVariantType("ay")); v != nil {
+		path, err := filepath.Abs(string(v.Bytestring()))
+		if err != nil {
+			slog.Error(
+				"cannot resolve --token-file",
+				"err", err)
+			return 1
+		}
+		m.tokenFile.Path = path
+	}
+	if opts.Contains("remember-token") {
+		m.tokenFile.Remember = true
+	}
+
+	// Check this locally, so that a typo fails before reaching the running
+	// instance.
+	if v := opts.LookupValue("status", glib.NewVariantType("s")); v != nil {
+		if !slices.Contains(statuses, v.String()) {
+			slog.Error(
+				"invalid --status, must be one of online, idle, dnd or invisible",
+				"status", v.String())
+			return 1
+		}
+	}
+
+	// Keep going.
+	return -1
+}
+
+// statuses are the values of --status. Each has a set-<status> action.
+var statuses = []string{"online", "idle", "dnd", "invisible"}
+
+// commandLine handles the command line given to any instance. It is run by the
+// primary instance.
+func (m *manager) commandLine(cmd *gio.ApplicationCommandLine) int {
+	ctx := m.app.Context()
+	m.activate(ctx)
+
+	opts := cmd.OptionsDict()
+	if v := opts.LookupValue("open-guild", glib.NewVariantType("x")); v != nil {
+		m.app.ActivateAction("open-guild", v)
+	}
+	if v := opts.LookupValue("open-channel", glib.NewVariantType("x")); v != nil {
+		m.app.ActivateAction("open-channel", v)
+	}
+	if v := opts.LookupValue("status", glib.NewVariantType("s")); v != nil {
+		m.app.ActivateAction("set-"+v.String(), nil)
+	}
+	if opts.Contains("quick-switcher") {
+		m.app.ActivateAction("quick-switcher", nil)
+	}
+
+	// The rest are Discord links.
+	if args := cmd.Arguments(); len(args) > 1 {
+		files := make([]gio.Filer, len(args)-1)
+		for i, arg := range args[1:] {
+			files[i] = cmd.CreateFileForArg(arg)
+		}
+		m.open(ctx, files)
+	}
+
+	return 0
 }
 
 func (m *manager) forwardSignalToWindow(name string, t *glib.VariantType) gtkutil.ActionCallback {
 	return gtkutil.ActionCallback{
 		ArgType: t,
-		Func:    func(args *glib.Variant) { m.win.ActivateAction(name, args) },
+		Func: func(args *glib.Variant) {
+			if m.focused != nil {
+				m.focused.ActivateWhenReady(name, args)
+			}
+		},
 	}
 }
 
@@ -99,12 +244,104 @@ func (m *manager) activate(ctx context.Context) {
 		return
 	}
 
-	m.win = window.NewWindow(ctx)
+	// Load the preferences before anything connects, since the proxy
+	// preferences must be known by then.
+	if err := loadPrefs(ctx); err != nil {
+		app.Error(ctx, err)
+	}
+	gtkcord.LoadProxyPassword(ctx)
+
+	// Make images load through the same proxy.
+	ctx = httputil.WithClient(ctx, gtkcord.NewHTTPClient(30*time.Second))
+
+	m.ctx = ctx
+	if m.tokenFile.Path != "" {
+		m.win = window.NewTokenFileWindow(ctx, m.tokenFile)
+	} else {
+		m.win = window.NewWindow(ctx)
+	}
+	m.addWindow(m.win)
 	m.win.Present()
+}
 
-	prefs.AsyncLoadSaved(ctx, func(err error) {
-		if err != nil {
-			app.Error(ctx, err)
+// open opens the Discord links given on the command line or by the desktop.
+func (m *manager) open(ctx context.Context, files []gio.Filer) {
+	m.activate(ctx)
+
+	for _, file := range files {
+		uri := file.URI()
+
+		link, ok := gtkcord.ParseLink(uri)
+		if !ok {
+			slog.Warn(
+				"ignoring URI that is not a Discord channel or message link",
+				"uri", uri)
+			continue
+		}
+
+		if m.focused != nil {
+			m.focused.OpenLink(link)
+		}
+	}
+}
+
+// openAccount presents the window that is logged in as the given account. A
+// new window is opened if there is none. The presented window is returned.
+func (m *manager) openAccount(id discord.UserID) *window.Window {
+	for _, win := range m.wins {
+		if win.AccountID() == id {
+			win.Present()
+			return win
+		}
+	}
+
+	win := window.NewAccountWindow(m.ctx, id)
+	m.addWindow(win)
+	win.Present()
+	return win
+}
+
+func (m *manager) addWindow(win *window.Window) {
+	m.trackWindow(win)
+	// Windows that tabs are moved into are tracked as well.
+	win.ConnectWindowCreated(m.trackWindow)
+}
+
+func (m *manager) trackWindow(win *window.Window) {
+	m.wins = append(m.wins, win)
+	if m.focused == nil {
+		m.focused = win
+	}
+
+	win.ConnectMention(func(ev *gateway.MessageCreateEvent) {
+		m.emitMention(win, ev)
+	})
+	win.NotifyProperty("is-active", func() {
+		if win.IsActive() {
+			m.focused = win
 		}
 	})
+	win.ConnectDestroy(func() {
+		m.wins = slices.DeleteFunc(m.wins, func(w *window.Window) bool { return w == win })
+		if m.win == win {
+			m.win = nil
+			if len(m.wins) > 0 {
+				m.win = m.wins[0]
+			}
+		}
+		if m.focused == win {
+			m.focused = m.win
+		}
+	})
+}
+
+func loadPrefs(ctx context.Context) error {
+	data, err := prefs.ReadSavedData(ctx)
+	if err != nil {
+		return errors.Wrap(err, "cannot read saved preferences")
+	}
+	if err := prefs.LoadData(data); err != nil {
+		return errors.Wrap(err, "cannot load saved preferences")
+	}
+	return nil
 }